@@ -1,18 +1,27 @@
 package cmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/mmbros/quote/internal/quote"
+	"github.com/mmbros/quote/internal/quotegetterdb"
 	"github.com/mmbros/quote/pkg/simpleflag"
 )
 
 const (
 	defaultConfigType = "yaml"
 	defaultMode       = "1"
+
+	defaultHistoryFormat = "table"
+	defaultSourcesFormat = "table"
+	defaultServeAddress  = "localhost:8080"
 )
 
 type appArgs struct {
@@ -25,6 +34,22 @@ type appArgs struct {
 	sources    simpleflag.Strings
 	workers    simpleflag.Int
 	mode       simpleflag.String
+	dateFrom   simpleflag.String
+	dateTo     simpleflag.String
+	status     simpleflag.String
+	format     simpleflag.String
+	output     simpleflag.String
+	stream     simpleflag.Bool
+	stats      simpleflag.Bool
+	refresh    simpleflag.Bool
+	verbose    simpleflag.Bool
+	timeout    simpleflag.String
+	deadline   simpleflag.String
+	hedgeDelay simpleflag.String
+	retries    simpleflag.Int
+	address    simpleflag.String
+	schedule   simpleflag.String
+	runNow     simpleflag.Bool
 }
 
 const (
@@ -33,6 +58,9 @@ const (
 
 Available Commands:
     get      Get the quotes of the specified isins
+    daemon   Refresh the quotes of the isins on a schedule
+    history  Show the quotes saved in the database
+    serve    Run an HTTP server exposing quotes, history and sources
     sources  Show available sources
     tor      Checks if Tor network will be used
 `
@@ -52,6 +80,23 @@ Options:
     -m, --mode        char     result mode: "1" first success or last error (default)
                                             "U" all errors until first success 
                                             "A" all 
+                                            "H" hedged: the next source is used only
+                                                after the hedge delay or a failure
+                                            "Q" quorum: the quote agreed by the sources
+    -f, --format      string   output format: "json" (default), "ndjson", "csv" or "table"
+    -o, --output      path     write the output to the file instead of stdout
+        --stream               print and save each quote as soon as it is retrieved;
+                               only "ndjson" (default) and "csv" formats can be streamed
+        --stats                print the statistics of the run to stderr
+        --refresh              retrieve the quotes even if the quotes saved in the
+                               database are fresh (see the max_age config param)
+    -v, --verbose              log each event of the run to stderr; otherwise, on a
+                               terminal, the progress of the run is shown
+        --timeout     duration default timeout of each request (default 10s)
+        --retries     int      default number of retries of transient failures (default 0)
+        --hedge-delay duration delay before using the next source in hedged mode
+                               (default 0: only after a failure)
+        --deadline    duration maximum duration of the whole run (default no deadline)
 `
 
 	usageTor = `Usage:
@@ -71,10 +116,110 @@ Options:
     -p, --proxy       url     proxy to test the Tor network
 `
 
+	usageHistory = `Usage:
+    quote history [options]
+
+Prints the quotes saved in the database.
+
+Options:
+    -c, --config      path     config file (default is $HOME/.quote.yaml)
+        --config-type string   used if config file does not have the extension in the name;
+                               accepted values are: YAML, TOML and JSON 
+    -d, --database    dns      sqlite3 database where the quotes are saved
+    -i, --isins       strings  list of isins to show
+    -s, --sources     strings  list of sources to show
+        --from        date     show the quotes with date on or after the given date (YYYY-MM-DD)
+        --to          date     show the quotes with date on or before the given date (YYYY-MM-DD)
+        --status      string   "all" success and error quotes (default)
+                               "ok"  success quotes only
+                               "err" error quotes only
+    -f, --format      string   output format: "table" (default), "csv", "json" or "ndjson"
+    -o, --output      path     write the output to the file instead of stdout
+`
+
+	usageDaemon = `Usage:
+    quote daemon [options]
+
+Refreshes the quotes of the isins of the config file on a schedule,
+and saves them to the database, until SIGINT or SIGTERM is received.
+
+The schedule of each isin is the first one defined among the isin schedule,
+the source schedule and the default schedule of the config file.
+A schedule is an interval (e.g. "30m" or "@every 30m"), a descriptor
+("@hourly", "@daily", "@weekly", "@monthly" or "@yearly")
+or a cron expression "minute hour day-of-month month day-of-week"
+(e.g. "0 18 * * mon-fri").
+The sources failing repeatedly are retried with an increasing delay.
+
+Options:
+    -c, --config      path     config file (default is $HOME/.quote.yaml)
+        --config-type string   used if config file does not have the extension in the name;
+                               accepted values are: YAML, TOML and JSON 
+    -i, --isins       strings  list of isins to refresh
+    -s, --sources     strings  list of sources to get the quotes from
+    -p, --proxy       url      default proxy
+    -w, --workers     int      number of workers (default 1)
+    -d, --database    dns      sqlite3 database used to save the quotes (mandatory)
+    -m, --mode        char     result mode: "1" first success or last error (default)
+                                            "U" all errors until first success 
+                                            "A" all 
+                                            "H" hedged: the next source is used only
+                                                after the hedge delay or a failure
+                                            "Q" quorum: the quote agreed by the sources
+        --schedule    string   default schedule of the isins
+        --now                  refresh all the isins at start
+        --timeout     duration default timeout of each request (default 10s)
+        --retries     int      default number of retries of transient failures (default 0)
+        --hedge-delay duration delay before using the next source in hedged mode
+                               (default 0: only after a failure)
+        --deadline    duration maximum duration of each refresh (default no deadline)
+`
+
+	usageServe = `Usage:
+    quote serve [options]
+
+Runs an HTTP server exposing the following JSON endpoints:
+    GET /quotes?isin=...&source=...   retrieves the quotes of the isins
+    GET /history?isin=...&source=...&from=...&to=...&status=...
+                                      returns the quotes saved in the database
+    GET /sources                      returns the available sources
+
+The sources of each isin are selected as in "quote get -i <isin> -s <source>".
+
+Options:
+    -a, --address     addr     address to listen on (default "localhost:8080")
+    -c, --config      path     config file (default is $HOME/.quote.yaml)
+        --config-type string   used if config file does not have the extension in the name;
+                               accepted values are: YAML, TOML and JSON 
+    -p, --proxy       url      default proxy
+    -w, --workers     int      number of workers (default 1)
+    -d, --database    dns      sqlite3 database used to save and read the quotes
+    -m, --mode        char     result mode: "1" first success or last error (default)
+                                            "U" all errors until first success 
+                                            "A" all 
+                                            "H" hedged: the next source is used only
+                                                after the hedge delay or a failure
+                                            "Q" quorum: the quote agreed by the sources
+        --timeout     duration default timeout of each request (default 10s)
+        --retries     int      default number of retries of transient failures (default 0)
+        --hedge-delay duration delay before using the next source in hedged mode
+                               (default 0: only after a failure)
+        --deadline    duration maximum duration of the whole retrieval of the quotes
+                               of each /quotes request (default no deadline)
+`
+
 	usageSources = `Usage:
-	quote sources
+    quote sources [options]
+
+Prints the available sources, with the supported asset kinds,
+the default number of workers and the base url of each source.
+The scrapers and json sources defined in the config file are included.
 
-Prints list of available sources.
+Options:
+    -c, --config      path     config file (default is $HOME/.quote.yaml)
+        --config-type string   used if config file does not have the extension in the name;
+                               accepted values are: YAML, TOML and JSON 
+    -f, --format      string   output format: "table" (default), "csv", "json" or "ndjson"
 `
 )
 
@@ -90,6 +235,16 @@ func initCommandGet(args *appArgs) *simpleflag.Command {
 		{Value: &args.sources, Names: "s,sources"},
 		{Value: &args.workers, Names: "w,workers"},
 		{Value: &args.mode, Names: "m,mode"},
+		{Value: &args.format, Names: "f,format"},
+		{Value: &args.output, Names: "o,output"},
+		{Value: &args.stream, Names: "stream"},
+		{Value: &args.stats, Names: "stats"},
+		{Value: &args.refresh, Names: "refresh"},
+		{Value: &args.verbose, Names: "v,verbose"},
+		{Value: &args.timeout, Names: "timeout"},
+		{Value: &args.retries, Names: "retries"},
+		{Value: &args.deadline, Names: "deadline"},
+		{Value: &args.hedgeDelay, Names: "hedge-delay"},
 	}
 
 	cmd := &simpleflag.Command{
@@ -116,11 +271,92 @@ func initCommandTor(args *appArgs) *simpleflag.Command {
 	return cmd
 }
 
+func initCommandHistory(args *appArgs) *simpleflag.Command {
+
+	flags := []*simpleflag.Flag{
+		{Value: &args.config, Names: "c,config"},
+		{Value: &args.configType, Names: "config-type"},
+		{Value: &args.database, Names: "d,database"},
+		{Value: &args.isins, Names: "i,isins"},
+		{Value: &args.sources, Names: "s,sources"},
+		{Value: &args.dateFrom, Names: "from"},
+		{Value: &args.dateTo, Names: "to"},
+		{Value: &args.status, Names: "status"},
+		{Value: &args.format, Names: "f,format"},
+		{Value: &args.output, Names: "o,output"},
+	}
+
+	cmd := &simpleflag.Command{
+		Names: "history,hist",
+		Usage: usageHistory,
+		Flags: flags,
+	}
+	return cmd
+}
+
+func initCommandDaemon(args *appArgs) *simpleflag.Command {
+
+	flags := []*simpleflag.Flag{
+		{Value: &args.config, Names: "c,config"},
+		{Value: &args.configType, Names: "config-type"},
+		{Value: &args.database, Names: "d,database"},
+		{Value: &args.isins, Names: "i,isins"},
+		{Value: &args.proxy, Names: "p,proxy"},
+		{Value: &args.sources, Names: "s,sources"},
+		{Value: &args.workers, Names: "w,workers"},
+		{Value: &args.mode, Names: "m,mode"},
+		{Value: &args.schedule, Names: "schedule"},
+		{Value: &args.runNow, Names: "now"},
+		{Value: &args.timeout, Names: "timeout"},
+		{Value: &args.retries, Names: "retries"},
+		{Value: &args.deadline, Names: "deadline"},
+		{Value: &args.hedgeDelay, Names: "hedge-delay"},
+	}
+
+	cmd := &simpleflag.Command{
+		Names: "daemon",
+		Usage: usageDaemon,
+		Flags: flags,
+	}
+	return cmd
+}
+
+func initCommandServe(args *appArgs) *simpleflag.Command {
+
+	flags := []*simpleflag.Flag{
+		{Value: &args.address, Names: "a,address"},
+		{Value: &args.config, Names: "c,config"},
+		{Value: &args.configType, Names: "config-type"},
+		{Value: &args.database, Names: "d,database"},
+		{Value: &args.proxy, Names: "p,proxy"},
+		{Value: &args.workers, Names: "w,workers"},
+		{Value: &args.mode, Names: "m,mode"},
+		{Value: &args.timeout, Names: "timeout"},
+		{Value: &args.retries, Names: "retries"},
+		{Value: &args.deadline, Names: "deadline"},
+		{Value: &args.hedgeDelay, Names: "hedge-delay"},
+	}
+
+	cmd := &simpleflag.Command{
+		Names: "serve",
+		Usage: usageServe,
+		Flags: flags,
+	}
+	return cmd
+}
+
 func initCommandSources(args *appArgs) *simpleflag.Command {
 
+	flags := []*simpleflag.Flag{
+		{Value: &args.config, Names: "c,config"},
+		{Value: &args.configType, Names: "config-type"},
+		{Value: &args.format, Names: "f,format"},
+	}
+
 	cmd := &simpleflag.Command{
 		Names: "sources,s",
 		Usage: usageSources,
+		Flags: flags,
 	}
 	return cmd
 }
@@ -133,8 +369,11 @@ func initApp(args *appArgs) *simpleflag.App {
 		Usage:         usageApp,
 		Commands: []*simpleflag.Command{
 			initCommandGet(args),
+			initCommandHistory(args),
 			initCommandTor(args),
 			initCommandSources(args),
+			initCommandServe(args),
+			initCommandDaemon(args),
 		},
 	}
 	return app
@@ -173,19 +412,163 @@ func execGet(args *appArgs, cfg *Config) error {
 		// 	fmt.Printf("Mode: %q\n", cfg.Mode)
 		// }
 		fmt.Printf("Mode: %q (%d)\n", cfg.Mode, cfg.mode)
+		if cfg.Format != "" {
+			fmt.Printf("Format: %q\n", cfg.Format)
+		}
+		if args.output.Value != "" {
+			fmt.Printf("Output: %q\n", args.output.Value)
+		}
+		if args.stream.Value {
+			fmt.Println("Stream: true")
+		}
+		if args.refresh.Value {
+			fmt.Println("Refresh: true")
+		}
+		if cfg.deadline > 0 {
+			fmt.Printf("Deadline: %v\n", cfg.deadline)
+		}
+		if cfg.hedgeDelay > 0 {
+			fmt.Printf("Hedge delay: %v\n", cfg.hedgeDelay)
+		}
+		if cfg.priorityWindow > 0 {
+			fmt.Printf("Priority window: %v\n", cfg.priorityWindow)
+		}
+		if cfg.Quorum > 0 || cfg.Tolerance > 0 {
+			fmt.Printf("Quorum: %d, tolerance %v\n", cfg.Quorum, cfg.Tolerance)
+		}
+		if cfg.CircuitThreshold > 0 {
+			fmt.Printf("Circuit: threshold %d, cool-off %v\n", cfg.CircuitThreshold, cfg.circuitCoolOff)
+		}
 		fmt.Println("Tasks:", jsonString(sis))
 
 		return nil
 	}
 
+	out, err := createOutput(args.output.Value)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
 	// do retrieves the quotes
-	return quote.Get(sis, cfg.Database, cfg.mode)
+	opts := &quote.GetOptions{
+		EngineOptions: cfg.engineOptions(),
+		Database:      cfg.Database,
+		Format:        cfg.Format,
+		Output:        out,
+		Stream:        args.stream.Value,
+		Stats:         args.stats.Value,
+		Refresh:       args.refresh.Value,
+		Verbose:       args.verbose.Value,
+		Progress:      isTerminal(os.Stderr),
+		Deadline:      cfg.deadline,
+	}
+	return quote.Get(sis, opts)
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// createOutput returns the file where the output is written.
+// If path is empty, os.Stdout is returned.
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// nopCloser is a writer with a no-op Close method.
+// Used to prevent closing os.Stdout.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// waitSignal returns a context that is canceled
+// at the first SIGINT or SIGTERM signal.
+func waitSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// parseArgDate parses the date argument in "YYYY-MM-DD" format.
+// An empty string returns the zero date.
+func parseArgDate(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s date %q: expected format is YYYY-MM-DD", name, value)
+	}
+	return t, nil
+}
+
+// historyFilter returns the database filter build from the arguments.
+func historyFilter(args *appArgs) (*quotegetterdb.QuoteFilter, error) {
+	var err error
+
+	flt := &quotegetterdb.QuoteFilter{
+		Isins:   args.isins,
+		Sources: args.sources,
+	}
+	if flt.DateFrom, err = parseArgDate("from", args.dateFrom.Value); err != nil {
+		return nil, err
+	}
+	if flt.DateTo, err = parseArgDate("to", args.dateTo.Value); err != nil {
+		return nil, err
+	}
+	if flt.Status, err = quotegetterdb.ParseStatus(args.status.Value); err != nil {
+		return nil, err
+	}
+	return flt, nil
+}
+
+func execHistory(args *appArgs, cfg *Config) error {
+	flt, err := historyFilter(args)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(args.output.Value)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return quote.History(cfg.Database, flt, historyFormat(args), out)
+}
+
+// historyFormat returns the format of the history command:
+// the format of the config file is used only by the get command.
+func historyFormat(args *appArgs) string {
+	if args.format.Passed {
+		return args.format.Value
+	}
+	return defaultHistoryFormat
 }
 
 func execSources(args *appArgs, cfg *Config) error {
-	sources := quote.Sources()
-	fmt.Printf("Available sources: \"%s\"\n", strings.Join(sources, "\", \""))
-	return nil
+	format := args.format.Value
+	if format == "" {
+		format = defaultSourcesFormat
+	}
+	return quote.WriteSources(os.Stdout, format)
 }
 
 // Execute is the main function
@@ -209,18 +592,31 @@ func Execute() {
 	err := app.Parse(arguments)
 
 	// get configuration
+	// NOTE: allSources does not contain the sources defined in the config file
+	allSources := quote.Sources()
+	if err == nil {
+		cfg, err = GetConfig(args, allSources)
+	}
+
+	// register the sources defined in the config file
 	if err == nil {
-		cfg, err = GetConfig(args, quote.Sources())
+		err = cfg.registerSources()
 	}
 
 	if err == nil {
 		switch app.CommandName() {
 		case "get":
 			err = execGet(args, cfg)
+		case "history":
+			err = execHistory(args, cfg)
 		case "tor":
 			err = execTor(args, cfg)
 		case "sources":
 			err = execSources(args, cfg)
+		case "serve":
+			err = execServe(args, cfg)
+		case "daemon":
+			err = execDaemon(args, cfg)
 		}
 	}
 
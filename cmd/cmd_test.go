@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func initAppHistoryArgs(options string) (*appArgs, error) {
+	args := &appArgs{}
+	cmd := initCommandHistory(args)
+	fs := cmd.FlagSet(nil)
+	err := fs.Parse(strings.Fields(options))
+
+	return args, err
+}
+
+func TestHistoryFilter(t *testing.T) {
+	cases := map[string]struct {
+		argtxt string
+		want   *quotegetterdb.QuoteFilter
+		errmsg string
+	}{
+		"none": {
+			want: &quotegetterdb.QuoteFilter{},
+		},
+		"isins and sources": {
+			argtxt: "-i isin1,isin2 -s source1",
+			want: &quotegetterdb.QuoteFilter{
+				Isins:   []string{"isin1", "isin2"},
+				Sources: []string{"source1"},
+			},
+		},
+		"dates and status": {
+			argtxt: "--from 2020-10-01 --to 2020-10-31 --status ok",
+			want: &quotegetterdb.QuoteFilter{
+				DateFrom: time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC),
+				DateTo:   time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC),
+				Status:   quotegetterdb.SuccessStatus,
+			},
+		},
+		"status err": {
+			argtxt: "--status ERR",
+			want: &quotegetterdb.QuoteFilter{
+				Status: quotegetterdb.ErrorStatus,
+			},
+		},
+		"invalid from": {
+			argtxt: "--from 01/10/2020",
+			errmsg: "invalid from date",
+		},
+		"invalid to": {
+			argtxt: "--to 2020-13-01",
+			errmsg: "invalid to date",
+		},
+		"invalid status": {
+			argtxt: "--status none",
+			errmsg: "invalid status",
+		},
+	}
+
+	for title, c := range cases {
+		args, err := initAppHistoryArgs(c.argtxt)
+		if !assert.NoError(t, err, title) {
+			continue
+		}
+		got, err := historyFilter(args)
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if assert.NoError(t, err, title) {
+			assert.Equal(t, c.want, got, title)
+		}
+	}
+}
+
+func TestHistoryFormat(t *testing.T) {
+	cases := map[string]string{
+		"":                defaultHistoryFormat,
+		"-f csv":          "csv",
+		"--format ndjson": "ndjson",
+	}
+	for argtxt, want := range cases {
+		args, err := initAppHistoryArgs(argtxt)
+		if assert.NoError(t, err, argtxt) {
+			assert.Equal(t, want, historyFormat(args), argtxt)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	availableSources := []string{"source1", "source2", "source3"}
+
+	cfgtxt := `
+workers: 2
+isins:
+  isin1:
+    sources: [source1]
+sources:
+  source2:
+    workers: 3
+  source3:
+    disabled: true
+`
+	args := &appArgs{}
+	cmd := initCommandServe(args)
+	fs := cmd.FlagSet(nil)
+	assert.NoError(t, fs.Parse(strings.Fields("--config-type yaml --timeout 5s")))
+
+	cfg := &Config{}
+	if !assert.NoError(t, cfg.auxGetConfig([]byte(cfgtxt), args, availableSources)) {
+		return
+	}
+	resolve, sources, err := newResolver(args, cfg.parsed, cfg.allSources)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the settings of all the sources, shared by the requests
+	if assert.Len(t, sources, 3) {
+		for _, si := range sources {
+			assert.Empty(t, si.Isins, si.Source)
+			assert.Equal(t, 5*time.Second, si.Timeout, si.Source)
+		}
+		assert.Equal(t, 3, sources[1].Workers)
+	}
+
+	cases := map[string]struct {
+		isins   []string
+		sources []string
+		want    map[string][]string // source -> isins
+		errmsg  string
+	}{
+		"isin of the config file": {
+			isins: []string{"isin1"},
+			want:  map[string][]string{"source1": {"isin1"}},
+		},
+		"new isin": {
+			isins: []string{"isin2"},
+			want: map[string][]string{
+				"source1": {"isin2"},
+				"source2": {"isin2"},
+			},
+		},
+		"sources": {
+			isins:   []string{"isin1", "isin2"},
+			sources: []string{"source3"},
+			want:    map[string][]string{"source3": {"isin1", "isin2"}},
+		},
+		"unknown source": {
+			isins:   []string{"isin1"},
+			sources: []string{"sourceX"},
+			errmsg:  "sourceX",
+		},
+	}
+
+	for title, c := range cases {
+		sis, err := resolve(c.isins, c.sources)
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if !assert.NoError(t, err, title) {
+			continue
+		}
+		got := map[string][]string{}
+		for _, si := range sis {
+			sort.Strings(si.Isins)
+			got[si.Source] = si.Isins
+			assert.Equal(t, 5*time.Second, si.Timeout, title)
+			// sources passed as arguments have the default workers
+			workers := map[string]int{"source1": 2, "source2": 3, "source3": defaultWorkers}
+			assert.Equal(t, workers[si.Source], si.Workers, title)
+		}
+		assert.Equal(t, c.want, got, title)
+	}
+
+	// the requests do not change the parsed config
+	_, err = resolve([]string{"isin1"}, []string{"source3"})
+	assert.NoError(t, err)
+	sis, err := resolve([]string{"isin1"}, nil)
+	if assert.NoError(t, err) && assert.Len(t, sis, 1) {
+		assert.Equal(t, "source1", sis[0].Source)
+	}
+
+	// the resolved config does not change the parsed one
+	assert.Len(t, cfg.Sources, 1)
+	assert.Len(t, cfg.parsed.Sources, 2)
+}
@@ -6,10 +6,15 @@ import (
 	"io/ioutil"
 	"net/url"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mmbros/quote/internal/quote"
+	"github.com/mmbros/quote/internal/quotegetter/jsons/jsonsource"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers/htmlsource"
+	"github.com/mmbros/quote/pkg/schedule"
 	"github.com/mmbros/quote/pkg/taskengine"
 	toml "github.com/pelletier/go-toml"
 	"gopkg.in/yaml.v3"
@@ -24,31 +29,130 @@ const (
 	errmsgSourceWorkers             = "workers must be greater than zero (source %q has workers=%d)"
 	errmsgWorkers                   = "workers must be greater than zero (workers=%d)"
 	errmsgProxy                     = "invalid proxy: %s"
+	errmsgTimeout                   = "invalid timeout %q"
+	errmsgSourceTimeout             = "invalid timeout %q (source %q)"
+	errmsgDeadline                  = "invalid deadline %q"
+	errmsgHedgeDelay                = "invalid hedge delay %q"
+	errmsgPriorityWindow            = "invalid priority window %q"
+	errmsgCircuitThreshold          = "circuit threshold must be greater or equal to zero (circuit_threshold=%d)"
+	errmsgCircuitCoolOff            = "invalid circuit cool-off %q"
+	errmsgQuorum                    = "quorum must be greater or equal to zero (quorum=%d)"
+	errmsgTolerance                 = "tolerance must be greater or equal to zero (tolerance=%v)"
+	errmsgSourceRate                = "invalid rate (source %q): %v"
+	errmsgRetries                   = "retries must be greater or equal to zero (retries=%d)"
+	errmsgSourceRetries             = "retries must be greater or equal to zero (source %q has retries=%d)"
+	errmsgMaxAge                    = "invalid max age %q"
+	errmsgSourceMaxAge              = "invalid max age %q (source %q)"
+	errmsgIsinMaxAge                = "invalid max age %q (isin %q)"
+	errmsgScraper                   = "invalid scraper %q: %v"
+	errmsgScraperDuplicate          = "invalid scraper %q: source already exists"
+	errmsgJSON                      = "invalid json source %q: %v"
+	errmsgIsinWithoutSchedule       = "isin %q without schedule (source %q)"
+	errmsgJSONDuplicate             = "invalid json source %q: source already exists"
 )
 
 type sourceItem struct {
-	Workers  int    `json:"workers,omitempty"`
-	Proxy    string `json:"proxy,omitempty"`
-	Disabled bool   `json:"disabled,omitempty"`
+	Workers  int       `json:"workers,omitempty"`
+	Proxy    string    `json:"proxy,omitempty"`
+	Timeout  string    `json:"timeout,omitempty"`
+	Retries  *int      `json:"retries,omitempty"`
+	Schedule string    `json:"schedule,omitempty"`
+	Priority int       `json:"priority,omitempty"`
+	Rate     *rateItem `json:"rate,omitempty"`
+	MaxAge   string    `json:"max_age,omitempty" yaml:"max_age" toml:"max_age"`
+	Disabled bool      `json:"disabled,omitempty"`
+
+	timeout   time.Duration
+	rate      *taskengine.RateLimit
+	freshness *quote.Freshness
+}
+
+// rateItem is the rate limit of the requests to a source.
+type rateItem struct {
+	Requests int    `json:"requests,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Burst    int    `json:"burst,omitempty"`
+	Spacing  string `json:"spacing,omitempty"`
+}
+
+// rateLimit returns the rate limit of the item.
+// If the interval is not defined, the requests are per second.
+func (r *rateItem) rateLimit() (*taskengine.RateLimit, error) {
+	interval, err := parseDuration(r.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval %q", r.Interval)
+	}
+	if interval == 0 {
+		interval = time.Second
+	}
+	spacing, err := parseDuration(r.Spacing)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spacing %q", r.Spacing)
+	}
+	rl := &taskengine.RateLimit{
+		Requests: r.Requests,
+		Interval: interval,
+		Burst:    r.Burst,
+		Spacing:  spacing,
+	}
+	if _, err := taskengine.NewRateLimiter(*rl); err != nil {
+		return nil, err
+	}
+	return rl, nil
 }
 
 type isinItem struct {
 	Name     string   `json:"name,omitempty"`
 	Disabled bool     `json:"disabled,omitempty"`
 	Sources  []string `json:"sources,omitempty"`
+	Schedule string   `json:"schedule,omitempty"`
+	MaxAge   string   `json:"max_age,omitempty" yaml:"max_age" toml:"max_age"`
+
+	freshness *quote.Freshness
+
+	// ordered is true if the sources are defined in the config file:
+	// their order is the order of preference of the sources for the isin.
+	ordered bool
 }
 
 // Config is ...
 type Config struct {
-	Database string                 `json:"database,omitempty"`
-	Workers  int                    `json:"workers,omitempty"`
-	Proxy    string                 `json:"proxy,omitempty"`
-	Proxies  map[string]string      `json:"proxies,omitempty"`
-	Sources  map[string]*sourceItem `json:"sources,omitempty"`
-	Isins    map[string]*isinItem   `json:"isins,omitempty"`
-	Mode     string                 `json:"mode,omitempty"`
-
-	mode taskengine.Mode
+	Database         string                            `json:"database,omitempty"`
+	Workers          int                               `json:"workers,omitempty"`
+	Proxy            string                            `json:"proxy,omitempty"`
+	Proxies          map[string]string                 `json:"proxies,omitempty"`
+	Sources          map[string]*sourceItem            `json:"sources,omitempty"`
+	Isins            map[string]*isinItem              `json:"isins,omitempty"`
+	Mode             string                            `json:"mode,omitempty"`
+	Format           string                            `json:"format,omitempty"`
+	Timeout          string                            `json:"timeout,omitempty"`
+	Deadline         string                            `json:"deadline,omitempty"`
+	HedgeDelay       string                            `json:"hedge_delay,omitempty" yaml:"hedge_delay" toml:"hedge_delay"`
+	PriorityWindow   string                            `json:"priority_window,omitempty" yaml:"priority_window" toml:"priority_window"`
+	CircuitThreshold int                               `json:"circuit_threshold,omitempty" yaml:"circuit_threshold" toml:"circuit_threshold"`
+	CircuitCoolOff   string                            `json:"circuit_cooloff,omitempty" yaml:"circuit_cooloff" toml:"circuit_cooloff"`
+	Quorum           int                               `json:"quorum,omitempty"`
+	Tolerance        float64                           `json:"tolerance,omitempty"`
+	Retries          int                               `json:"retries,omitempty"`
+	Schedule         string                            `json:"schedule,omitempty"`
+	MaxAge           string                            `json:"max_age,omitempty" yaml:"max_age" toml:"max_age"`
+	Scrapers         map[string]*htmlsource.Definition `json:"scrapers,omitempty"`
+	Jsons            map[string]*jsonsource.Definition `json:"jsons,omitempty"`
+
+	mode           taskengine.Mode
+	timeout        time.Duration
+	deadline       time.Duration
+	hedgeDelay     time.Duration
+	priorityWindow time.Duration
+	circuitCoolOff time.Duration
+	freshness      *quote.Freshness
+	factories      map[string]*quote.SourceFactory // sources defined in the config file
+
+	// the config as parsed, before the merge of the arguments,
+	// and all the sources, with the ones defined in the config file:
+	// used to resolve the isins and sources of each request of the server.
+	parsed     *Config
+	allSources []string
 }
 
 // String returns a json string representation of the object.
@@ -211,6 +315,7 @@ func (cfg *Config) normalizeVars() {
 			v = &isinItem{}
 			cfg.Isins[k] = v
 		}
+		v.ordered = len(v.Sources) > 0
 	}
 	for k, v := range cfg.Sources {
 		if v == nil {
@@ -287,6 +392,30 @@ func (cfg *Config) merge(args *appArgs, allAvailableSources []string) error {
 		cfg.Mode = defaultMode
 	}
 
+	// Format
+	if args.format.Passed {
+		cfg.Format = args.format.Value
+	}
+
+	// Timeout, Deadline, HedgeDelay and Retries
+	if args.timeout.Passed {
+		cfg.Timeout = args.timeout.Value
+	}
+	if args.deadline.Passed {
+		cfg.Deadline = args.deadline.Value
+	}
+	if args.hedgeDelay.Passed {
+		cfg.HedgeDelay = args.hedgeDelay.Value
+	}
+	if args.retries.Passed {
+		cfg.Retries = args.retries.Value
+	}
+
+	// Schedule
+	if args.schedule.Passed {
+		cfg.Schedule = args.schedule.Value
+	}
+
 	// Isins
 	//
 	// If passed, only isins in args are getted
@@ -366,6 +495,7 @@ func (cfg *Config) merge(args *appArgs, allAvailableSources []string) error {
 		// update Isins.sources with args sources
 		for _, i := range cfg.Isins {
 			i.Sources = enabledSources
+			i.ordered = false
 		}
 	}
 	return nil
@@ -430,6 +560,10 @@ func (cfg *Config) checkAndSetMode() error {
 		m = taskengine.UntilFirstSuccess
 	case "A", "ALL":
 		m = taskengine.All
+	case "H", "HEDGED":
+		m = taskengine.Hedged
+	case "Q", "QUORUM":
+		m = taskengine.Quorum
 	default:
 		return fmt.Errorf("invalid mode %q", cfg.Mode)
 	}
@@ -437,12 +571,72 @@ func (cfg *Config) checkAndSetMode() error {
 	return nil
 }
 
+// parseDuration parses a duration string.
+// An empty string returns a zero duration.
+// Negative durations are not allowed.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative duration")
+	}
+	return d, err
+}
+
+func (cfg *Config) checkAndSetDurations() error {
+	var err error
+
+	if cfg.timeout, err = parseDuration(cfg.Timeout); err != nil {
+		return fmt.Errorf(errmsgTimeout, cfg.Timeout)
+	}
+	if cfg.deadline, err = parseDuration(cfg.Deadline); err != nil {
+		return fmt.Errorf(errmsgDeadline, cfg.Deadline)
+	}
+	if cfg.hedgeDelay, err = parseDuration(cfg.HedgeDelay); err != nil {
+		return fmt.Errorf(errmsgHedgeDelay, cfg.HedgeDelay)
+	}
+	if cfg.priorityWindow, err = parseDuration(cfg.PriorityWindow); err != nil {
+		return fmt.Errorf(errmsgPriorityWindow, cfg.PriorityWindow)
+	}
+	if cfg.CircuitThreshold < 0 {
+		return fmt.Errorf(errmsgCircuitThreshold, cfg.CircuitThreshold)
+	}
+	if cfg.circuitCoolOff, err = parseDuration(cfg.CircuitCoolOff); err != nil {
+		return fmt.Errorf(errmsgCircuitCoolOff, cfg.CircuitCoolOff)
+	}
+	if cfg.Quorum < 0 {
+		return fmt.Errorf(errmsgQuorum, cfg.Quorum)
+	}
+	if cfg.Tolerance < 0 {
+		return fmt.Errorf(errmsgTolerance, cfg.Tolerance)
+	}
+	if cfg.Retries < 0 {
+		return fmt.Errorf(errmsgRetries, cfg.Retries)
+	}
+	if cfg.freshness, err = quote.ParseFreshness(cfg.MaxAge); err != nil {
+		return fmt.Errorf(errmsgMaxAge, cfg.MaxAge)
+	}
+	return nil
+}
+
 func (cfg *Config) check(allSources []string) error {
 
 	if err := cfg.checkAndSetMode(); err != nil {
 		return err
 	}
 
+	if err := cfg.checkAndSetDurations(); err != nil {
+		return err
+	}
+
+	if cfg.Format != "" {
+		if err := quote.CheckFormat(cfg.Format); err != nil {
+			return err
+		}
+	}
+
 	setOfAllSources := newSet(allSources)
 
 	// check proxy and workers of each referenced source
@@ -457,7 +651,32 @@ func (cfg *Config) check(allSources []string) error {
 			return fmt.Errorf(errmsgSourceWorkers, s, source.Workers)
 		}
 		if source.Workers == 0 {
-			source.Workers = cfg.Workers
+			source.Workers = cfg.sourceWorkers(s)
+		}
+
+		// timeout
+		timeout, err := parseDuration(source.Timeout)
+		if err != nil {
+			return fmt.Errorf(errmsgSourceTimeout, source.Timeout, s)
+		}
+		if timeout == 0 {
+			timeout = cfg.timeout
+		}
+		source.timeout = timeout
+
+		// retries
+		if source.Retries == nil {
+			retries := cfg.Retries
+			source.Retries = &retries
+		} else if *source.Retries < 0 {
+			return fmt.Errorf(errmsgSourceRetries, s, *source.Retries)
+		}
+
+		// rate
+		if source.Rate != nil {
+			if source.rate, err = source.Rate.rateLimit(); err != nil {
+				return fmt.Errorf(errmsgSourceRate, s, err)
+			}
 		}
 
 		// proxy
@@ -468,6 +687,22 @@ func (cfg *Config) check(allSources []string) error {
 			}
 		}
 		source.Proxy = proxyURL
+
+		// max age
+		if source.freshness, err = quote.ParseFreshness(source.MaxAge); err != nil {
+			return fmt.Errorf(errmsgSourceMaxAge, source.MaxAge, s)
+		}
+		if source.freshness == nil {
+			source.freshness = cfg.freshness
+		}
+	}
+
+	// max age of each isin
+	for i, isin := range cfg.Isins {
+		var err error
+		if isin.freshness, err = quote.ParseFreshness(isin.MaxAge); err != nil {
+			return fmt.Errorf(errmsgIsinMaxAge, isin.MaxAge, i)
+		}
 	}
 
 	return nil
@@ -475,18 +710,31 @@ func (cfg *Config) check(allSources []string) error {
 
 // SourceIsinsList ...
 // If no sources, returns a list with zero items (it does not returns nil).
+// The list is sorted by source, and the isins of each source are sorted.
+//
+// The priority of a source is the priority param of the source,
+// unless the isin defines its sources in the config file:
+// in this case the order of the isin sources is the order of preference.
 // NOTE: it assumes all isins and sources are enabled
 func (cfg *Config) SourceIsinsList() []*quote.SourceIsins {
 
 	// build a map from (enabled) source to (enabled) isins
 	sources := map[string][]string{}
+	// priorities of the sources for the isins with ordered sources
+	priorities := map[string]map[string]int{}
+	// freshness of the isins with a specific max age
+	freshness := map[string]*quote.Freshness{}
 	for i, isin := range cfg.Isins {
 		// skip disabled isins
 		// if i.Disabled {
 		// 	continue
 		// }
 
-		for _, s := range isin.Sources {
+		if isin.freshness != nil {
+			freshness[i] = isin.freshness
+		}
+
+		for j, s := range isin.Sources {
 			// skip disabled sources
 			// if cfg.Sources[s].Disabled {
 			// 	continue
@@ -499,25 +747,122 @@ func (cfg *Config) SourceIsinsList() []*quote.SourceIsins {
 				a = append(a, i)
 			}
 			sources[s] = a
+
+			if isin.ordered && len(isin.Sources) > 1 {
+				p := priorities[s]
+				if p == nil {
+					p = map[string]int{}
+					priorities[s] = p
+				}
+				// the first source has the highest priority
+				p[i] = len(isin.Sources) - j
+			}
 		}
 	}
 
 	sis := make([]*quote.SourceIsins, 0, len(sources))
 	for s, isins := range sources {
 		src := cfg.Sources[s]
+		sort.Strings(isins)
 
 		si := &quote.SourceIsins{
-			Source:  s,
-			Proxy:   src.Proxy,
-			Workers: src.Workers,
-			Isins:   isins,
+			Source:     s,
+			Proxy:      src.Proxy,
+			Workers:    src.Workers,
+			Timeout:    src.timeout,
+			Retries:    *src.Retries,
+			Isins:      isins,
+			Priority:   src.Priority,
+			Priorities: priorities[s],
+			Rate:       src.rate,
+			Freshness:  src.freshness,
+		}
+		for _, isin := range isins {
+			if f, ok := freshness[isin]; ok {
+				if si.IsinFreshness == nil {
+					si.IsinFreshness = map[string]*quote.Freshness{}
+				}
+				si.IsinFreshness[isin] = f
+			}
 		}
 		sis = append(sis, si)
 	}
+	sort.Slice(sis, func(i, j int) bool {
+		return sis[i].Source < sis[j].Source
+	})
 	return sis
 }
 
+// engineOptions returns the options of the retrieval engine.
+func (cfg *Config) engineOptions() quote.EngineOptions {
+	return quote.EngineOptions{
+		Mode:             cfg.mode,
+		HedgeDelay:       cfg.hedgeDelay,
+		PriorityWindow:   cfg.priorityWindow,
+		CircuitThreshold: cfg.CircuitThreshold,
+		CircuitCoolOff:   cfg.circuitCoolOff,
+		Quorum:           cfg.Quorum,
+		Tolerance:        cfg.Tolerance,
+	}
+}
+
+// DaemonTasks returns the isins of each source grouped by schedule.
+// The schedule of an isin is the first one defined among
+// the isin schedule, the source schedule and the default schedule.
+// NOTE: it assumes all isins and sources are enabled
+func (cfg *Config) DaemonTasks() ([]*quote.DaemonTask, error) {
+	schedules := map[string]schedule.Schedule{}
+
+	var tasks []*quote.DaemonTask
+	for _, si := range cfg.SourceIsinsList() {
+		// isins of the source grouped by schedule
+		isinsOfSpec := map[string][]string{}
+		for _, isin := range si.Isins {
+			spec := cfg.Isins[isin].Schedule
+			if spec == "" {
+				spec = cfg.Sources[si.Source].Schedule
+			}
+			if spec == "" {
+				spec = cfg.Schedule
+			}
+			if spec == "" {
+				return nil, fmt.Errorf(errmsgIsinWithoutSchedule, isin, si.Source)
+			}
+			if _, ok := schedules[spec]; !ok {
+				sched, err := schedule.Parse(spec)
+				if err != nil {
+					return nil, err
+				}
+				schedules[spec] = sched
+			}
+			isinsOfSpec[spec] = append(isinsOfSpec[spec], isin)
+		}
+
+		for spec, isins := range isinsOfSpec {
+			src := *si
+			src.Isins = isins
+			tasks = append(tasks, &quote.DaemonTask{
+				SourceIsins: &src,
+				Schedule:    schedules[spec],
+			})
+		}
+	}
+	return tasks, nil
+}
+
 func (cfg *Config) auxGetConfig(data []byte, args *appArgs, allSources []string) error {
+	allSources, err := cfg.parse(data, args, allSources)
+	if err == nil {
+		cfg.parsed, cfg.allSources = cfg.clone(), allSources
+		err = cfg.resolve(args, allSources)
+	}
+	return err
+}
+
+// parse unmarshals the config file data, normalizes the config variables
+// and builds the sources defined in the config file.
+// It returns the list of all the sources: the available ones and the new ones.
+func (cfg *Config) parse(data []byte, args *appArgs, allSources []string) ([]string, error) {
 	var err error
 
 	// 1. unmarshall data
@@ -534,10 +879,18 @@ func (cfg *Config) auxGetConfig(data []byte, args *appArgs, allSources []string)
 	// 2. normalize config variables
 	cfg.normalizeVars()
 
-	// 3. merge command line arguments in config
+	// 2b. add the sources defined in the config file
 	if err == nil {
-		err = cfg.merge(args, allSources)
+		allSources, err = cfg.initFactories(allSources)
 	}
+	return allSources, err
+}
+
+// resolve merges the command line arguments in the parsed config,
+// removes the unused isins and sources and checks the result.
+func (cfg *Config) resolve(args *appArgs, allSources []string) error {
+	// 3. merge command line arguments in config
+	err := cfg.merge(args, allSources)
 
 	// 4. remove unused isins and sources
 	if err == nil {
@@ -552,6 +905,94 @@ func (cfg *Config) auxGetConfig(data []byte, args *appArgs, allSources []string)
 	return err
 }
 
+// sourceWorkers returns the workers of the source s not defined
+// in the config or in args: the default workers of the source
+// in the registry, if any, or the global workers.
+func (cfg *Config) sourceWorkers(s string) int {
+	if w := quote.SourceWorkers(s); w > 0 {
+		return w
+	}
+	return cfg.Workers
+}
+
+// clone returns a copy of the parsed config that can be resolved
+// without changing the original one: the isin and source items are copied,
+// the other maps are shared since they are only read.
+func (cfg *Config) clone() *Config {
+	c := *cfg
+	c.Isins = make(map[string]*isinItem, len(cfg.Isins))
+	for k, v := range cfg.Isins {
+		item := *v
+		c.Isins[k] = &item
+	}
+	c.Sources = make(map[string]*sourceItem, len(cfg.Sources))
+	for k, v := range cfg.Sources {
+		item := *v
+		c.Sources[k] = &item
+	}
+	return &c
+}
+
+// initFactories builds the sources defined in the scrapers and jsons sections of the config.
+// It returns the list of all the sources: the available ones and the new ones.
+func (cfg *Config) initFactories(allSources []string) ([]string, error) {
+	setOfAllSources := newSet(allSources)
+	cfg.factories = map[string]*quote.SourceFactory{}
+
+	for name, def := range cfg.Scrapers {
+		if setOfAllSources.has(name) {
+			return nil, fmt.Errorf(errmsgScraperDuplicate, name)
+		}
+		fn, err := htmlsource.NewQuoteGetterFunc(def)
+		if err != nil {
+			return nil, fmt.Errorf(errmsgScraper, name, err)
+		}
+		cfg.factories[name] = &quote.SourceFactory{
+			New:     fn,
+			Kinds:   def.Kinds,
+			BaseURL: def.BaseURL,
+		}
+		allSources = append(allSources, name)
+	}
+
+	for name, def := range cfg.Jsons {
+		if setOfAllSources.has(name) || cfg.factories[name] != nil {
+			return nil, fmt.Errorf(errmsgJSONDuplicate, name)
+		}
+		fn, err := jsonsource.NewQuoteGetterFunc(def)
+		if err != nil {
+			return nil, fmt.Errorf(errmsgJSON, name, err)
+		}
+		cfg.factories[name] = &quote.SourceFactory{
+			New:     fn,
+			Kinds:   def.Kinds,
+			BaseURL: def.BaseURL,
+		}
+		allSources = append(allSources, name)
+	}
+	return allSources, nil
+}
+
+// registerSources adds the sources defined in the config file
+// to the available sources.
+func (cfg *Config) registerSources() error {
+	for name, f := range cfg.factories {
+		if err := quote.Register(name, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readConfigFile returns the content of the config file passed in args.
+// It returns nil if the config file is not defined.
+func readConfigFile(args *appArgs) ([]byte, error) {
+	if (args != nil) && (args.config.Value != "") {
+		return ioutil.ReadFile(args.config.Value)
+	}
+	return nil, nil
+}
+
 // GetConfig ...
 func GetConfig(args *appArgs, allSources []string) (*Config, error) {
 	var (
@@ -561,11 +1002,9 @@ func GetConfig(args *appArgs, allSources []string) (*Config, error) {
 	cfg := &Config{}
 
 	// 1. read config file, if defined
-	if (args != nil) && (args.config.Value != "") {
-		data, err = ioutil.ReadFile(args.config.Value)
-		if err != nil {
-			return nil, err
-		}
+	data, err = readConfigFile(args)
+	if err != nil {
+		return nil, err
 	}
 
 	err = cfg.auxGetConfig(data, args, allSources)
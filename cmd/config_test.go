@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mmbros/quote/internal/quote"
+	"github.com/mmbros/quote/internal/quotegetter"
 	"github.com/mmbros/quote/pkg/taskengine"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -356,6 +360,61 @@ isins:
 	}
 }
 
+func TestWorkersRegistry(t *testing.T) {
+	// source registered with its default workers
+	const source = "cmd-test-workers"
+	if quote.SourceWorkers(source) == 0 {
+		err := quote.Register(source, &quote.SourceFactory{
+			New:     func(string, *http.Client) quotegetter.QuoteGetter { return nil },
+			Workers: 4,
+		})
+		require.NoError(t, err)
+	}
+	availableSources := []string{"source1", source}
+
+	cases := map[string]struct {
+		argtxt  string
+		cfgtxt  string
+		workers map[string]int
+	}{
+		"default": {
+			argtxt:  "-i isin1",
+			workers: map[string]int{"source1": defaultWorkers, source: 4},
+		},
+		"global workers": {
+			argtxt:  "-w 10 -i isin1",
+			workers: map[string]int{"source1": 10, source: 4},
+		},
+		"args workers": {
+			argtxt:  "-i isin1 -s source1 -s " + source + ":2",
+			workers: map[string]int{"source1": defaultWorkers, source: 2},
+		},
+		"cfg workers": {
+			argtxt: "--config-type=yaml",
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  ` + source + `:
+    workers: 3
+`,
+			workers: map[string]int{"source1": defaultWorkers, source: 3},
+		},
+	}
+	for title, c := range cases {
+		cfg := &Config{}
+		args, _ := initAppGetArgs(c.argtxt)
+		err := cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+		if assert.NoError(t, err, title) {
+			workers := map[string]int{}
+			for _, si := range cfg.SourceIsinsList() {
+				workers[si.Source] = si.Workers
+			}
+			assert.Equal(t, c.workers, workers, title)
+		}
+	}
+}
+
 func TestProxy(t *testing.T) {
 
 	availableSources := []string{"source1", "source2", "source3"}
@@ -752,6 +811,22 @@ func TestMode(t *testing.T) {
 			argtxt: "-i isin1 -m a",
 			want:   taskengine.All,
 		},
+		"args H": {
+			argtxt: "-i isin1 -m H",
+			want:   taskengine.Hedged,
+		},
+		"args hedged": {
+			argtxt: "-i isin1 -m hedged",
+			want:   taskengine.Hedged,
+		},
+		"args Q": {
+			argtxt: "-i isin1 -m Q",
+			want:   taskengine.Quorum,
+		},
+		"args quorum": {
+			argtxt: "-i isin1 -m quorum",
+			want:   taskengine.Quorum,
+		},
 		"args error": {
 			argtxt: "-i isin1 -m s1",
 			errmsg: "invalid mode",
@@ -792,3 +867,836 @@ isins:
 		}
 	}
 }
+
+func TestHedgeDelay(t *testing.T) {
+
+	availableSources := []string{"source1", "source2", "source3"}
+
+	cases := map[string]struct {
+		argtxt string
+		cfgtxt string
+		want   time.Duration
+		errmsg string
+	}{
+		"no hedge delay": {
+			argtxt: "-i isin1 -m H",
+			want:   0,
+		},
+		"args only": {
+			argtxt: "-i isin1 -m H --hedge-delay 500ms",
+			want:   500 * time.Millisecond,
+		},
+		"cfg only": {
+			cfgtxt: `mode: H
+hedge_delay: 2s
+isins:
+  isin1:
+`,
+			want: 2 * time.Second,
+		},
+		"args with cfg": {
+			argtxt: "-i isin1 --hedge-delay 1s",
+			cfgtxt: `hedge_delay: 2s`,
+			want:   time.Second,
+		},
+		"args invalid hedge delay": {
+			argtxt: "-i isin1 --hedge-delay x",
+			errmsg: "invalid hedge delay \"x\"",
+		},
+		"cfg negative hedge delay": {
+			argtxt: "-i isin1",
+			cfgtxt: `hedge_delay: -1s`,
+			errmsg: "invalid hedge delay \"-1s\"",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs(c.argtxt)
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				assert.Equal(t, c.want, cfg.hedgeDelay, title)
+			}
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+
+	availableSources := []string{"source1", "source2", "source3"}
+
+	yaml1 := `
+priority_window: 500ms
+sources:
+  source1:
+    priority: 2
+  source3:
+    priority: -1
+isins:
+  isin1:
+    sources: [source3, source2, source1]
+  isin2:
+    sources: [source2]
+  isin3:
+`
+	type item struct {
+		priority   int
+		priorities map[string]int
+	}
+
+	cases := map[string]struct {
+		argtxt string
+		cfgtxt string
+		want   map[string]item
+		window time.Duration
+		errmsg string
+	}{
+		"cfg only": {
+			cfgtxt: yaml1,
+			want: map[string]item{
+				"source1": {2, map[string]int{"isin1": 1}},
+				"source2": {0, map[string]int{"isin1": 2}},
+				"source3": {-1, map[string]int{"isin1": 3}},
+			},
+			window: 500 * time.Millisecond,
+		},
+		"args sources": {
+			argtxt: "-s source1,source3",
+			cfgtxt: yaml1,
+			want: map[string]item{
+				"source1": {2, nil},
+				"source3": {-1, nil},
+			},
+			window: 500 * time.Millisecond,
+		},
+		"args isins": {
+			argtxt: "-i isin1,isin4",
+			cfgtxt: yaml1,
+			want: map[string]item{
+				"source1": {2, map[string]int{"isin1": 1}},
+				"source2": {0, map[string]int{"isin1": 2}},
+				"source3": {-1, map[string]int{"isin1": 3}},
+			},
+			window: 500 * time.Millisecond,
+		},
+		"cfg invalid priority window": {
+			argtxt: "-i isin1",
+			cfgtxt: `priority_window: 1`,
+			errmsg: "invalid priority window \"1\"",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs(c.argtxt)
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				got := map[string]item{}
+				for _, si := range cfg.SourceIsinsList() {
+					got[si.Source] = item{si.Priority, si.Priorities}
+				}
+				assert.Equal(t, c.want, got, title)
+				assert.Equal(t, c.window, cfg.priorityWindow, title)
+			}
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+
+	availableSources := []string{"source1", "source2", "source3"}
+
+	cases := map[string]struct {
+		cfgtxt string
+		want   map[string]*taskengine.RateLimit
+		errmsg string
+	}{
+		"rate": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source1:
+    rate:
+      requests: 10
+      interval: 1m
+      burst: 2
+      spacing: 2s
+  source2:
+    rate:
+      requests: 3
+  source3:
+    rate:
+      spacing: 500ms
+`,
+			want: map[string]*taskengine.RateLimit{
+				"source1": {Requests: 10, Interval: time.Minute, Burst: 2, Spacing: 2 * time.Second},
+				"source2": {Requests: 3, Interval: time.Second},
+				"source3": {Interval: time.Second, Spacing: 500 * time.Millisecond},
+			},
+		},
+		"no rate": {
+			cfgtxt: `
+isins:
+  isin1:
+    sources: [source1]
+`,
+			want: map[string]*taskengine.RateLimit{
+				"source1": nil,
+			},
+		},
+		"invalid interval": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source1:
+    rate:
+      requests: 1
+      interval: x
+`,
+			errmsg: "invalid rate (source \"source1\"): invalid interval \"x\"",
+		},
+		"empty rate": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source2:
+    rate:
+      burst: 3
+`,
+			errmsg: "invalid rate (source \"source2\"): invalid rate limit: neither requests nor spacing defined",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs("")
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				got := map[string]*taskengine.RateLimit{}
+				for _, si := range cfg.SourceIsinsList() {
+					got[si.Source] = si.Rate
+				}
+				assert.Equal(t, c.want, got, title)
+			}
+		}
+	}
+}
+
+func TestTimeoutRetries(t *testing.T) {
+
+	availableSources := []string{"source1", "source2", "source3"}
+
+	yaml1 := `
+timeout: 5s
+retries: 2
+
+isins:
+  isin1:
+
+sources:
+  source1:
+    timeout: 30s
+  source2:
+    retries: 0
+`
+	type item struct {
+		timeout time.Duration
+		retries int
+	}
+
+	cases := map[string]struct {
+		argtxt   string
+		cfgtxt   string
+		want     map[string]item
+		deadline time.Duration
+		errmsg   string
+	}{
+		"args only": {
+			argtxt: "-i isin1 --timeout 2s --retries 1 --deadline 1m",
+			want: map[string]item{
+				"source1": {2 * time.Second, 1},
+				"source2": {2 * time.Second, 1},
+				"source3": {2 * time.Second, 1},
+			},
+			deadline: time.Minute,
+		},
+		"cfg only": {
+			cfgtxt: yaml1,
+			want: map[string]item{
+				"source1": {30 * time.Second, 2},
+				"source2": {5 * time.Second, 0},
+				"source3": {5 * time.Second, 2},
+			},
+		},
+		"cfg with args": {
+			argtxt: "--timeout 1s --retries 3",
+			cfgtxt: yaml1,
+			want: map[string]item{
+				"source1": {30 * time.Second, 3},
+				"source2": {time.Second, 0},
+				"source3": {time.Second, 3},
+			},
+		},
+		"args invalid timeout": {
+			argtxt: "-i isin1 --timeout 10",
+			errmsg: "invalid timeout \"10\"",
+		},
+		"args negative timeout": {
+			argtxt: "-i isin1 --timeout -1s",
+			errmsg: "invalid timeout \"-1s\"",
+		},
+		"args invalid deadline": {
+			argtxt: "-i isin1 --deadline x",
+			errmsg: "invalid deadline \"x\"",
+		},
+		"args negative retries": {
+			argtxt: "-i isin1 --retries -1",
+			errmsg: "retries must be greater or equal to zero (retries=-1)",
+		},
+		"cfg invalid source timeout": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source1:
+    timeout: abc
+`,
+			errmsg: "invalid timeout \"abc\" (source \"source1\")",
+		},
+		"cfg negative source retries": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source1:
+    retries: -2
+`,
+			errmsg: "retries must be greater or equal to zero (source \"source1\" has retries=-2)",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs(c.argtxt)
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				got := map[string]item{}
+				for _, si := range cfg.SourceIsinsList() {
+					got[si.Source] = item{si.Timeout, si.Retries}
+				}
+				assert.Equal(t, c.want, got, title)
+				assert.Equal(t, c.deadline, cfg.deadline, title)
+			}
+		}
+	}
+}
+
+func TestScrapers(t *testing.T) {
+
+	availableSources := []string{"source1"}
+
+	yaml1 := `
+isins:
+  isin1:
+    sources: [myscraper]
+
+scrapers:
+  myscraper:
+    kinds: [fund]
+    base_url: https://www.example.com
+    search:
+      url: https://www.example.com/search?q={{.Isin}}
+      link: "td.name > a"
+    info:
+      price: "span.price"
+      currency: "span.currency"
+      date: "span.date"
+      date_layout: "02/01/2006"
+`
+	toml1 := `
+[isins.isin1]
+sources = ["myscraper"]
+
+[scrapers.myscraper]
+kinds = ["fund"]
+base_url = "https://www.example.com"
+
+[scrapers.myscraper.info]
+url = "https://www.example.com/info/{{.Isin}}"
+price = "span.price"
+date = "span.date"
+date_layout = "02/01/2006"
+price_first = true
+`
+
+	cases := map[string]struct {
+		argtxt string
+		cfgtxt string
+		errmsg string
+	}{
+		"yaml": {
+			argtxt: "--config-type yaml",
+			cfgtxt: yaml1,
+		},
+		"toml": {
+			argtxt: "--config-type toml",
+			cfgtxt: toml1,
+		},
+		"duplicate source": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.ReplaceAll(yaml1, "myscraper", "source1"),
+			errmsg: "invalid scraper \"source1\": source already exists",
+		},
+		"invalid definition": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.Replace(yaml1, "date_layout", "layout", 1),
+			errmsg: "invalid scraper \"myscraper\": date layout not defined",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs(c.argtxt)
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if assert.NoError(t, err, title) {
+			sis := cfg.SourceIsinsList()
+			if assert.Equal(t, 1, len(sis), title) {
+				assert.Equal(t, "myscraper", sis[0].Source, title)
+				assert.Equal(t, []string{"isin1"}, sis[0].Isins, title)
+			}
+			f := cfg.factories["myscraper"]
+			if assert.NotNil(t, f, title) {
+				assert.Equal(t, []string{"fund"}, f.Kinds, title)
+				assert.Equal(t, "https://www.example.com", f.BaseURL, title)
+			}
+		}
+	}
+}
+
+func TestJsons(t *testing.T) {
+
+	availableSources := []string{"source1"}
+
+	yaml1 := `
+isins:
+  isin1:
+    sources: [myjson]
+
+scrapers:
+  myscraper:
+    info:
+      url: https://www.example.com/info/{{.Isin}}
+      price: "span.price"
+      date: "span.date"
+      date_layout: "02/01/2006"
+
+jsons:
+  myjson:
+    kinds: [crypto]
+    base_url: https://api.example.com
+    url: https://api.example.com/ticker/{{.Isin | lower}}-eur
+    price: ticker.price
+    currency: ticker.target
+    date: timestamp
+    date_format: unix
+    default_currency: EUR
+`
+	toml1 := `
+[isins.isin1]
+sources = ["myjson"]
+
+[jsons.myjson]
+kinds = ["crypto"]
+base_url = "https://api.example.com"
+url = "https://api.example.com/ticker/{{.Isin | lower}}-eur"
+price = "ticker.price"
+date = "timestamp"
+date_format = "unixms"
+`
+
+	cases := map[string]struct {
+		argtxt string
+		cfgtxt string
+		errmsg string
+	}{
+		"yaml": {
+			argtxt: "--config-type yaml",
+			cfgtxt: yaml1,
+		},
+		"toml": {
+			argtxt: "--config-type toml",
+			cfgtxt: toml1,
+		},
+		"duplicate source": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.ReplaceAll(yaml1, "myjson", "source1"),
+			errmsg: "invalid json source \"source1\": source already exists",
+		},
+		"duplicate scraper": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.ReplaceAll(yaml1, "myjson", "myscraper"),
+			errmsg: "invalid json source \"myscraper\": source already exists",
+		},
+		"invalid definition": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.Replace(yaml1, "price: ticker.price", "price: ticker..price", 1),
+			errmsg: "invalid json source \"myjson\": invalid path \"ticker..price\"",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs(c.argtxt)
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if assert.NoError(t, err, title) {
+			sis := cfg.SourceIsinsList()
+			if assert.Equal(t, 1, len(sis), title) {
+				assert.Equal(t, "myjson", sis[0].Source, title)
+				assert.Equal(t, []string{"isin1"}, sis[0].Isins, title)
+			}
+			f := cfg.factories["myjson"]
+			if assert.NotNil(t, f, title) {
+				assert.Equal(t, []string{"crypto"}, f.Kinds, title)
+				assert.Equal(t, "https://api.example.com", f.BaseURL, title)
+			}
+		}
+	}
+}
+
+func TestDaemonTasks(t *testing.T) {
+	availableSources := []string{"source1", "source2"}
+
+	cfgtxt := `
+schedule: 1h
+isins:
+  isin1:
+    sources: [source1, source2]
+  isin2:
+    sources: [source1]
+    schedule: "0 18 * * mon-fri"
+  isin3:
+    sources: [source2]
+sources:
+  source2:
+    schedule: 30m
+`
+	type task struct {
+		source   string
+		schedule string
+		isins    []string
+	}
+
+	cases := map[string]struct {
+		argtxt string
+		cfgtxt string
+		want   []task
+		errmsg string
+	}{
+		"config": {
+			argtxt: "--config-type yaml",
+			cfgtxt: cfgtxt,
+			want: []task{
+				{"source1", "0 18 * * mon-fri", []string{"isin2"}},
+				{"source1", "1h0m0s", []string{"isin1"}},
+				{"source2", "30m0s", []string{"isin1", "isin3"}},
+			},
+		},
+		"default schedule argument": {
+			argtxt: "--config-type yaml --schedule 2h -i isin1",
+			cfgtxt: cfgtxt,
+			want: []task{
+				{"source1", "2h0m0s", []string{"isin1"}},
+				{"source2", "30m0s", []string{"isin1"}},
+			},
+		},
+		"without schedule": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.Replace(cfgtxt, "schedule: 1h", "", 1),
+			errmsg: "without schedule (source \"source1\")",
+		},
+		"invalid schedule": {
+			argtxt: "--config-type yaml",
+			cfgtxt: strings.Replace(cfgtxt, "schedule: 1h", "schedule: 1x", 1),
+			errmsg: "invalid schedule \"1x\"",
+		},
+	}
+
+	for title, c := range cases {
+		args := &appArgs{}
+		cmd := initCommandDaemon(args)
+		fs := cmd.FlagSet(nil)
+		require.NoError(t, fs.Parse(strings.Fields(c.argtxt)))
+
+		cfg := &Config{}
+		err := cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+		require.NoError(t, err, title)
+
+		tasks, err := cfg.DaemonTasks()
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if !assert.NoError(t, err, title) {
+			continue
+		}
+
+		got := make([]task, 0, len(tasks))
+		for _, tsk := range tasks {
+			sort.Strings(tsk.Isins)
+			got = append(got, task{tsk.Source, fmt.Sprint(tsk.Schedule), tsk.Isins})
+		}
+		sort.Slice(got, func(i, j int) bool {
+			if got[i].source != got[j].source {
+				return got[i].source < got[j].source
+			}
+			return got[i].schedule < got[j].schedule
+		})
+		assert.Equal(t, c.want, got, title)
+	}
+}
+
+func TestCircuit(t *testing.T) {
+
+	availableSources := []string{"source1", "source2"}
+
+	cases := map[string]struct {
+		cfgtxt    string
+		threshold int
+		coolOff   time.Duration
+		errmsg    string
+	}{
+		"no circuit": {
+			cfgtxt: ``,
+		},
+		"threshold": {
+			cfgtxt:    `circuit_threshold: 3`,
+			threshold: 3,
+		},
+		"threshold and cool-off": {
+			cfgtxt: `
+circuit_threshold: 2
+circuit_cooloff: 5m
+`,
+			threshold: 2,
+			coolOff:   5 * time.Minute,
+		},
+		"invalid threshold": {
+			cfgtxt: `circuit_threshold: -1`,
+			errmsg: "circuit threshold must be greater or equal to zero (circuit_threshold=-1)",
+		},
+		"invalid cool-off": {
+			cfgtxt: `circuit_cooloff: x`,
+			errmsg: "invalid circuit cool-off \"x\"",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs("-i isin1")
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				assert.Equal(t, c.threshold, cfg.CircuitThreshold, title)
+				assert.Equal(t, c.coolOff, cfg.circuitCoolOff, title)
+			}
+		}
+	}
+}
+
+func TestQuorum(t *testing.T) {
+
+	availableSources := []string{"source1", "source2"}
+
+	cases := map[string]struct {
+		cfgtxt    string
+		quorum    int
+		tolerance float64
+		errmsg    string
+	}{
+		"default": {
+			cfgtxt: `mode: Q`,
+		},
+		"quorum and tolerance": {
+			cfgtxt: `
+mode: Q
+quorum: 2
+tolerance: 0.01
+`,
+			quorum:    2,
+			tolerance: 0.01,
+		},
+		"invalid quorum": {
+			cfgtxt: `quorum: -1`,
+			errmsg: "quorum must be greater or equal to zero (quorum=-1)",
+		},
+		"invalid tolerance": {
+			cfgtxt: `tolerance: -0.5`,
+			errmsg: "tolerance must be greater or equal to zero (tolerance=-0.5)",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs("-i isin1")
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				assert.Equal(t, c.quorum, cfg.Quorum, title)
+				assert.Equal(t, c.tolerance, cfg.Tolerance, title)
+			}
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+
+	availableSources := []string{"source1", "source2"}
+
+	type freshness struct {
+		source *quote.Freshness
+		isins  map[string]*quote.Freshness
+	}
+
+	cases := map[string]struct {
+		cfgtxt string
+		want   map[string]freshness
+		errmsg string
+	}{
+		"no max age": {
+			cfgtxt: `
+isins:
+  isin1:
+`,
+			want: map[string]freshness{
+				"source1": {},
+				"source2": {},
+			},
+		},
+		"max age": {
+			cfgtxt: `
+max_age: 12h
+isins:
+  isin1:
+  isin2:
+    max_age: today
+sources:
+  source2:
+    max_age: 1h
+`,
+			want: map[string]freshness{
+				"source1": {
+					source: &quote.Freshness{MaxAge: 12 * time.Hour},
+					isins:  map[string]*quote.Freshness{"isin2": {Today: true}},
+				},
+				"source2": {
+					source: &quote.Freshness{MaxAge: time.Hour},
+					isins:  map[string]*quote.Freshness{"isin2": {Today: true}},
+				},
+			},
+		},
+		"invalid max age": {
+			cfgtxt: `max_age: 1x`,
+			errmsg: "invalid max age \"1x\"",
+		},
+		"invalid source max age": {
+			cfgtxt: `
+isins:
+  isin1:
+sources:
+  source1:
+    max_age: yesterday
+`,
+			errmsg: "invalid max age \"yesterday\" (source \"source1\")",
+		},
+		"invalid isin max age": {
+			cfgtxt: `
+isins:
+  isin1:
+    max_age: -1h
+`,
+			errmsg: "invalid max age \"-1h\" (isin \"isin1\")",
+		},
+	}
+	for title, c := range cases {
+
+		cfg := &Config{}
+		args, err := initAppGetArgs("")
+		require.NoError(t, err)
+		err = cfg.auxGetConfig([]byte(c.cfgtxt), args, availableSources)
+
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+		} else {
+			if assert.NoError(t, err, title) {
+				got := map[string]freshness{}
+				for _, si := range cfg.SourceIsinsList() {
+					got[si.Source] = freshness{si.Freshness, si.IsinFreshness}
+				}
+				assert.Equal(t, c.want, got, title)
+			}
+		}
+	}
+}
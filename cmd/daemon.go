@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mmbros/quote/internal/quote"
+)
+
+func execDaemon(args *appArgs, cfg *Config) error {
+	tasks, err := cfg.DaemonTasks()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := waitSignal()
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Refreshing %d isins\n", len(cfg.Isins))
+	return quote.Daemon(ctx, &quote.DaemonOptions{
+		Tasks:         tasks,
+		EngineOptions: cfg.engineOptions(),
+		Database:      cfg.Database,
+		Deadline:      cfg.deadline,
+		RunAtStart:    args.runNow.Value,
+		GracePeriod:   shutdownTimeout,
+	})
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mmbros/quote/internal/quote"
+)
+
+// shutdownTimeout is the maximum time to wait for the in-flight
+// requests to complete when the server is stopped,
+// and for the current refresh to complete when the daemon is stopped.
+const shutdownTimeout = 30 * time.Second
+
+// newResolver returns the function that selects the sources of the isins
+// of each http request, as done by the get command with the
+// --isins and --sources arguments: the parsed config base is merged with
+// the arguments and the isins and sources of the request.
+// It also returns the settings of all the sources that can be requested,
+// shared by the requests.
+// allSources must contain the sources defined in the config file.
+func newResolver(args *appArgs, base *Config, allSources []string) (quote.ResolveFunc, []*quote.SourceIsins, error) {
+	sources, err := base.sourceSettings(args, allSources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(isins, sources []string) ([]*quote.SourceIsins, error) {
+		a := *args
+		a.isins = isins
+		a.sources = sources
+
+		cfg := base.clone()
+		if err := cfg.resolve(&a, allSources); err != nil {
+			return nil, err
+		}
+		return cfg.SourceIsinsList(), nil
+	}, sources, nil
+}
+
+// sourceSettings returns the settings of all the sources of the parsed config,
+// merged with the arguments: the isins are not defined.
+func (cfg *Config) sourceSettings(args *appArgs, allSources []string) ([]*quote.SourceIsins, error) {
+	a := *args
+	a.isins = nil
+	a.sources = nil
+
+	c := cfg.clone()
+	if err := c.merge(&a, allSources); err != nil {
+		return nil, err
+	}
+	// the sources not available are reported by the resolver
+	setOfAllSources := newSet(allSources)
+	for s := range c.Sources {
+		if !setOfAllSources.has(s) {
+			delete(c.Sources, s)
+		}
+	}
+	if err := c.check(allSources); err != nil {
+		return nil, err
+	}
+
+	sis := make([]*quote.SourceIsins, 0, len(allSources))
+	for _, s := range allSources {
+		src := c.Sources[s]
+		sis = append(sis, &quote.SourceIsins{
+			Source:  s,
+			Proxy:   src.Proxy,
+			Workers: src.Workers,
+			Timeout: src.timeout,
+			Retries: *src.Retries,
+			Rate:    src.rate,
+		})
+	}
+	return sis, nil
+}
+
+func execServe(args *appArgs, cfg *Config) error {
+	// the config file parsed by GetConfig is resolved again by each request
+	resolve, sources, err := newResolver(args, cfg.parsed, cfg.allSources)
+	if err != nil {
+		return err
+	}
+
+	h, err := quote.NewHandler(&quote.ServerOptions{
+		Resolve:       resolve,
+		Sources:       sources,
+		EngineOptions: cfg.engineOptions(),
+		Database:      cfg.Database,
+		Deadline:      cfg.deadline,
+	})
+	if err != nil {
+		return err
+	}
+
+	address := args.address.Value
+	if address == "" {
+		address = defaultServeAddress
+	}
+	srv := &http.Server{Addr: address, Handler: h}
+
+	ctx, cancel := waitSignal()
+	defer cancel()
+
+	// graceful shutdown
+	done := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		ctxShutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		done <- srv.Shutdown(ctxShutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", address)
+	if err = srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
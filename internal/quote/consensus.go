@@ -0,0 +1,102 @@
+package quote
+
+import (
+	"math"
+	"strings"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// Consensus of the sources about the quote of an isin in Quorum mode.
+const (
+	// ConsensusAgreed means that all the sources returned the same quote.
+	ConsensusAgreed = "agreed"
+
+	// ConsensusDisputed means that some sources returned a different quote:
+	// the quote of the majority is returned, and the other sources are dissenting.
+	ConsensusDisputed = "disputed"
+
+	// ConsensusSingle means that only one source returned the quote.
+	ConsensusSingle = "single-source"
+)
+
+// agree returns true if the quotes of the two results are the same:
+// equal currency and date, if both defined, and the prices within
+// the relative tolerance (e.g. 0.01 for 1%).
+// With zero tolerance, the prices must be exactly equal, regardless of their scale.
+func agree(a, b *Result, tolerance float64) bool {
+	if a.Currency != "" && b.Currency != "" && !strings.EqualFold(a.Currency, b.Currency) {
+		return false
+	}
+	if a.Date != nil && b.Date != nil {
+		y1, m1, d1 := a.Date.Date()
+		y2, m2, d2 := b.Date.Date()
+		if y1 != y2 || m1 != m2 || d1 != d2 {
+			return false
+		}
+	}
+	if tolerance == 0 {
+		return a.Price.Cmp(b.Price) == 0
+	}
+	p1, p2 := a.Price.Float64(), b.Price.Float64()
+	return math.Abs(p1-p2) <= tolerance*math.Max(math.Abs(p1), math.Abs(p2))
+}
+
+// reconcileResults returns the result of an isin given the results
+// of the sources in order of arrival.
+// The quote agreed by most of the sources is returned, with its consensus
+// and the dissenting sources; in case of a tie, the first quote arrived wins.
+// If no source succeeded, the last error is returned.
+func reconcileResults(results []*Result, tolerance float64) *Result {
+	var success []*Result
+	for _, r := range results {
+		if r.Success() {
+			success = append(success, r)
+		}
+	}
+	if len(success) == 0 {
+		return results[len(results)-1]
+	}
+
+	// the quote agreed by most of the sources
+	best, bestCount := 0, 0
+	for j, r := range success {
+		count := 0
+		for _, other := range success {
+			if agree(r, other, tolerance) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = j, count
+		}
+	}
+
+	res := *success[best]
+	for _, r := range success {
+		if !agree(&res, r, tolerance) {
+			res.Dissenting = append(res.Dissenting, r.Source)
+		}
+	}
+	switch {
+	case len(success) == 1:
+		res.Consensus = ConsensusSingle
+	case len(res.Dissenting) == 0:
+		res.Consensus = ConsensusAgreed
+	default:
+		res.Consensus = ConsensusDisputed
+	}
+	return &res
+}
+
+// Reconciler returns the function that reconciles the results
+// of the sources in Quorum mode, with the given price tolerance.
+func Reconciler(tolerance float64) func(taskengine.TaskID, []taskengine.Result) taskengine.Result {
+	return func(tid taskengine.TaskID, rs []taskengine.Result) taskengine.Result {
+		results := make([]*Result, 0, len(rs))
+		for _, r := range rs {
+			results = append(results, toResult(r))
+		}
+		return reconcileResults(results, tolerance)
+	}
+}
@@ -0,0 +1,139 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/pkg/decimal"
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileResults(t *testing.T) {
+	day1 := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	day1b := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	day2 := time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	ok := func(source string, price string, currency string, date *time.Time) *Result {
+		return &Result{Isin: "isin1", Source: source, Price: decimal.MustParse(price), Currency: currency, Date: date}
+	}
+	ko := func(source string) *Result {
+		err := errors.New("generic error")
+		return &Result{Isin: "isin1", Source: source, Err: err, ErrMsg: err.Error()}
+	}
+
+	cases := map[string]struct {
+		results    []*Result
+		tolerance  float64
+		source     string
+		consensus  string
+		dissenting []string
+	}{
+		"all errors": {
+			results: []*Result{ko("s1"), ko("s2")},
+			source:  "s2",
+		},
+		"single source": {
+			results:   []*Result{ko("s1"), ok("s2", "10", "EUR", &day1)},
+			source:    "s2",
+			consensus: ConsensusSingle,
+		},
+		"agreed": {
+			results:   []*Result{ok("s1", "10", "EUR", &day1), ok("s2", "10", "eur", &day1b), ok("s3", "10", "", nil)},
+			source:    "s1",
+			consensus: ConsensusAgreed,
+		},
+		"agreed with different scale": {
+			results:   []*Result{ok("s1", "126.370", "EUR", &day1), ok("s2", "126.37", "EUR", &day1)},
+			source:    "s1",
+			consensus: ConsensusAgreed,
+		},
+		"disputed exact price": {
+			results:    []*Result{ok("s1", "16777217", "USD", &day1), ok("s2", "16777216", "USD", &day1)},
+			source:     "s1",
+			consensus:  ConsensusDisputed,
+			dissenting: []string{"s2"},
+		},
+		"agreed within tolerance": {
+			results:   []*Result{ok("s1", "10", "EUR", &day1), ok("s2", "10.05", "EUR", &day1)},
+			tolerance: 0.01,
+			source:    "s1",
+			consensus: ConsensusAgreed,
+		},
+		"disputed price": {
+			results:    []*Result{ok("s1", "11", "EUR", &day1), ok("s2", "10", "EUR", &day1), ok("s3", "10", "EUR", &day1)},
+			tolerance:  0.01,
+			source:     "s2",
+			consensus:  ConsensusDisputed,
+			dissenting: []string{"s1"},
+		},
+		"disputed currency": {
+			results:    []*Result{ok("s1", "10", "EUR", &day1), ok("s2", "10", "USD", &day1)},
+			source:     "s1",
+			consensus:  ConsensusDisputed,
+			dissenting: []string{"s2"},
+		},
+		"disputed date": {
+			results:    []*Result{ok("s1", "10", "EUR", &day1), ok("s2", "10", "EUR", &day2), ok("s3", "10", "EUR", &day2)},
+			source:     "s2",
+			consensus:  ConsensusDisputed,
+			dissenting: []string{"s1"},
+		},
+	}
+
+	for title, c := range cases {
+		res := reconcileResults(c.results, c.tolerance)
+		assert.Equal(t, c.source, res.Source, title)
+		assert.Equal(t, c.consensus, res.Consensus, title)
+		assert.Equal(t, c.dissenting, res.Dissenting, title)
+	}
+}
+
+func TestGetQuorum(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2", "source3", "source4")
+
+	cases := map[string]struct {
+		sources    []string
+		quorum     int
+		consensus  string
+		dissenting []string
+	}{
+		"agreed": {
+			sources:   []string{"source1", "source3"},
+			consensus: ConsensusAgreed,
+		},
+		"single source": {
+			sources:   []string{"source1", "source2"},
+			consensus: ConsensusSingle,
+		},
+		"disputed": {
+			sources:    []string{"source1", "source3", "source4"},
+			consensus:  ConsensusDisputed,
+			dissenting: []string{"source4"},
+		},
+		"quorum": {
+			sources:   []string{"source1", "source3", "source4"},
+			quorum:    1,
+			consensus: ConsensusSingle,
+		},
+	}
+
+	for title, c := range cases {
+		sis := []*SourceIsins{}
+		for _, source := range c.sources {
+			sis = append(sis, &SourceIsins{Source: source, Workers: 1, Isins: []string{"isin1"}})
+		}
+		opts := &GetOptions{EngineOptions: EngineOptions{Mode: taskengine.Quorum, Quorum: c.quorum}}
+		engopts, err := opts.engineOptions()
+		require.NoError(t, err)
+
+		res, err := retrieve(context.Background(), reg, sis, nil, engopts)
+		if assert.NoError(t, err, title) && assert.Len(t, res, 1, title) {
+			assert.Equal(t, c.consensus, res[0].Consensus, title)
+			assert.Equal(t, c.dissenting, res[0].Dissenting, title)
+		}
+	}
+}
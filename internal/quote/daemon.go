@@ -0,0 +1,339 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/schedule"
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// Default backoff of the daemon jobs after repeated failures.
+const (
+	defaultBackoffMin = time.Minute
+	defaultBackoffMax = time.Hour
+)
+
+// DaemonTask contains the isins of a source refreshed on the same schedule.
+type DaemonTask struct {
+	*SourceIsins
+	Schedule schedule.Schedule
+}
+
+// DaemonOptions contains the options of the Daemon function.
+type DaemonOptions struct {
+	EngineOptions
+
+	// Tasks are the isins to refresh, grouped by source and schedule.
+	Tasks []*DaemonTask
+
+	// Database is the sqlite3 database where the quotes are saved. Mandatory.
+	Database string
+
+	// Deadline is the maximum duration of each refresh.
+	// If zero, no deadline is set.
+	Deadline time.Duration
+
+	// RunAtStart specifies that all the isins are refreshed at start,
+	// before following their schedule.
+	RunAtStart bool
+
+	// GracePeriod is the maximum time waited for the current refresh
+	// to complete when the context is done: after that, it is canceled.
+	// If zero, the current refresh is canceled at once.
+	GracePeriod time.Duration
+
+	// BackoffMin and BackoffMax are the initial and the maximum delay added
+	// to the schedule of an isin after repeated failures of the source.
+	// The delay doubles at each further failure, and it is removed
+	// after a success. If zero, 1 minute and 1 hour are used.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+
+	// Log is the writer where the daemon activity is reported.
+	// If nil, os.Stderr is used.
+	Log io.Writer
+}
+
+// daemonJob is the state of the refresh of an isin from a source.
+type daemonJob struct {
+	source   *SourceIsins // settings of the source; Isins is not used
+	isin     string
+	schedule schedule.Schedule
+	next     time.Time // zero if never
+	failures int       // consecutive failures
+}
+
+func jobKey(source, isin string) string {
+	return source + "\x00" + isin
+}
+
+// backoff returns the delay added to the schedule
+// after the given number of consecutive failures.
+// The first failure does not add any delay.
+func backoff(failures int, min, max time.Duration) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	d := min
+	for j := 2; j < failures && d < max; j++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// daemon is the state of a Daemon execution.
+type daemon struct {
+	opts *DaemonOptions
+	reg  *Registry
+	db   *quotegetterdb.QuoteDatabase
+	log  io.Writer
+	jobs map[string]*daemonJob
+	pool *sourcePool
+}
+
+func newDaemon(opts *DaemonOptions) (*daemon, error) {
+	if opts == nil || len(opts.Tasks) == 0 {
+		return nil, errors.New("no isins to refresh")
+	}
+	if opts.Database == "" {
+		return nil, errors.New("database not defined")
+	}
+
+	d := &daemon{
+		opts: opts,
+		reg:  opts.SourceRegistry(),
+		log:  opts.Log,
+		jobs: map[string]*daemonJob{},
+	}
+	if d.log == nil {
+		d.log = os.Stderr
+	}
+	// check all the isins of each source
+	sources := map[string]*SourceIsins{}
+	for _, task := range opts.Tasks {
+		if task.SourceIsins == nil || task.Schedule == nil {
+			return nil, errors.New("invalid task: source or schedule not defined")
+		}
+		si := sources[task.Source]
+		if si == nil {
+			src := *task.SourceIsins
+			src.Isins = nil
+			si = &src
+			sources[task.Source] = si
+		}
+		for _, isin := range task.Isins {
+			key := jobKey(task.Source, isin)
+			if d.jobs[key] != nil {
+				return nil, fmt.Errorf("isin %q of source %q scheduled twice", isin, task.Source)
+			}
+			d.jobs[key] = &daemonJob{
+				source:   si,
+				isin:     isin,
+				schedule: task.Schedule,
+			}
+			si.Isins = append(si.Isins, isin)
+		}
+	}
+	items := make([]*SourceIsins, 0, len(sources))
+	for _, si := range sources {
+		items = append(items, si)
+	}
+	engopts, err := opts.TaskEngineOptions()
+	if err != nil {
+		return nil, err
+	}
+	if d.pool, err = newSourcePool(d.reg, items, engopts); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Daemon refreshes the isins of the tasks following their schedule,
+// and saves the quotes to the database, until the context is done.
+// The sources that fail repeatedly are retried with an increasing delay.
+// When the context is done, no new refresh is started, and the current one
+// is completed within the grace period and saved before returning.
+func Daemon(ctx context.Context, opts *DaemonOptions) error {
+	d, err := newDaemon(opts)
+	if err != nil {
+		return err
+	}
+
+	defer d.pool.shutdown(context.Background())
+
+	d.db, err = quotegetterdb.Open(opts.Database)
+	if err != nil {
+		return err
+	}
+	defer d.db.Close()
+
+	now := time.Now()
+	for _, job := range d.jobs {
+		if opts.RunAtStart {
+			job.next = now
+		} else {
+			job.next = job.schedule.Next(now)
+		}
+	}
+
+	for {
+		next := d.next()
+		if next.IsZero() {
+			fmt.Fprintln(d.log, "No more isins to refresh")
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		runCtx, cancel := graceContext(ctx, opts.GracePeriod)
+		d.run(runCtx, time.Now())
+		cancel()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// graceContext returns a context that is canceled after the grace period
+// from the time ctx is done, so that the works in progress can complete.
+// The values of ctx are not used.
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	gctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-gctx.Done():
+			return
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-gctx.Done():
+		}
+	}()
+	return gctx, cancel
+}
+
+// next returns the earliest scheduled time of the jobs,
+// or the zero time if none is scheduled.
+func (d *daemon) next() time.Time {
+	var next time.Time
+	for _, job := range d.jobs {
+		if job.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || job.next.Before(next) {
+			next = job.next
+		}
+	}
+	return next
+}
+
+// due returns the sources and isins scheduled on or before now.
+func (d *daemon) due(now time.Time) ([]*SourceIsins, []*daemonJob) {
+	var jobs []*daemonJob
+	sources := map[string]*SourceIsins{}
+
+	for _, job := range d.jobs {
+		if job.next.IsZero() || job.next.After(now) {
+			continue
+		}
+		jobs = append(jobs, job)
+		si := sources[job.source.Source]
+		if si == nil {
+			src := *job.source
+			src.Isins = nil
+			si = &src
+			sources[job.source.Source] = si
+		}
+		si.Isins = append(si.Isins, job.isin)
+	}
+
+	items := make([]*SourceIsins, 0, len(sources))
+	for _, si := range sources {
+		sort.Strings(si.Isins)
+		items = append(items, si)
+	}
+	return items, jobs
+}
+
+// run refreshes the isins scheduled on or before now,
+// saves the results and schedules the next refresh of each isin.
+func (d *daemon) run(ctx context.Context, now time.Time) {
+	items, jobs := d.due(now)
+
+	runCtx, cancel := runContext(ctx, d.opts.Deadline)
+	defer cancel()
+
+	urls, err := selectInfoURLs(d.db, items)
+	if err != nil {
+		fmt.Fprintln(d.log, err)
+	}
+
+	sum := newSummary()
+	results, err := d.pool.retrieve(runCtx, items, urls)
+	if err != nil {
+		fmt.Fprintln(d.log, err)
+	}
+
+	outcome := map[string]bool{}
+	for _, r := range results {
+		if r.Status == taskengine.Canceled {
+			// not a failure of the source: another source has retrieved
+			// the quote (ErrTaskSucceeded or ErrQuorumReached), or the run
+			// has been stopped at the end of the grace period of the shutdown.
+			// In both cases the backoff of the isin is not changed.
+			continue
+		}
+		sum.add(r)
+		if r.Status == taskengine.Skipped {
+			// not retrieved: the backoff of the isin is not changed
+			continue
+		}
+		outcome[jobKey(r.Source, r.Isin)] = r.Success()
+		if err := r.dbInsert(d.db); err != nil {
+			fmt.Fprintln(d.log, err)
+		}
+	}
+
+	min, max := d.opts.BackoffMin, d.opts.BackoffMax
+	if min <= 0 {
+		min = defaultBackoffMin
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+
+	end := time.Now()
+	for _, job := range jobs {
+		if success, ok := outcome[jobKey(job.source.Source, job.isin)]; ok {
+			if success {
+				job.failures = 0
+			} else {
+				job.failures++
+			}
+		}
+		job.next = job.schedule.Next(end.Add(backoff(job.failures, min, max)))
+	}
+
+	fmt.Fprintf(d.log, "%s %v\n", now.Format("2006-01-02 15:04:05"), sum)
+}
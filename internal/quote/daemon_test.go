@@ -0,0 +1,198 @@
+package quote
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/schedule"
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	min, max := time.Minute, 10*time.Minute
+	cases := map[int]time.Duration{
+		0: 0,
+		1: 0,
+		2: time.Minute,
+		3: 2 * time.Minute,
+		4: 4 * time.Minute,
+		5: 8 * time.Minute,
+		6: 10 * time.Minute,
+		9: 10 * time.Minute,
+	}
+	for failures, want := range cases {
+		assert.Equal(t, want, backoff(failures, min, max), "failures=%d", failures)
+	}
+}
+
+func TestNewDaemonErrors(t *testing.T) {
+	reg := newDummyRegistry(t, "source1")
+	every := schedule.Every(time.Minute)
+
+	cases := map[string]struct {
+		opts   *DaemonOptions
+		errmsg string
+	}{
+		"no tasks": {
+			opts:   &DaemonOptions{Database: "db"},
+			errmsg: "no isins to refresh",
+		},
+		"no database": {
+			opts: &DaemonOptions{
+				Tasks: []*DaemonTask{{&SourceIsins{Source: "source1", Isins: []string{"isin1"}}, every}},
+			},
+			errmsg: "database not defined",
+		},
+		"no schedule": {
+			opts: &DaemonOptions{
+				Database: "db",
+				Tasks:    []*DaemonTask{{SourceIsins: &SourceIsins{Source: "source1", Isins: []string{"isin1"}}}},
+			},
+			errmsg: "schedule not defined",
+		},
+		"scheduled twice": {
+			opts: &DaemonOptions{
+				Database: "db",
+				Tasks: []*DaemonTask{
+					{&SourceIsins{Source: "source1", Isins: []string{"isin1"}}, every},
+					{&SourceIsins{Source: "source1", Isins: []string{"isin1"}}, schedule.Every(time.Hour)},
+				},
+			},
+			errmsg: "scheduled twice",
+		},
+		"unknown source": {
+			opts: &DaemonOptions{
+				Database: "db",
+				Tasks:    []*DaemonTask{{&SourceIsins{Source: "sourceX", Isins: []string{"isin1"}}, every}},
+			},
+			errmsg: "not available",
+		},
+	}
+
+	for title, c := range cases {
+		c.opts.Registry = reg
+		_, err := newDaemon(c.opts)
+		if assert.Error(t, err, title) {
+			assert.Contains(t, err.Error(), c.errmsg, title)
+		}
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	var log bytes.Buffer
+
+	// source1-isin1 always succeeds, source2-isin2 always fails:
+	// after the second failure the refresh of source2 is delayed by the backoff
+	opts := &DaemonOptions{
+		EngineOptions: EngineOptions{Mode: taskengine.All, Registry: newDummyRegistry(t, "source1", "source2")},
+		Tasks: []*DaemonTask{
+			{&SourceIsins{Source: "source1", Isins: []string{"isin1"}}, schedule.Every(30 * time.Millisecond)},
+			{&SourceIsins{Source: "source2", Isins: []string{"isin2"}}, schedule.Every(30 * time.Millisecond)},
+		},
+		Database:   dbpath,
+		RunAtStart: true,
+		BackoffMin: time.Hour,
+		Log:        &log,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	err := Daemon(ctx, opts)
+	require.NoError(t, err)
+
+	// NOTE: the error records of the same day replace each other in the database
+	items, err := selectHistory(dbpath, &quotegetterdb.QuoteFilter{Sources: []string{"source1"}})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(items), 4)
+
+	// source2 is refreshed twice only
+	assert.Equal(t, 2, strings.Count(log.String(), "(1 success, 1 errors)"), log.String())
+}
+
+func TestDaemonShutdown(t *testing.T) {
+	for _, c := range []struct {
+		grace time.Duration
+		saved int
+	}{
+		{time.Second, 1}, // the current refresh is completed and saved
+		{0, 0},           // the current refresh is canceled
+	} {
+		dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+		opts := &DaemonOptions{
+			EngineOptions: EngineOptions{Registry: newDummyRegistry(t, "source3")},
+			Tasks: []*DaemonTask{
+				{&SourceIsins{Source: "source3", Isins: []string{"isin1"}}, schedule.Every(time.Hour)},
+			},
+			Database:    dbpath,
+			RunAtStart:  true,
+			GracePeriod: c.grace,
+			Log:         ioutil.Discard,
+		}
+
+		// the context is done while source3 is retrieving isin1
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := Daemon(ctx, opts)
+		cancel()
+		require.NoError(t, err)
+
+		items, err := selectHistory(dbpath, &quotegetterdb.QuoteFilter{})
+		require.NoError(t, err)
+		assert.Len(t, items, c.saved, "grace %v", c.grace)
+	}
+}
+
+func TestDaemonRun(t *testing.T) {
+	every := schedule.Every(time.Minute)
+	opts := &DaemonOptions{
+		EngineOptions: EngineOptions{Mode: taskengine.All, Registry: newDummyRegistry(t, "source1", "source2")},
+		Tasks: []*DaemonTask{
+			{&SourceIsins{Source: "source1", Isins: []string{"isin1"}}, every},
+			{&SourceIsins{Source: "source2", Isins: []string{"isin2"}}, every},
+		},
+		Database:   filepath.Join(t.TempDir(), "quote.sqlite3"),
+		BackoffMin: time.Hour,
+		Log:        ioutil.Discard,
+	}
+	d, err := newDaemon(opts)
+	require.NoError(t, err)
+	d.db, err = quotegetterdb.Open(opts.Database)
+	require.NoError(t, err)
+	defer d.db.Close()
+
+	job1 := d.jobs[jobKey("source1", "isin1")]
+	job2 := d.jobs[jobKey("source2", "isin2")]
+
+	// nothing is due
+	now := time.Now()
+	items, jobs := d.due(now)
+	assert.Empty(t, items)
+	assert.Empty(t, jobs)
+
+	for run := 1; run <= 3; run++ {
+		now := time.Now()
+		job1.next = now
+		job2.next = now
+		d.run(context.Background(), now)
+
+		assert.Equal(t, 0, job1.failures, "run %d", run)
+		assert.Equal(t, run, job2.failures, "run %d", run)
+
+		// the first failure is not delayed
+		delay := job2.next.Sub(job1.next)
+		if run == 1 {
+			assert.Less(t, int64(delay), int64(time.Second), "run %d", run)
+		} else {
+			assert.GreaterOrEqual(t, int64(delay), int64(backoff(run, time.Hour, defaultBackoffMax)), "run %d", run)
+		}
+	}
+}
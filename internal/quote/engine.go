@@ -0,0 +1,82 @@
+package quote
+
+import (
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// EngineOptions contains the options of the retrieval engine,
+// shared by the Get, NewHandler and Daemon functions.
+type EngineOptions struct {
+	// Mode specifies the taskengine mode of execution.
+	Mode taskengine.Mode
+
+	// HedgeDelay is the time after that an isin is requested to the next
+	// source in Hedged mode, if no success has arrived.
+	// If zero, the next source is used only after the failure of the previous ones.
+	HedgeDelay time.Duration
+
+	// PriorityWindow is the maximum time the success of a source is held,
+	// waiting for the result of a source with higher priority.
+	// If zero, the first success is returned immediately.
+	PriorityWindow time.Duration
+
+	// CircuitThreshold is the number of consecutive failures of a source
+	// that stops the retrieval of its remaining isins: they are reported
+	// with the "skipped: circuit open" error. If zero, no circuit breaker is used.
+	// The state of the circuits is shared by the requests of a server
+	// and by the refreshes of a daemon.
+	CircuitThreshold int
+
+	// CircuitCoolOff is the time after that a source with open circuit
+	// is used again. If zero, the circuit stays open.
+	CircuitCoolOff time.Duration
+
+	// Quorum is the number of sources that must return the quote
+	// of an isin in Quorum mode. If zero, all the sources are used.
+	Quorum int
+
+	// Tolerance is the maximum relative difference of the prices
+	// of the same quote in Quorum mode (e.g. 0.01 for 1%).
+	Tolerance float64
+
+	// Registry contains the available sources.
+	// If nil, DefaultRegistry is used.
+	Registry *Registry
+}
+
+// SourceRegistry returns the registry of the sources:
+// Registry, or DefaultRegistry if nil.
+func (eo *EngineOptions) SourceRegistry() *Registry {
+	if eo.Registry == nil {
+		return DefaultRegistry
+	}
+	return eo.Registry
+}
+
+// circuitBreaker returns a new circuit breaker of the sources,
+// or nil if CircuitThreshold is zero.
+func (eo *EngineOptions) circuitBreaker() (*taskengine.CircuitBreaker, error) {
+	if eo.CircuitThreshold == 0 {
+		return nil, nil
+	}
+	return taskengine.NewCircuitBreaker(eo.CircuitThreshold, eo.CircuitCoolOff)
+}
+
+// TaskEngineOptions returns the options of an execution of the engine,
+// with a new circuit breaker created from CircuitThreshold and CircuitCoolOff.
+func (eo *EngineOptions) TaskEngineOptions() (*taskengine.Options, error) {
+	cb, err := eo.circuitBreaker()
+	if err != nil {
+		return nil, err
+	}
+	return &taskengine.Options{
+		Mode:           eo.Mode,
+		HedgeDelay:     eo.HedgeDelay,
+		PriorityWindow: eo.PriorityWindow,
+		Circuit:        cb,
+		Quorum:         eo.Quorum,
+		Reconcile:      Reconciler(eo.Tolerance),
+	}, nil
+}
@@ -0,0 +1,185 @@
+package quote
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats.
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+
+	// FormatNDJSON is the newline delimited json format:
+	// each item is written as a json object in a single line.
+	FormatNDJSON = "ndjson"
+)
+
+// formatItem is an item that can be written by a formatter.
+type formatItem interface {
+	// fields returns the values of the item, one for each column of the header.
+	fields() []string
+}
+
+// formatter writes a sequence of items to an output in a specific format.
+type formatter interface {
+	// write writes (or buffers) a single item.
+	write(item formatItem) error
+	// flush writes any buffered data to the output.
+	flush() error
+}
+
+// CheckFormat returns an error if the output format is not available.
+func CheckFormat(format string) error {
+	_, err := newFormatter(format, ioutil.Discard, nil)
+	return err
+}
+
+// streamFormat checks the format can be used to write each item
+// as soon as it is available, without buffering.
+// It returns the normalized format: an empty format is equivalent to FormatNDJSON.
+func streamFormat(format string) (string, error) {
+	switch f := strings.ToLower(format); f {
+	case "":
+		return FormatNDJSON, nil
+	case FormatNDJSON, "jsonl", FormatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("format %q cannot be streamed", format)
+}
+
+// newFormatter returns a formatter that writes to w in the given format.
+// The header contains the names of the columns of the table and csv formats.
+// An empty format is equivalent to FormatJSON.
+func newFormatter(format string, w io.Writer, header []string) (formatter, error) {
+	switch strings.ToLower(format) {
+	case FormatTable:
+		return newTableFormatter(w, header), nil
+	case FormatCSV:
+		return newCSVFormatter(w, header), nil
+	case FormatJSON, "":
+		return &jsonFormatter{w: w, items: []interface{}{}}, nil
+	case FormatNDJSON, "jsonl":
+		return &ndjsonFormatter{json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q", format)
+}
+
+// tableFormatter writes the items as an aligned text table.
+type tableFormatter struct {
+	tw     *tabwriter.Writer
+	header []string
+}
+
+func newTableFormatter(w io.Writer, header []string) *tableFormatter {
+	return &tableFormatter{
+		tw:     tabwriter.NewWriter(w, 0, 0, 2, ' ', 0),
+		header: header,
+	}
+}
+
+func (f *tableFormatter) writeRow(values []string) error {
+	_, err := fmt.Fprintln(f.tw, strings.Join(values, "\t"))
+	return err
+}
+
+func (f *tableFormatter) write(item formatItem) error {
+	if f.header != nil {
+		if err := f.writeRow(f.header); err != nil {
+			return err
+		}
+		f.header = nil
+	}
+	return f.writeRow(item.fields())
+}
+
+func (f *tableFormatter) flush() error {
+	if f.header != nil {
+		// no items: write the header only
+		if err := f.writeRow(f.header); err != nil {
+			return err
+		}
+		f.header = nil
+	}
+	return f.tw.Flush()
+}
+
+// csvFormatter writes the items in csv format, with a header row.
+type csvFormatter struct {
+	cw     *csv.Writer
+	header []string
+}
+
+func newCSVFormatter(w io.Writer, header []string) *csvFormatter {
+	return &csvFormatter{
+		cw:     csv.NewWriter(w),
+		header: header,
+	}
+}
+
+func (f *csvFormatter) writeHeader() error {
+	if f.header == nil {
+		return nil
+	}
+	header := make([]string, len(f.header))
+	for j, h := range f.header {
+		header[j] = strings.ToLower(h)
+	}
+	f.header = nil
+	return f.cw.Write(header)
+}
+
+func (f *csvFormatter) write(item formatItem) error {
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+	return f.cw.Write(item.fields())
+}
+
+func (f *csvFormatter) flush() error {
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+	f.cw.Flush()
+	return f.cw.Error()
+}
+
+// jsonFormatter writes the items as an indented json array.
+type jsonFormatter struct {
+	w     io.Writer
+	items []interface{}
+}
+
+func (f *jsonFormatter) write(item formatItem) error {
+	f.items = append(f.items, item)
+	return nil
+}
+
+func (f *jsonFormatter) flush() error {
+	json, err := json.MarshalIndent(f.items, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f.w, string(json))
+	return err
+}
+
+// ndjsonFormatter writes each item as a json object in a single line.
+// The items are written as soon as they are received.
+type ndjsonFormatter struct {
+	enc *json.Encoder
+}
+
+func (f *ndjsonFormatter) write(item formatItem) error {
+	return f.enc.Encode(item)
+}
+
+func (f *ndjsonFormatter) flush() error {
+	return nil
+}
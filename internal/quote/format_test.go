@@ -0,0 +1,149 @@
+package quote
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHistoryItems() []*historyItem {
+	ts := time.Date(2020, 10, 1, 10, 11, 12, 0, time.UTC)
+	date := time.Date(2020, 9, 30, 0, 0, 0, 0, time.UTC)
+
+	return []*historyItem{
+		newHistoryItem(&quotegetterdb.QuoteRecord{
+			ID:        1,
+			Isin:      "isin1",
+			Source:    "source1",
+			Timestamp: ts,
+			Date:      date,
+			Price:     "12.34",
+			Currency:  "EUR",
+		}),
+		newHistoryItem(&quotegetterdb.QuoteRecord{
+			ID:        2,
+			Isin:      "isin2",
+			Source:    "source1",
+			Timestamp: ts,
+			ErrMsg:    "no result found",
+		}),
+	}
+}
+
+func TestFormatHistory(t *testing.T) {
+	cases := map[string]string{
+		FormatTable: `ISIN   SOURCE   TIMESTAMP            DATE        PRICE  CURRENCY  ERROR
+isin1  source1  2020-10-01 10:11:12  2020-09-30  12.34  EUR       ` + `
+isin2  source1  2020-10-01 10:11:12                               no result found
+`,
+		FormatCSV: `isin,source,timestamp,date,price,currency,error
+isin1,source1,2020-10-01 10:11:12,2020-09-30,12.34,EUR,
+isin2,source1,2020-10-01 10:11:12,,,,no result found
+`,
+		FormatJSON: `[
+ {
+  "id": 1,
+  "isin": "isin1",
+  "source": "source1",
+  "timestamp": "2020-10-01T10:11:12Z",
+  "date": "2020-09-30T00:00:00Z",
+  "price": 12.34,
+  "currency": "EUR"
+ },
+ {
+  "id": 2,
+  "isin": "isin2",
+  "source": "source1",
+  "timestamp": "2020-10-01T10:11:12Z",
+  "error": "no result found"
+ }
+]
+`,
+	}
+
+	for format, want := range cases {
+		var buf bytes.Buffer
+		err := writeHistory(&buf, format, testHistoryItems())
+		if assert.NoError(t, err, format) {
+			assert.Equal(t, want, buf.String(), format)
+		}
+	}
+}
+
+func TestFormatResults(t *testing.T) {
+	date := time.Date(2020, 9, 30, 0, 0, 0, 0, time.UTC)
+	ts := time.Date(2020, 10, 1, 10, 11, 12, 0, time.UTC)
+	results := []*Result{
+		{
+			Isin:      "isin1",
+			Source:    "source1",
+			Price:     "12.34",
+			Currency:  "EUR",
+			Date:      &date,
+			TimeStart: ts,
+			TimeEnd:   ts,
+		},
+		{
+			Isin:      "isin2",
+			Source:    "source1",
+			Instance:  1,
+			TimeStart: ts,
+			TimeEnd:   ts,
+			ErrMsg:    "no result found",
+			Err:       errors.New("no result found"),
+		},
+	}
+
+	cases := map[string]string{
+		FormatTable: `ISIN   SOURCE   DATE        PRICE  CURRENCY  ERROR
+isin1  source1  2020-09-30  12.34  EUR       ` + `
+isin2  source1                               no result found
+`,
+		FormatCSV: `isin,source,date,price,currency,error
+isin1,source1,2020-09-30,12.34,EUR,
+isin2,source1,,,,no result found
+`,
+		FormatNDJSON: `{"isin":"isin1","source":"source1","instance":0,"price":12.34,"currency":"EUR","date":"2020-09-30T00:00:00Z","time_start":"2020-10-01T10:11:12Z","time_end":"2020-10-01T10:11:12Z"}
+{"isin":"isin2","source":"source1","instance":1,"time_start":"2020-10-01T10:11:12Z","time_end":"2020-10-01T10:11:12Z","error":"no result found"}
+`,
+	}
+
+	for format, want := range cases {
+		var buf bytes.Buffer
+		err := writeResults(&buf, format, results)
+		if assert.NoError(t, err, format) {
+			assert.Equal(t, want, buf.String(), format)
+		}
+	}
+}
+
+func TestFormatEmpty(t *testing.T) {
+	cases := map[string]string{
+		FormatTable:  "ISIN  SOURCE  TIMESTAMP  DATE  PRICE  CURRENCY  ERROR\n",
+		FormatCSV:    "isin,source,timestamp,date,price,currency,error\n",
+		FormatJSON:   "[]\n",
+		FormatNDJSON: "",
+	}
+
+	for format, want := range cases {
+		var buf bytes.Buffer
+		err := writeHistory(&buf, format, nil)
+		if assert.NoError(t, err, format) {
+			assert.Equal(t, want, buf.String(), format)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range []string{"", "table", "CSV", "json", "ndjson", "jsonl"} {
+		assert.NoError(t, CheckFormat(format), format)
+	}
+	err := CheckFormat("xml")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid format")
+	}
+}
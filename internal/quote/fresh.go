@@ -0,0 +1,214 @@
+package quote
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// Freshness is the policy that decides if a quote saved in the database
+// is fresh: the fresh quotes are not retrieved again.
+type Freshness struct {
+	// MaxAge is the maximum time elapsed from the retrieval of a fresh quote.
+	// If zero, it is not used.
+	MaxAge time.Duration `json:"max_age,omitempty"`
+
+	// Today specifies that a quote with today's date is fresh,
+	// e.g. the daily NAV of a fund already published.
+	Today bool `json:"today,omitempty"`
+}
+
+// ParseFreshness returns the freshness policy of the string:
+// "today" for a quote with today's date, or the maximum age
+// of the quote as a duration string (e.g. "12h").
+// An empty string returns nil: the quotes are always retrieved.
+func ParseFreshness(s string) (*Freshness, error) {
+	switch s {
+	case "":
+		return nil, nil
+	case "today":
+		return &Freshness{Today: true}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid freshness %q", s)
+	}
+	return &Freshness{MaxAge: d}, nil
+}
+
+// fresh returns true if the quote record is fresh at the given time.
+// A nil policy never considers a quote fresh.
+func (f *Freshness) fresh(qr *quotegetterdb.QuoteRecord, now time.Time) bool {
+	if f == nil {
+		return false
+	}
+	if f.MaxAge > 0 && now.Sub(qr.Timestamp) < f.MaxAge {
+		return true
+	}
+	if f.Today && !qr.Date.IsZero() {
+		y1, m1, d1 := qr.Date.Date()
+		y2, m2, d2 := now.Date()
+		return y1 == y2 && m1 == m2 && d1 == d2
+	}
+	return false
+}
+
+// cachedResult returns the result of a quote saved in the database.
+// The times of the result are the time of the retrieval of the quote.
+func cachedResult(qr *quotegetterdb.QuoteRecord) *Result {
+	r := &Result{
+		Isin:      qr.Isin,
+		Source:    qr.Source,
+		URL:       qr.URL,
+		Price:     qr.Price,
+		Currency:  qr.Currency,
+		TimeStart: qr.Timestamp,
+		TimeEnd:   qr.Timestamp,
+		Cached:    true,
+	}
+	if !qr.Date.IsZero() {
+		date := qr.Date
+		r.Date = &date
+	}
+	return r
+}
+
+// splitFresh returns the items with the isins to retrieve,
+// and the results of the fresh quotes saved in the database.
+//
+// In All mode, the quote of each source is retrieved only if not fresh.
+// In Quorum mode, an isin is not retrieved if the quotes of a quorum of
+// its sources are fresh: they are reconciled as the retrieved ones.
+// In the other modes, an isin is not retrieved if the quote of any of
+// its sources is fresh: the quote of the source with higher priority,
+// then the most recent, is returned.
+func splitFresh(db *quotegetterdb.QuoteDatabase, items []*SourceIsins, opts *taskengine.Options, now time.Time) ([]*SourceIsins, []*Result, error) {
+	var isins, sources []string
+	for _, item := range items {
+		if item.Freshness == nil && len(item.IsinFreshness) == 0 {
+			continue
+		}
+		sources = append(sources, item.Source)
+		isins = append(isins, item.Isins...)
+	}
+	if len(sources) == 0 {
+		return items, nil, nil
+	}
+
+	records, err := db.SelectQuotes(&quotegetterdb.QuoteFilter{
+		Isins:   isins,
+		Sources: sources,
+		Status:  quotegetterdb.SuccessStatus,
+		Last:    true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// last quote of each source and isin
+	last := map[string]*quotegetterdb.QuoteRecord{}
+	for _, qr := range records {
+		last[jobKey(qr.Source, qr.Isin)] = qr
+	}
+
+	// fresh quote of each source and isin
+	type freshQuote struct {
+		qr       *quotegetterdb.QuoteRecord
+		priority int
+	}
+	fresh := map[string]*freshQuote{}
+	// fresh quotes of each isin, the best first:
+	// the quote of the source with higher priority, then the most recent
+	byIsin := map[string][]*freshQuote{}
+	// number of sources of each isin
+	count := map[string]int{}
+	for _, item := range items {
+		for _, isin := range item.Isins {
+			count[isin]++
+			qr := last[jobKey(item.Source, isin)]
+			if qr == nil || !item.freshness(isin).fresh(qr, now) {
+				continue
+			}
+			fq := &freshQuote{qr, item.priority(isin)}
+			fresh[jobKey(item.Source, isin)] = fq
+			byIsin[isin] = append(byIsin[isin], fq)
+		}
+	}
+	for _, fqs := range byIsin {
+		sort.SliceStable(fqs, func(i, j int) bool {
+			if fqs[i].priority != fqs[j].priority {
+				return fqs[i].priority > fqs[j].priority
+			}
+			return fqs[i].qr.Timestamp.After(fqs[j].qr.Timestamp)
+		})
+	}
+
+	// served returns true if the isin is not retrieved from the source
+	served := func(source, isin string) bool {
+		switch opts.Mode {
+		case taskengine.All:
+			return fresh[jobKey(source, isin)] != nil
+		case taskengine.Quorum:
+			quorum := count[isin]
+			if opts.Quorum > 0 && opts.Quorum < quorum {
+				quorum = opts.Quorum
+			}
+			return len(byIsin[isin]) >= quorum
+		}
+		return len(byIsin[isin]) > 0
+	}
+
+	var cached []*Result
+	for _, item := range items {
+		for _, isin := range item.Isins {
+			if !served(item.Source, isin) {
+				continue
+			}
+			switch fqs := byIsin[isin]; {
+			case opts.Mode == taskengine.All:
+				cached = append(cached, cachedResult(fresh[jobKey(item.Source, isin)].qr))
+			case fqs[0].qr.Source != item.Source:
+				// the result of the isin is added once, with the best source
+			case opts.Mode == taskengine.Quorum:
+				results := make([]taskengine.Result, 0, len(fqs))
+				for _, fq := range fqs {
+					results = append(results, cachedResult(fq.qr))
+				}
+				cached = append(cached, toResult(reconcile(opts, isin, results)))
+			default:
+				cached = append(cached, cachedResult(fqs[0].qr))
+			}
+		}
+	}
+
+	toRetrieve := make([]*SourceIsins, 0, len(items))
+	for _, item := range items {
+		var stale []string
+		for _, isin := range item.Isins {
+			if !served(item.Source, isin) {
+				stale = append(stale, isin)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		si := *item
+		si.Isins = stale
+		toRetrieve = append(toRetrieve, &si)
+	}
+	return toRetrieve, cached, nil
+}
+
+// reconcile returns the result of the isin reconciled from the results
+// of the sources with the Reconcile function of the options,
+// as done by the engine in Quorum mode.
+func reconcile(opts *taskengine.Options, isin string, results []taskengine.Result) taskengine.Result {
+	tid := taskengine.TaskID(isin)
+	if opts.Reconcile == nil {
+		return &taskengine.QuorumResult{TaskID: tid, Results: results}
+	}
+	return opts.Reconcile(tid, results)
+}
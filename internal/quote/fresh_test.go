@@ -0,0 +1,233 @@
+package quote
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/decimal"
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFreshness(t *testing.T) {
+	cases := map[string]struct {
+		s    string
+		want *Freshness
+		err  bool
+	}{
+		"empty":    {"", nil, false},
+		"today":    {"today", &Freshness{Today: true}, false},
+		"duration": {"12h", &Freshness{MaxAge: 12 * time.Hour}, false},
+		"zero":     {"0s", nil, true},
+		"negative": {"-1h", nil, true},
+		"invalid":  {"yesterday", nil, true},
+	}
+	for title, c := range cases {
+		f, err := ParseFreshness(c.s)
+		if c.err {
+			assert.Error(t, err, title)
+			continue
+		}
+		if assert.NoError(t, err, title) {
+			assert.Equal(t, c.want, f, title)
+		}
+	}
+}
+
+func TestFreshnessFresh(t *testing.T) {
+	now := time.Date(2020, 10, 2, 12, 0, 0, 0, time.Local)
+	today := time.Date(2020, 10, 2, 0, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	cases := map[string]struct {
+		f         *Freshness
+		timestamp time.Time
+		date      time.Time
+		want      bool
+	}{
+		"nil":               {nil, now, today, false},
+		"max age":           {&Freshness{MaxAge: time.Hour}, now.Add(-30 * time.Minute), yesterday, true},
+		"max age expired":   {&Freshness{MaxAge: time.Hour}, now.Add(-2 * time.Hour), today, false},
+		"today":             {&Freshness{Today: true}, now.Add(-48 * time.Hour), today, true},
+		"today old date":    {&Freshness{Today: true}, now, yesterday, false},
+		"today zero date":   {&Freshness{Today: true}, now, time.Time{}, false},
+		"max age and today": {&Freshness{MaxAge: time.Hour, Today: true}, now.Add(-2 * time.Hour), today, true},
+	}
+	for title, c := range cases {
+		qr := &quotegetterdb.QuoteRecord{Timestamp: c.timestamp, Date: c.date}
+		assert.Equal(t, c.want, c.f.fresh(qr, now), title)
+	}
+}
+
+// insertFreshRecords saves the quotes used by the freshness tests
+// in a new database, and returns it.
+func insertFreshRecords(t *testing.T, now time.Time) *quotegetterdb.QuoteDatabase {
+	db, err := quotegetterdb.Open(filepath.Join(t.TempDir(), "quote.sqlite3"))
+	require.NoError(t, err)
+
+	quote := func(source, isin string, age time.Duration) *quotegetterdb.QuoteRecord {
+		return &quotegetterdb.QuoteRecord{
+			Isin:      isin,
+			Source:    source,
+			Timestamp: now.Add(-age),
+			Date:      now.Add(-48 * time.Hour).Truncate(24 * time.Hour), // the same NAV date
+			Price:     "12.35",
+			Currency:  "EUR",
+		}
+	}
+	err = db.InsertQuotes(
+		quote("source1", "isin1", time.Hour),
+		quote("source3", "isin1", 3*time.Hour),
+		quote("source1", "isin2", 30*time.Hour),
+		&quotegetterdb.QuoteRecord{Isin: "isin3", Source: "source3", Timestamp: now.Add(-time.Hour), ErrMsg: "generic error"},
+	)
+	require.NoError(t, err)
+	return db
+}
+
+func TestSplitFresh(t *testing.T) {
+	now := time.Now()
+	db := insertFreshRecords(t, now)
+	defer db.Close()
+
+	items := []*SourceIsins{
+		{
+			Source:        "source1",
+			Isins:         []string{"isin1", "isin2", "isin3"},
+			Freshness:     &Freshness{MaxAge: 2 * time.Hour},
+			IsinFreshness: map[string]*Freshness{"isin2": {MaxAge: 48 * time.Hour}},
+		},
+		{
+			Source:    "source3",
+			Isins:     []string{"isin1", "isin3"},
+			Freshness: &Freshness{MaxAge: 4 * time.Hour},
+			Priority:  1,
+		},
+		{
+			Source: "source4",
+			Isins:  []string{"isin1"},
+		},
+	}
+
+	cases := map[string]struct {
+		mode       taskengine.Mode
+		quorum     int
+		cached     []string
+		consensus  []string
+		toRetrieve map[string][]string
+	}{
+		"all": {
+			mode:       taskengine.All,
+			cached:     []string{"source1/isin1", "source1/isin2", "source3/isin1"},
+			toRetrieve: map[string][]string{"source1": {"isin3"}, "source3": {"isin3"}, "source4": {"isin1"}},
+		},
+		// isin1 is not retrieved from source4, without freshness policy
+		"first success": {
+			mode:       taskengine.FirstSuccessOrLastError,
+			cached:     []string{"source1/isin2", "source3/isin1"},
+			toRetrieve: map[string][]string{"source1": {"isin3"}, "source3": {"isin3"}},
+		},
+		// isin1 has 3 sources, but only 2 fresh quotes
+		"quorum of all the sources": {
+			mode:       taskengine.Quorum,
+			cached:     []string{"source1/isin2"},
+			consensus:  []string{ConsensusSingle},
+			toRetrieve: map[string][]string{"source1": {"isin1", "isin3"}, "source3": {"isin1", "isin3"}, "source4": {"isin1"}},
+		},
+		"quorum": {
+			mode:       taskengine.Quorum,
+			quorum:     2,
+			cached:     []string{"source1/isin2", "source3/isin1"},
+			consensus:  []string{ConsensusSingle, ConsensusAgreed},
+			toRetrieve: map[string][]string{"source1": {"isin3"}, "source3": {"isin3"}},
+		},
+	}
+
+	for title, c := range cases {
+		opts := &taskengine.Options{Mode: c.mode, Quorum: c.quorum, Reconcile: Reconciler(0)}
+		toRetrieve, cached, err := splitFresh(db, items, opts, now)
+		require.NoError(t, err, title)
+
+		got := []string{}
+		var consensus []string
+		for _, r := range cached {
+			assert.True(t, r.Cached, title)
+			assert.True(t, r.Success(), title)
+			assert.Empty(t, r.Dissenting, title)
+			got = append(got, r.Source+"/"+r.Isin)
+			if r.Consensus != "" {
+				consensus = append(consensus, r.Consensus)
+			}
+		}
+		assert.Equal(t, c.cached, got, title)
+		assert.Equal(t, c.consensus, consensus, title)
+
+		gotItems := map[string][]string{}
+		for _, si := range toRetrieve {
+			gotItems[si.Source] = si.Isins
+		}
+		assert.Equal(t, c.toRetrieve, gotItems, title)
+	}
+
+	// the items are not changed
+	assert.Equal(t, []string{"isin1", "isin2", "isin3"}, items[0].Isins)
+}
+
+func TestGetFresh(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2")
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	db, err := quotegetterdb.Open(dbpath)
+	require.NoError(t, err)
+	err = db.InsertQuotes(&quotegetterdb.QuoteRecord{
+		Isin: "isin1", Source: "source1", Price: "10.5", Currency: "EUR",
+		Timestamp: time.Now().Add(-10 * time.Minute),
+	})
+	db.Close()
+	require.NoError(t, err)
+
+	sis := []*SourceIsins{
+		{Source: "source1", Workers: 1, Isins: []string{"isin1"}, Freshness: &Freshness{MaxAge: time.Hour}},
+		{Source: "source2", Workers: 1, Isins: []string{"isin2"}, Freshness: &Freshness{MaxAge: time.Hour}},
+	}
+
+	for _, refresh := range []bool{false, true} {
+		var buf bytes.Buffer
+		opts := &GetOptions{
+			EngineOptions: EngineOptions{Mode: taskengine.All, Registry: reg},
+			Database:      dbpath,
+			Output:        &buf,
+			Refresh:       refresh,
+		}
+		require.NoError(t, Get(sis, opts))
+
+		var results []*Result
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+		require.Len(t, results, 2)
+
+		byIsin := map[string]*Result{}
+		for _, r := range results {
+			byIsin[r.Isin] = r
+		}
+		// the error of source2 is not a fresh quote
+		assert.False(t, byIsin["isin2"].Cached)
+		if refresh {
+			assert.False(t, byIsin["isin1"].Cached)
+			assert.Equal(t, decimal.Decimal("12.35"), byIsin["isin1"].Price)
+		} else {
+			assert.True(t, byIsin["isin1"].Cached)
+			assert.Equal(t, decimal.Decimal("10.5"), byIsin["isin1"].Price)
+
+			// the cached quote is not saved again
+			items, err := selectHistory(dbpath, &quotegetterdb.QuoteFilter{Sources: []string{"source1"}})
+			require.NoError(t, err)
+			if assert.Len(t, items, 1) {
+				assert.Equal(t, 1, items[0].ID)
+			}
+		}
+	}
+}
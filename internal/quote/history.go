@@ -0,0 +1,115 @@
+package quote
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/decimal"
+)
+
+// historyItem is a quote record read from the database.
+// Date field is a pointer in order to omit zero dates.
+type historyItem struct {
+	ID        int             `json:"id"`
+	Isin      string          `json:"isin"`
+	Source    string          `json:"source"`
+	Timestamp time.Time       `json:"timestamp"`
+	Date      *time.Time      `json:"date,omitempty"`
+	Price     decimal.Decimal `json:"price,omitempty"`
+	Currency  string          `json:"currency,omitempty"`
+	URL       string          `json:"url,omitempty"`
+	ErrMsg    string          `json:"error,omitempty"`
+}
+
+var historyHeader = []string{"ISIN", "SOURCE", "TIMESTAMP", "DATE", "PRICE", "CURRENCY", "ERROR"}
+
+func newHistoryItem(qr *quotegetterdb.QuoteRecord) *historyItem {
+	item := &historyItem{
+		ID:        qr.ID,
+		Isin:      qr.Isin,
+		Source:    qr.Source,
+		Timestamp: qr.Timestamp.Local(), // stored in UTC
+		Price:     qr.Price,
+		Currency:  qr.Currency,
+		URL:       qr.URL,
+		ErrMsg:    qr.ErrMsg,
+	}
+	if !qr.Date.IsZero() {
+		item.Date = &qr.Date
+	}
+	return item
+}
+
+func (item *historyItem) fields() []string {
+	var date, price string
+	if item.Date != nil {
+		date = item.Date.Format("2006-01-02")
+	}
+	if item.ErrMsg == "" {
+		price = item.Price.String()
+	}
+	return []string{
+		item.Isin,
+		item.Source,
+		item.Timestamp.Format("2006-01-02 15:04:05"),
+		date,
+		price,
+		item.Currency,
+		item.ErrMsg,
+	}
+}
+
+// selectHistory returns the quote records of the database matching the filter.
+func selectHistory(dbpath string, flt *quotegetterdb.QuoteFilter) ([]*historyItem, error) {
+	if len(dbpath) == 0 {
+		return nil, fmt.Errorf("database not defined")
+	}
+
+	db, err := quotegetterdb.Open(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	records, err := db.SelectQuotes(flt)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*historyItem, 0, len(records))
+	for _, qr := range records {
+		items = append(items, newHistoryItem(qr))
+	}
+	return items, nil
+}
+
+// writeHistory writes the items to w in the given format.
+func writeHistory(w io.Writer, format string, items []*historyItem) error {
+	f, err := newFormatter(format, w, historyHeader)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err = f.write(item); err != nil {
+			return err
+		}
+	}
+	return f.flush()
+}
+
+// History writes to w the quotes saved in the database that match the filter.
+// The format parameter specifies the output format: table, csv, json or ndjson.
+func History(dbpath string, flt *quotegetterdb.QuoteFilter, format string, w io.Writer) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	items, err := selectHistory(dbpath, flt)
+	if err != nil {
+		return err
+	}
+
+	return writeHistory(w, format, items)
+}
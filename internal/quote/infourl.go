@@ -0,0 +1,86 @@
+package quote
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers"
+	"github.com/mmbros/quote/internal/quotegetterdb"
+)
+
+// infoURLs contains the info urls of the isins, by jobKey(source, isin),
+// saved in the database by the previous retrievals.
+// The scrapers use the info url to skip the search of the isin.
+// A nil infoURLs contains no urls.
+type infoURLs map[string]string
+
+// get returns the info url of the isin for the source, or "" if not found.
+func (c infoURLs) get(source, isin string) string {
+	return c[jobKey(source, isin)]
+}
+
+// selectInfoURLs returns the info urls of the isins of the items.
+func selectInfoURLs(db *quotegetterdb.QuoteDatabase, items []*SourceIsins) (infoURLs, error) {
+	var isins, sources []string
+	for _, item := range items {
+		sources = append(sources, item.Source)
+		isins = append(isins, item.Isins...)
+	}
+	records, err := db.SelectInfoURLs(isins, sources)
+	if err != nil {
+		return nil, err
+	}
+	urls := infoURLs{}
+	for _, r := range records {
+		urls[jobKey(r.Source, r.Isin)] = r.URL
+	}
+	return urls, nil
+}
+
+// loadInfoURLs returns the info urls of the isins of the items
+// saved in the dbpath database. If dbpath is empty, nil is returned.
+func loadInfoURLs(dbpath string, items []*SourceIsins) (infoURLs, error) {
+	if len(dbpath) == 0 {
+		return nil, nil
+	}
+	db, err := quotegetterdb.Open(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return selectInfoURLs(db, items)
+}
+
+// staleInfoURL returns true if the error of a retrieval that used
+// an info url means that the url is no longer valid:
+// the page is not found or is not about the isin.
+func staleInfoURL(err error) bool {
+	if errors.Is(err, scrapers.ErrNoResultFound) || errors.Is(err, scrapers.ErrIsinMismatch) {
+		return true
+	}
+	var se *quotegetter.StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusNotFound || se.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+// dbInsertInfoURL saves the info url of a success result,
+// or deletes the stale info url of a failed one.
+func (r *Result) dbInsertInfoURL(db *quotegetterdb.QuoteDatabase) error {
+	if r.Err != nil && r.staleURL {
+		return db.DeleteInfoURLs(&quotegetterdb.InfoURLRecord{
+			Isin:   r.Isin,
+			Source: r.Source,
+		})
+	}
+	if r.Err != nil || len(r.URL) == 0 {
+		return nil
+	}
+	return db.InsertInfoURLs(&quotegetterdb.InfoURLRecord{
+		Isin:   r.Isin,
+		Source: r.Source,
+		URL:    r.URL,
+	})
+}
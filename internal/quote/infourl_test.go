@@ -0,0 +1,183 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers"
+	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// urlQuoteGetter is a quote getter that records the urls it receives.
+// An empty url is searched and resolved to infoURL(isin), unless the isin
+// is goneIsin; the "http://stale/" url returns an isin mismatch error.
+type urlQuoteGetter struct {
+	source string
+	client *http.Client
+
+	mu   sync.Mutex
+	urls map[string][]string // by isin
+}
+
+// goneIsin is the isin no longer found by the search.
+const goneIsin = "isin4"
+
+func infoURL(isin string) string {
+	return "http://info/" + isin
+}
+
+func (qg *urlQuoteGetter) Source() string       { return qg.source }
+func (qg *urlQuoteGetter) Client() *http.Client { return qg.client }
+
+func (qg *urlQuoteGetter) GetQuote(ctx context.Context, isin, url string) (*quotegetter.Result, error) {
+	qg.mu.Lock()
+	qg.urls[isin] = append(qg.urls[isin], url)
+	qg.mu.Unlock()
+
+	switch url {
+	case "":
+		if isin == goneIsin {
+			return nil, quotegetter.NewError(qg.source, isin, url, scrapers.ErrNoResultFound)
+		}
+		url = infoURL(isin)
+	case "http://stale/":
+		return nil, quotegetter.NewError(qg.source, isin, url, scrapers.ErrIsinMismatch)
+	}
+	return &quotegetter.Result{
+		Source:   qg.source,
+		Isin:     isin,
+		URL:      url,
+		Date:     time.Now(),
+		Currency: "EUR",
+		Price:    "12.35",
+	}, nil
+}
+
+func TestStaleInfoURL(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"no result found": {scrapers.ErrNoResultFound, true},
+		"isin mismatch":   {fmt.Errorf("wrapped: %w", scrapers.ErrIsinMismatch), true},
+		"not found":       {&quotegetter.StatusError{Method: "GET", Status: "404 Not Found", StatusCode: 404}, true},
+		"server error":    {&quotegetter.StatusError{Method: "GET", Status: "500 Internal Server Error", StatusCode: 500}, false},
+		"other error":     {errors.New("generic error"), false},
+	}
+	for title, c := range cases {
+		assert.Equal(t, c.want, staleInfoURL(c.err), title)
+	}
+}
+
+func TestGetInfoURLs(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	db, err := quotegetterdb.Open(dbpath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.InsertInfoURLs(
+		&quotegetterdb.InfoURLRecord{Isin: "isin1", Source: "source1", URL: "http://stale/"},
+		&quotegetterdb.InfoURLRecord{Isin: "isin2", Source: "source1", URL: infoURL("isin2")},
+		&quotegetterdb.InfoURLRecord{Isin: "isin1", Source: "other", URL: "http://other/"},
+		&quotegetterdb.InfoURLRecord{Isin: goneIsin, Source: "source1", URL: "http://stale/"},
+	)
+	require.NoError(t, err)
+
+	qg := &urlQuoteGetter{urls: map[string][]string{}}
+	reg := NewRegistry()
+	err = reg.Register("source1", &SourceFactory{
+		New: func(source string, client *http.Client) quotegetter.QuoteGetter {
+			qg.source, qg.client = source, client
+			return qg
+		},
+	})
+	require.NoError(t, err)
+
+	sis := []*SourceIsins{
+		{Source: "source1", Workers: 1, Isins: []string{"isin1", "isin2", "isin3", goneIsin}},
+	}
+	urls, err := selectInfoURLs(db, sis)
+	require.NoError(t, err)
+	assert.Len(t, urls, 3)
+
+	res, err := retrieve(context.Background(), reg, sis, urls, &taskengine.Options{Mode: taskengine.All})
+	require.NoError(t, err)
+	for _, r := range res {
+		if r.Isin == goneIsin {
+			assert.False(t, r.Success(), r.Isin)
+		} else {
+			assert.True(t, r.Success(), r.Isin)
+			assert.Equal(t, infoURL(r.Isin), r.URL, r.Isin)
+		}
+		require.NoError(t, r.dbInsert(db))
+	}
+
+	// the stale url is searched again, the cached one is used as is
+	assert.Equal(t, map[string][]string{
+		"isin1":  {"http://stale/", ""},
+		"isin2":  {infoURL("isin2")},
+		"isin3":  {""},
+		goneIsin: {"http://stale/", ""},
+	}, qg.urls)
+
+	// the resolved urls are saved, the stale url not found again is deleted
+	urls, err = selectInfoURLs(db, sis)
+	require.NoError(t, err)
+	assert.Equal(t, infoURLs{
+		jobKey("source1", "isin1"): infoURL("isin1"),
+		jobKey("source1", "isin2"): infoURL("isin2"),
+		jobKey("source1", "isin3"): infoURL("isin3"),
+	}, urls)
+}
+
+func TestStaleInfoURLRate(t *testing.T) {
+	const spacing = 50 * time.Millisecond
+
+	qg := &urlQuoteGetter{urls: map[string][]string{}}
+	reg := NewRegistry()
+	err := reg.Register("source1", &SourceFactory{
+		New: func(source string, client *http.Client) quotegetter.QuoteGetter {
+			qg.source, qg.client = source, client
+			return qg
+		},
+	})
+	require.NoError(t, err)
+
+	sis := []*SourceIsins{{
+		Source:  "source1",
+		Workers: 1,
+		Isins:   []string{"isin1"},
+		Rate:    &taskengine.RateLimit{Spacing: spacing},
+	}}
+	urls := infoURLs{jobKey("source1", "isin1"): "http://stale/"}
+
+	// the search of the stale url waits its turn, as a new work
+	start := time.Now()
+	res, err := retrieve(context.Background(), reg, sis, urls, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.True(t, res[0].Success())
+	assert.True(t, time.Since(start) >= spacing, "elapsed %v", time.Since(start))
+
+	// the context expires while waiting to search again:
+	// the stale url is not searched, and it is not deleted
+	time.Sleep(spacing)
+	ctx, cancel := context.WithTimeout(context.Background(), spacing/2)
+	defer cancel()
+	res, err = retrieve(ctx, reg, sis, urls, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.False(t, res[0].Success())
+	assert.False(t, res[0].staleURL)
+	assert.Equal(t, []string{"http://stale/", "", "http://stale/"}, qg.urls["isin1"])
+}
@@ -0,0 +1,98 @@
+package quote
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// sourcePool retrieves the quotes of concurrent requests on the same
+// workers of the sources: each isin is submitted to the pool by itself,
+// and each source never does more than its workers retrievals at the same time.
+type sourcePool struct {
+	pool    *taskengine.Pool
+	sources map[string]struct{}
+}
+
+// newSourcePool returns the pool of the sources of items.
+// The isins of items are not used.
+func newSourcePool(reg *Registry, items []*SourceIsins, opts *taskengine.Options) (*sourcePool, error) {
+	if err := checkListOfSourceIsins(reg, items); err != nil {
+		return nil, err
+	}
+
+	quoteGetter, err := initQuoteGetters(reg, items)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := make([]*taskengine.Worker, 0, len(items))
+	sources := map[string]struct{}{}
+	for _, item := range items {
+		w, err := newWorker(reg, item, quoteGetter[item.Source])
+		if err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+		sources[item.Source] = struct{}{}
+	}
+
+	pool, err := taskengine.NewPool(ws, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sourcePool{pool: pool, sources: sources}, nil
+}
+
+// retrieve retrieves the quotes specified by the SourceIsins object
+// and returns the results when all of them are available.
+// Of the settings of the sources of items, only the priorities are used:
+// the others are the ones of the pool.
+func (p *sourcePool) retrieve(ctx context.Context, items []*SourceIsins, urls infoURLs) ([]*Result, error) {
+
+	// the tasks of each isin, in order of appearance
+	var isins []string
+	tasks := map[string]map[taskengine.WorkerID]taskengine.Task{}
+	for _, item := range items {
+		if _, ok := p.sources[item.Source]; !ok {
+			return nil, fmt.Errorf("source %q not available", item.Source)
+		}
+		for _, isin := range item.Isins {
+			ts := tasks[isin]
+			if ts == nil {
+				ts = map[taskengine.WorkerID]taskengine.Task{}
+				tasks[isin] = ts
+				isins = append(isins, isin)
+			}
+			ts[taskengine.WorkerID(item.Source)] = newTask(item, isin, urls)
+		}
+	}
+
+	// in case of error, the tasks already submitted are canceled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	futures := make([]*taskengine.Future, 0, len(isins))
+	for _, isin := range isins {
+		f, err := p.pool.Submit(ctx, tasks[isin])
+		if err != nil {
+			return nil, err
+		}
+		futures = append(futures, f)
+	}
+
+	results := []*Result{}
+	for _, f := range futures {
+		for _, r := range f.Results() {
+			results = append(results, toResult(r))
+		}
+	}
+	return results, nil
+}
+
+// shutdown waits for the in-flight retrievals to complete,
+// or cancels them when the context is done.
+func (p *sourcePool) shutdown(ctx context.Context) error {
+	return p.pool.Shutdown(ctx)
+}
@@ -0,0 +1,42 @@
+package quote
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourcePool(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2", "source3")
+	sources := []*SourceIsins{{Source: "source1"}, {Source: "source2", Workers: 2}}
+
+	p, err := newSourcePool(reg, sources, &taskengine.Options{})
+	require.NoError(t, err)
+
+	// each isin is retrieved by itself: one result for each of them
+	items := []*SourceIsins{
+		{Source: "source1", Isins: []string{"isin1", "isin2"}},
+		{Source: "source2", Isins: []string{"isin1"}},
+	}
+	results, err := p.retrieve(context.Background(), items, nil)
+	if assert.NoError(t, err) && assert.Len(t, results, 2) {
+		assert.Equal(t, "isin1", results[0].Isin)
+		assert.Equal(t, "source1", results[0].Source)
+		assert.True(t, results[0].Success())
+		assert.Equal(t, "isin2", results[1].Isin)
+		assert.False(t, results[1].Success())
+	}
+
+	// the source is not a worker of the pool
+	_, err = p.retrieve(context.Background(), []*SourceIsins{{Source: "source3", Isins: []string{"isin1"}}}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not available")
+	}
+
+	assert.NoError(t, p.shutdown(context.Background()))
+	_, err = p.retrieve(context.Background(), items, nil)
+	assert.Equal(t, taskengine.ErrPoolClosed, err)
+}
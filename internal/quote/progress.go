@@ -0,0 +1,135 @@
+package quote
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// progress is the taskengine.Observer that shows a live status line
+// with the isins completed, to be used on a terminal.
+type progress struct {
+	w     io.Writer
+	total int // number of isins
+
+	mu      sync.Mutex
+	done    int
+	errors  int
+	running int
+}
+
+func newProgress(w io.Writer, items []*SourceIsins) *progress {
+	isins := map[string]struct{}{}
+	for _, item := range items {
+		for _, isin := range item.Isins {
+			isins[isin] = struct{}{}
+		}
+	}
+	return &progress{w: w, total: len(isins)}
+}
+
+// print rewrites the status line. It must be called with the lock held.
+func (p *progress) print() {
+	// \r returns to the start of the line, \x1b[K clears the rest of the line
+	fmt.Fprintf(p.w, "\r%d/%d isins, %d errors, %d running\x1b[K", p.done, p.total, p.errors, p.running)
+}
+
+// end terminates the status line.
+func (p *progress) end() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progress) TaskStarted(wid taskengine.WorkerID, inst int, tid taskengine.TaskID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running++
+	p.print()
+}
+
+func (p *progress) TaskFinished(wid taskengine.WorkerID, inst int, tid taskengine.TaskID, res taskengine.Result) {
+	if inst < 0 {
+		// skipped: never started
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	p.print()
+}
+
+func (p *progress) TaskCompleted(tid taskengine.TaskID, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if !success {
+		p.errors++
+	}
+	p.print()
+}
+
+func (p *progress) TaskAssigned(wid taskengine.WorkerID, tid taskengine.TaskID) {}
+func (p *progress) TaskCanceled(tid taskengine.TaskID)                          {}
+func (p *progress) WorkerIdle(wid taskengine.WorkerID, inst int)                {}
+func (p *progress) WorkerClosed(wid taskengine.WorkerID)                        {}
+
+// eventLog is the taskengine.Observer that writes a line for each event,
+// with the time elapsed from the start.
+type eventLog struct {
+	w     io.Writer
+	start time.Time
+	mu    sync.Mutex
+}
+
+func newEventLog(w io.Writer) *eventLog {
+	return &eventLog{w: w, start: time.Now()}
+}
+
+func (l *eventLog) log(format string, a ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elapsed := time.Since(l.start).Round(time.Millisecond)
+	fmt.Fprintf(l.w, "%8v  %s\n", elapsed, fmt.Sprintf(format, a...))
+}
+
+func (l *eventLog) TaskAssigned(wid taskengine.WorkerID, tid taskengine.TaskID) {
+	l.log("assigned   %s to %s", tid, wid)
+}
+
+func (l *eventLog) TaskStarted(wid taskengine.WorkerID, inst int, tid taskengine.TaskID) {
+	l.log("started    %s by %s#%d", tid, wid, inst)
+}
+
+func (l *eventLog) TaskFinished(wid taskengine.WorkerID, inst int, tid taskengine.TaskID, res taskengine.Result) {
+	r := toResult(res)
+	if r.Success() {
+		l.log("finished   %s by %s#%d: %v %s", tid, wid, inst, r.Price, r.Currency)
+	} else {
+		l.log("finished   %s by %s#%d: %s", tid, wid, inst, r.ErrMsg)
+	}
+}
+
+func (l *eventLog) TaskCanceled(tid taskengine.TaskID) {
+	l.log("canceled   %s", tid)
+}
+
+func (l *eventLog) TaskCompleted(tid taskengine.TaskID, success bool) {
+	status := "ok"
+	if !success {
+		status = "error"
+	}
+	l.log("completed  %s: %s", tid, status)
+}
+
+func (l *eventLog) WorkerIdle(wid taskengine.WorkerID, inst int) {
+	l.log("idle       %s#%d", wid, inst)
+}
+
+func (l *eventLog) WorkerClosed(wid taskengine.WorkerID) {
+	l.log("closed     %s", wid)
+}
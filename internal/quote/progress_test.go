@@ -0,0 +1,49 @@
+package quote
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2")
+	sis := []*SourceIsins{
+		{Source: "source1", Workers: 1, Isins: []string{"isin1"}},
+		{Source: "source2", Workers: 1, Isins: []string{"isin1", "isin2"}},
+	}
+	var buf bytes.Buffer
+	p := newProgress(&buf, sis)
+	_, err := retrieve(context.Background(), reg, sis, nil, &taskengine.Options{Observer: p})
+	require.NoError(t, err)
+	p.end()
+
+	out := buf.String()
+	require.True(t, strings.HasSuffix(out, "\n"), out)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\r")
+	assert.Equal(t, "2/2 isins, 1 errors, 0 running\x1b[K", lines[len(lines)-1])
+}
+
+func TestEventLog(t *testing.T) {
+	reg := newDummyRegistry(t, "source1")
+	sis := []*SourceIsins{
+		{Source: "source1", Workers: 1, Isins: []string{"isin1"}},
+	}
+	var buf bytes.Buffer
+	_, err := retrieve(context.Background(), reg, sis, nil, &taskengine.Options{Observer: newEventLog(&buf)})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 6, buf.String())
+	assert.Regexp(t, `^\s*\S+\s+assigned\s+isin1 to source1$`, lines[0])
+	assert.Regexp(t, `^\s*\S+\s+started\s+isin1 by source1#0$`, lines[1])
+	assert.Regexp(t, `^\s*\S+\s+finished\s+isin1 by source1#0: \S+ EUR$`, lines[2])
+	assert.Regexp(t, `^\s*\S+\s+completed\s+isin1: ok$`, lines[3])
+	assert.Regexp(t, `^\s*\S+\s+canceled\s+isin1$`, lines[4])
+	assert.Regexp(t, `^\s*\S+\s+closed\s+source1$`, lines[5])
+}
@@ -2,56 +2,154 @@ package quote
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/mmbros/quote/internal/quotegetter"
-	"github.com/mmbros/quote/internal/quotegetter/scrapers"
 	"github.com/mmbros/quote/internal/quotegetterdb"
+	"github.com/mmbros/quote/pkg/decimal"
 	"github.com/mmbros/quote/pkg/taskengine"
 )
 
 // SourceIsins struct represents the isins to get from a specific source
 type SourceIsins struct {
-	Source  string   `json:"source,omitempty"`
-	Workers int      `json:"workers,omitempty"`
-	Proxy   string   `json:"proxy,omitempty"`
-	Isins   []string `json:"isins,omitempty"`
+	Source  string        `json:"source,omitempty"`
+	Workers int           `json:"workers,omitempty"` // if zero, the default workers of the source
+	Proxy   string        `json:"proxy,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"` // timeout of each http request
+	Retries int           `json:"retries,omitempty"` // retries of the transient failures
+	Isins   []string      `json:"isins,omitempty"`
+
+	// Priority is the priority of the source: given equal opportunity,
+	// the sources with higher priority get the isins first,
+	// and their results are preferred.
+	Priority int `json:"priority,omitempty"`
+
+	// Priorities contains the priority of the source for specific isins.
+	// If an isin is not in the map, Priority is used.
+	Priorities map[string]int `json:"priorities,omitempty"`
+
+	// Rate is the rate limit of the requests to the source,
+	// shared by all the workers. If nil, the requests are not limited.
+	Rate *taskengine.RateLimit `json:"rate,omitempty"`
+
+	// Freshness is the policy that decides if the quote of an isin
+	// saved in the database is fresh: the fresh quotes are not retrieved
+	// again, unless GetOptions.Refresh is set. If nil, the quotes are
+	// always retrieved.
+	Freshness *Freshness `json:"freshness,omitempty"`
+
+	// IsinFreshness contains the freshness policy of the source for specific isins.
+	// If an isin is not in the map, Freshness is used.
+	IsinFreshness map[string]*Freshness `json:"isin_freshness,omitempty"`
+}
+
+// priority returns the priority of the source for the isin.
+func (si *SourceIsins) priority(isin string) int {
+	if p, ok := si.Priorities[isin]; ok {
+		return p
+	}
+	return si.Priority
+}
+
+// freshness returns the freshness policy of the source for the isin.
+func (si *SourceIsins) freshness(isin string) *Freshness {
+	if f, ok := si.IsinFreshness[isin]; ok {
+		return f
+	}
+	return si.Freshness
 }
 
 type taskGetQuote struct {
-	isin string
-	url  string
+	isin     string
+	url      string
+	priority int
 }
 
 func (t *taskGetQuote) TaskID() taskengine.TaskID {
 	return taskengine.TaskID(t.isin)
 }
 
-// resultGetQuote.Date field is a pointer in order to omit zero dates.
+func (t *taskGetQuote) Priority() int {
+	return t.priority
+}
+
+// Result is the outcome of the retrieval of the quote of an isin from a source.
+// In case of error, Err is not nil and ErrMsg contains the error message.
+//
+// Result.Date field is a pointer in order to omit zero dates.
 // see https://stackoverflow.com/questions/32643815/json-omitempty-with-time-time-field
+type Result struct {
+	Isin      string          `json:"isin,omitempty"`
+	Source    string          `json:"source,omitempty"`
+	Instance  int             `json:"instance"`
+	URL       string          `json:"url,omitempty"`
+	Price     decimal.Decimal `json:"price,omitempty"`
+	Currency  string          `json:"currency,omitempty"`
+	Date      *time.Time      `json:"date,omitempty"` // need a pointer to omit zero date
+	TimeStart time.Time       `json:"time_start"`
+	TimeEnd   time.Time       `json:"time_end"`
+	ErrMsg    string          `json:"error,omitempty"`
+	Err       error           `json:"-"`
+
+	// Status is set by the engine: the canceled and skipped results
+	// are distinguished from the errors. Reason is the reason
+	// of the cancellation or of the skip.
+	Status taskengine.StatusType `json:"status,omitempty"`
+	Reason string                `json:"reason,omitempty"`
+
+	// Consensus and Dissenting are defined in Quorum mode only:
+	// the agreement of the sources about the quote,
+	// and the sources that returned a different quote.
+	Consensus  string   `json:"consensus,omitempty"`
+	Dissenting []string `json:"dissenting,omitempty"`
+
+	// Cached is true if the quote is a fresh quote read from the database,
+	// instead of being retrieved from the source.
+	Cached bool `json:"cached,omitempty"`
+
+	// staleURL is true if the info url passed to the source is stale
+	// and the isin was searched again without it:
+	// it is deleted from the database, if not replaced by a new one.
+	staleURL bool
+}
 
-type resultGetQuote struct {
-	Isin      string     `json:"isin,omitempty"`
-	Source    string     `json:"source,omitempty"`
-	Instance  int        `json:"instance"`
-	URL       string     `json:"url,omitempty"`
-	Price     float32    `json:"price,omitempty"`
-	Currency  string     `json:"currency,omitempty"`
-	Date      *time.Time `json:"date,omitempty"` // need a pointer to omit zero date
-	TimeStart time.Time  `json:"time_start"`
-	TimeEnd   time.Time  `json:"time_end"`
-	ErrMsg    string     `json:"error,omitempty"`
-	Err       error      `json:"-"`
-}
-
-func (r *resultGetQuote) Success() bool {
+// Success returns true if the quote was successfully retrieved.
+func (r *Result) Success() bool {
 	return r.Err == nil
 }
 
-func (r *resultGetQuote) dbInsert(db *quotegetterdb.QuoteDatabase) error {
+// SetStatus implements the taskengine.StatusSetter interface.
+func (r *Result) SetStatus(status taskengine.StatusType, reason error) {
+	r.Status = status
+	if reason != nil {
+		r.Reason = reason.Error()
+	}
+}
+
+var resultHeader = []string{"ISIN", "SOURCE", "DATE", "PRICE", "CURRENCY", "ERROR"}
+
+func (r *Result) fields() []string {
+	var date, price string
+	if r.Date != nil {
+		date = r.Date.Format("2006-01-02")
+	}
+	if r.Err == nil {
+		price = r.Price.String()
+	}
+	return []string{
+		r.Isin,
+		r.Source,
+		date,
+		price,
+		r.Currency,
+		r.ErrMsg,
+	}
+}
+
+func (r *Result) dbInsert(db *quotegetterdb.QuoteDatabase) error {
 	var qr *quotegetterdb.QuoteRecord
 
 	// assert := func(b bool, label string) {
@@ -63,13 +161,14 @@ func (r *resultGetQuote) dbInsert(db *quotegetterdb.QuoteDatabase) error {
 	// assert(r != nil, "r != nil")
 	// assert(db != nil, "db != nil")
 
-	// skip context.Canceled errors
-	if r.Err != nil {
-		if err, ok := r.Err.(*scrapers.Error); ok {
-			if !errors.Is(err, context.Canceled) {
-				return nil
-			}
-		}
+	// skip the canceled works and the isins not retrieved
+	// because of the open circuit
+	if r.Status == taskengine.Canceled || r.Status == taskengine.Skipped {
+		return nil
+	}
+	// the cached quotes are already saved
+	if r.Cached {
+		return nil
 	}
 	qr = &quotegetterdb.QuoteRecord{
 		Isin:     r.Isin,
@@ -86,31 +185,37 @@ func (r *resultGetQuote) dbInsert(db *quotegetterdb.QuoteDatabase) error {
 	// assert(len(qr.Isin) > 0, "len(qr.Isin) > 0")
 	// assert(len(qr.Source) > 0, "len(qr.Source) > 0")
 
-	// save to database
-	return db.InsertQuotes(qr)
+	// save to database, with the info url of the success results
+	if err := db.InsertQuotes(qr); err != nil {
+		return err
+	}
+	return r.dbInsertInfoURL(db)
 }
 
-func dbInsert(dbpath string, results []*resultGetQuote) error {
+// SaveResults saves the results to the sqlite3 database.
+// If dbpath is empty, nothing is done.
+func SaveResults(dbpath string, results []*Result) error {
 	if len(dbpath) == 0 {
 		return nil
 	}
 
 	// save to database
 	db, err := quotegetterdb.Open(dbpath)
-	if db != nil {
-		defer db.Close()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-		for _, r := range results {
-			err = r.dbInsert(db)
-			if err != nil {
-				return err
-			}
+	for _, r := range results {
+		err = r.dbInsert(db)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func checkListOfSourceIsins(items []*SourceIsins) error {
+func checkListOfSourceIsins(reg *Registry, items []*SourceIsins) error {
 	used := map[string]struct{}{}
 
 	for _, item := range items {
@@ -120,47 +225,208 @@ func checkListOfSourceIsins(items []*SourceIsins) error {
 		}
 		used[item.Source] = struct{}{}
 
-		if _, ok := availableSources[item.Source]; !ok {
+		if reg.lookup(item.Source) == nil {
 			return fmt.Errorf("source %q not available", item.Source)
 		}
-		if item.Workers <= 0 {
+		if item.Workers < 0 {
 			return fmt.Errorf("source %q with invalid workers %d", item.Source, item.Workers)
 		}
+		if item.Timeout < 0 {
+			return fmt.Errorf("source %q with invalid timeout %v", item.Source, item.Timeout)
+		}
+		if item.Retries < 0 {
+			return fmt.Errorf("source %q with invalid retries %d", item.Source, item.Retries)
+		}
 	}
 	return nil
 }
 
-// Get retrieves the quotes specified by the SourceIsins object.
-// The mode parameters specified the taskengine mode of execution.
-// The results quotes are printed in json format.
-// The quotes are also saved to the database, if the dbpath is given.
-func Get(items []*SourceIsins, dbpath string, mode taskengine.Mode) error {
+// GetOptions contains the options of the Get function.
+type GetOptions struct {
+	EngineOptions
+
+	// Database is the sqlite3 database where the quotes are saved.
+	// If empty, the quotes are not saved.
+	Database string
+
+	// Format is the output format: table, csv, json or ndjson.
+	// If empty, json is used.
+	Format string
+
+	// Output is the writer where the results are printed.
+	// If nil, os.Stdout is used.
+	Output io.Writer
+
+	// Deadline is the maximum duration of the whole retrieval.
+	// If zero, no deadline is set.
+	Deadline time.Duration
+
+	// Stream specifies that each result is printed and saved to the database
+	// as soon as it is available, instead of waiting for all the results.
+	// Only the ndjson (default) and csv formats can be streamed.
+	// At the end, a summary is printed to os.Stderr.
+	Stream bool
+
+	// Stats specifies that the statistics of the run are printed to os.Stderr:
+	// the works done by each source with their latency,
+	// and the attempts and the winning source of each isin.
+	Stats bool
+
+	// Progress specifies that a live status line with the isins completed
+	// and the errors is printed to os.Stderr. To be used on a terminal.
+	Progress bool
+
+	// Verbose specifies that each event of the retrieval is logged
+	// to os.Stderr: the works assigned, started, finished and canceled.
+	// It takes precedence over Progress.
+	Verbose bool
+
+	// Refresh specifies that the quotes are retrieved
+	// even if the quotes saved in the database are fresh.
+	// See SourceIsins.Freshness.
+	Refresh bool
+}
+
+func (opts *GetOptions) engineOptions() (*taskengine.Options, error) {
+	engopts, err := opts.TaskEngineOptions()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Stats {
+		engopts.Stats = &taskengine.Stats{}
+	}
+	return engopts, nil
+}
+
+// observer returns the observer of the retrieval, writing to w, and the function
+// to call at the end of the retrieval. The observer is nil if not requested.
+func (opts *GetOptions) observer(items []*SourceIsins, w io.Writer) (taskengine.Observer, func()) {
+	switch {
+	case opts.Verbose:
+		return newEventLog(w), func() {}
+	case opts.Progress:
+		p := newProgress(w, items)
+		return p, p.end
+	}
+	return nil, func() {}
+}
+
+// stored returns the items to retrieve, the results of the fresh quotes
+// and the info urls, read from the database: the fresh quotes are
+// selected by the mode of the engine options. If Refresh is set,
+// all the items are retrieved. In case of error, the error is printed
+// to os.Stderr and the items are retrieved without the database.
+func (opts *GetOptions) stored(db *quotegetterdb.QuoteDatabase, items []*SourceIsins, engopts *taskengine.Options) ([]*SourceIsins, []*Result, infoURLs) {
+	var cached []*Result
+	if !opts.Refresh {
+		toRetrieve, fresh, err := splitFresh(db, items, engopts, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return items, nil, nil
+		}
+		items, cached = toRetrieve, fresh
+	}
+	urls, err := selectInfoURLs(db, items)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return items, cached, urls
+}
 
-	results, err := getResults(items, mode)
+// writeResults writes the results to w in the given format.
+func writeResults(w io.Writer, format string, results []*Result) error {
+	f, err := newFormatter(format, w, resultHeader)
 	if err != nil {
 		return err
 	}
+	for _, r := range results {
+		if err = f.write(r); err != nil {
+			return err
+		}
+	}
+	return f.flush()
+}
 
-	// save to database, if not empty
-	err = dbInsert(dbpath, results)
+// Get retrieves the quotes specified by the SourceIsins object.
+// The results quotes are printed in the format specified by the options.
+// The quotes are also saved to the database, if defined.
+func Get(items []*SourceIsins, opts *GetOptions) error {
+	if opts == nil {
+		opts = &GetOptions{}
+	}
+	if err := CheckFormat(opts.Format); err != nil {
+		return err
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	ctx, cancel := runContext(context.Background(), opts.Deadline)
+	defer cancel()
+
+	if opts.Stream {
+		return getStream(ctx, items, opts, out)
+	}
+
+	engopts, err := opts.engineOptions()
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	var (
+		cached []*Result
+		urls   infoURLs
+	)
+	if len(opts.Database) > 0 {
+		db, err := quotegetterdb.Open(opts.Database)
+		if err != nil {
+			// go on without the quotes and the info urls of the database
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			items, cached, urls = opts.stored(db, items, engopts)
+			db.Close()
+		}
 	}
 
-	json, err := json.MarshalIndent(results, "", " ")
+	obs, end := opts.observer(items, os.Stderr)
+	engopts.Observer = obs
+	results, err := retrieve(ctx, opts.SourceRegistry(), items, urls, engopts)
+	end()
 	if err != nil {
 		return err
 	}
+	results = append(cached, results...)
+
+	// save to database, if not empty
+	err = SaveResults(opts.Database, results)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 
-	fmt.Println(string(json))
+	if engopts.Stats != nil {
+		writeStats(os.Stderr, engopts.Stats)
+	}
 
-	return nil
+	return writeResults(out, opts.Format, results)
+}
+
+// runContext returns the context of the retrieval derived from parent,
+// with the deadline if greater than zero.
+func runContext(parent context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	if deadline > 0 {
+		return context.WithTimeout(parent, deadline)
+	}
+	return context.WithCancel(parent)
 }
 
-func getResults(items []*SourceIsins, mode taskengine.Mode) ([]*resultGetQuote, error) {
+// execute starts the retrieval of the quotes specified by the SourceIsins object.
+// It returns the channel that receives the results as soon as they are available.
+// The info urls in urls are passed to the quote getters, in order to skip
+// the search of the isins: if an info url is stale, the isin is searched again.
+func execute(ctx context.Context, reg *Registry, items []*SourceIsins, urls infoURLs, opts *taskengine.Options) (chan taskengine.Result, error) {
 
 	// check input
-	if err := checkListOfSourceIsins(items); err != nil {
+	if err := checkListOfSourceIsins(reg, items); err != nil {
 		return nil, err
 	}
 
@@ -170,79 +436,278 @@ func getResults(items []*SourceIsins, mode taskengine.Mode) ([]*resultGetQuote,
 	// WorkerTasks
 	wts := make(taskengine.WorkerTasks)
 
-	quoteGetter, err := initQuoteGetters(items)
+	quoteGetter, err := initQuoteGetters(reg, items)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, item := range items {
 
-		qg := quoteGetter[item.Source]
-
-		// work function of the source
-		wfn := func(ctx context.Context, inst int, task taskengine.Task) taskengine.Result {
-			t := task.(*taskGetQuote)
-			time1 := time.Now()
-			res, err := qg.GetQuote(ctx, t.isin, t.url)
-			time2 := time.Now()
-
-			r := &resultGetQuote{
-				Instance:  inst,
-				TimeStart: time1,
-				TimeEnd:   time2,
-				Err:       err,
-			}
-			if res != nil {
-				r.Isin = res.Isin
-				r.Source = res.Source
-				r.Price = res.Price
-				r.Currency = res.Currency
-				r.URL = res.URL
-				if !res.Date.IsZero() {
-					r.Date = &res.Date
-				}
-			}
-			if err != nil {
-				r.ErrMsg = err.Error()
-				if e, ok := err.(quotegetter.Error); ok {
-					r.Isin = e.Isin()
-					r.Source = e.Source()
-					r.URL = e.URL()
-				}
-			}
-			return r
-		}
-
 		// worker
-		w := &taskengine.Worker{
-			WorkerID:  taskengine.WorkerID(item.Source),
-			Instances: item.Workers,
-			Work:      wfn,
+		w, err := newWorker(reg, item, quoteGetter[item.Source])
+		if err != nil {
+			return nil, err
 		}
 		ws = append(ws, w)
 
 		// Tasks
 		ts := make(taskengine.Tasks, 0, len(item.Isins))
 		for _, isin := range item.Isins {
-			ts = append(ts, &taskGetQuote{
-				isin: isin,
-				url:  "",
-			})
+			ts = append(ts, newTask(item, isin, urls))
 		}
 		wts[w.WorkerID] = ts
 
 	}
 
-	resChan, err := taskengine.Execute(context.Background(), ws, wts, mode)
+	return taskengine.ExecuteWithOptions(ctx, ws, wts, opts)
+}
+
+// newTask returns the task of the retrieval of the isin from the source of item.
+func newTask(item *SourceIsins, isin string, urls infoURLs) *taskGetQuote {
+	return &taskGetQuote{
+		isin:     isin,
+		url:      urls.get(item.Source, isin),
+		priority: item.priority(isin),
+	}
+}
+
+// newWorker returns the worker of the source of item, using the quote getter qg.
+func newWorker(reg *Registry, item *SourceIsins, qg quotegetter.QuoteGetter) (*taskengine.Worker, error) {
+	rate, err := reg.limiter(item.Source, item.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	// work function of the source
+	wfn := func(ctx context.Context, inst int, task taskengine.Task) taskengine.Result {
+		t := task.(*taskGetQuote)
+		time1 := time.Now()
+		res, err := qg.GetQuote(ctx, t.isin, t.url)
+		stale := false
+		if err != nil && t.url != "" && ctx.Err() == nil && staleInfoURL(err) && rate.Wait(ctx) == nil {
+			// the cached info url is stale: search the isin again,
+			// within the rate limit of the source.
+			// It is deleted only if the search is done.
+			res, err = qg.GetQuote(ctx, t.isin, "")
+			stale = true
+		}
+		time2 := time.Now()
+
+		r := &Result{
+			Instance:  inst,
+			TimeStart: time1,
+			TimeEnd:   time2,
+			Err:       err,
+			staleURL:  stale,
+		}
+		if res != nil {
+			r.Isin = res.Isin
+			r.Source = res.Source
+			r.Price = res.Price
+			r.Currency = res.Currency
+			r.URL = res.URL
+			if !res.Date.IsZero() {
+				r.Date = &res.Date
+			}
+		}
+		if err != nil {
+			r.ErrMsg = err.Error()
+			if e, ok := err.(quotegetter.Error); ok {
+				r.Isin = e.Isin()
+				r.Source = e.Source()
+				r.URL = e.URL()
+			}
+		}
+		return r
+	}
+
+	return &taskengine.Worker{
+		WorkerID:  taskengine.WorkerID(item.Source),
+		Instances: reg.lookup(item.Source).workers(item.Workers),
+		Work:      wfn,
+		Rate:      rate,
+	}, nil
+}
+
+// Retrieve retrieves the quotes specified by the SourceIsins object
+// and returns the results when all of them are available.
+// The retrieval is stopped when the context is done.
+// The sources of DefaultRegistry are used.
+func Retrieve(ctx context.Context, items []*SourceIsins, opts *taskengine.Options) ([]*Result, error) {
+	return DefaultRegistry.Retrieve(ctx, items, opts)
+}
+
+// Retrieve is like the Retrieve function, but it uses the sources of the registry.
+func (r *Registry) Retrieve(ctx context.Context, items []*SourceIsins, opts *taskengine.Options) ([]*Result, error) {
+	return retrieve(ctx, r, items, nil, opts)
+}
+
+func retrieve(ctx context.Context, reg *Registry, items []*SourceIsins, urls infoURLs, opts *taskengine.Options) ([]*Result, error) {
+	resChan, err := execute(ctx, reg, items, urls, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	results := []*resultGetQuote{}
+	results := []*Result{}
 	for r := range resChan {
-		res := r.(*resultGetQuote)
-		results = append(results, res)
+		results = append(results, toResult(r))
 	}
 
 	return results, nil
 }
+
+// toResult returns the Result of the taskengine result.
+// The tasks skipped or canceled by the engine before the work
+// are converted to error results.
+func toResult(r taskengine.Result) *Result {
+	switch r := r.(type) {
+	case *taskengine.SkippedResult:
+		return &Result{
+			Isin:   string(r.Task.TaskID()),
+			Source: string(r.WorkerID),
+			Err:    r.Err,
+			ErrMsg: r.Err.Error(),
+			Status: taskengine.Skipped,
+			Reason: r.Err.Error(),
+		}
+	case *taskengine.CanceledResult:
+		return &Result{
+			Isin:   string(r.Task.TaskID()),
+			Source: string(r.WorkerID),
+			Err:    r.Err,
+			ErrMsg: r.Err.Error(),
+			Status: taskengine.Canceled,
+			Reason: r.Err.Error(),
+		}
+	case *taskengine.QuorumResult:
+		// the results have not been reconciled by the engine
+		return Reconciler(0)(r.TaskID, r.Results).(*Result)
+	}
+	return r.(*Result)
+}
+
+// summary contains the totals of the results of a Get execution.
+type summary struct {
+	results int
+	cached  int
+	status  map[taskengine.StatusType]int // results of each status
+	isins   map[string]struct{}
+	start   time.Time
+}
+
+func newSummary() *summary {
+	return &summary{
+		status: map[taskengine.StatusType]int{},
+		isins:  map[string]struct{}{},
+		start:  time.Now(),
+	}
+}
+
+func (s *summary) add(r *Result) {
+	s.results++
+	if r.Cached {
+		s.cached++
+	}
+	s.status[r.Status]++
+	s.isins[r.Isin] = struct{}{}
+}
+
+// String returns the summary. The canceled, skipped and cached results
+// are shown only if any.
+func (s *summary) String() string {
+	counts := fmt.Sprintf("%d success, %d errors", s.status[taskengine.Success], s.status[taskengine.Error])
+	for _, st := range []taskengine.StatusType{taskengine.Canceled, taskengine.Skipped} {
+		if n := s.status[st]; n > 0 {
+			counts += fmt.Sprintf(", %d %v", n, st)
+		}
+	}
+	if s.cached > 0 {
+		counts += fmt.Sprintf(", %d cached", s.cached)
+	}
+	return fmt.Sprintf("%d results (%s) for %d isins in %v",
+		s.results, counts, len(s.isins),
+		time.Since(s.start).Round(time.Millisecond))
+}
+
+// getStream retrieves the quotes and, as soon as each result is available,
+// saves it to the database and writes it to out.
+func getStream(ctx context.Context, items []*SourceIsins, opts *GetOptions, out io.Writer) error {
+	format, err := streamFormat(opts.Format)
+	if err != nil {
+		return err
+	}
+	f, err := newFormatter(format, out, resultHeader)
+	if err != nil {
+		return err
+	}
+
+	engopts, err := opts.engineOptions()
+	if err != nil {
+		return err
+	}
+
+	var (
+		db     *quotegetterdb.QuoteDatabase
+		cached []*Result
+		urls   infoURLs
+	)
+	if len(opts.Database) > 0 {
+		db, err = quotegetterdb.Open(opts.Database)
+		if err != nil {
+			// go on without saving the results
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			items, cached, urls = opts.stored(db, items, engopts)
+		}
+		defer db.Close()
+	}
+
+	sum := newSummary()
+
+	obs, end := opts.observer(items, os.Stderr)
+	engopts.Observer = obs
+	resChan, err := execute(ctx, opts.SourceRegistry(), items, urls, engopts)
+	if err != nil {
+		return err
+	}
+
+	var errWrite error
+	for _, r := range cached {
+		sum.add(r)
+		if errWrite == nil {
+			errWrite = f.write(r)
+		}
+	}
+	if errWrite == nil && len(cached) > 0 {
+		errWrite = f.flush()
+	}
+	for res := range resChan {
+		r := toResult(res)
+		sum.add(r)
+
+		if db != nil {
+			if err := r.dbInsert(db); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		// in case of write error, the results channel is drained anyway
+		// to let the engine terminate.
+		if errWrite == nil {
+			errWrite = f.write(r)
+		}
+		if errWrite == nil {
+			errWrite = f.flush()
+		}
+	}
+	end()
+	if errWrite != nil {
+		return errWrite
+	}
+
+	fmt.Fprintln(os.Stderr, sum)
+	if engopts.Stats != nil {
+		writeStats(os.Stderr, engopts.Stats)
+	}
+	return nil
+}
@@ -1,16 +1,22 @@
 package quote
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/decimal"
 	"github.com/mmbros/quote/pkg/taskengine"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // String returns a json string representation of the object.
@@ -35,8 +41,9 @@ func (qg *dummyQuoteGetter) Client() *http.Client { return qg.client }
 func (qg *dummyQuoteGetter) GetQuote(ctx context.Context, isin, url string) (*quotegetter.Result, error) {
 
 	cases := map[string]*struct {
-		err  bool
-		wait int
+		err   bool
+		wait  int
+		price decimal.Decimal // if empty, 12.35
 	}{
 		"source1-isin1": {
 			err:  false,
@@ -46,6 +53,15 @@ func (qg *dummyQuoteGetter) GetQuote(ctx context.Context, isin, url string) (*qu
 			err:  true,
 			wait: 20,
 		},
+		"source3-isin1": {
+			err:  false,
+			wait: 40,
+		},
+		"source4-isin1": {
+			err:   false,
+			wait:  30,
+			price: "13.5",
+		},
 	}
 	key := qg.source + "-" + isin
 	c := cases[key]
@@ -55,7 +71,11 @@ func (qg *dummyQuoteGetter) GetQuote(ctx context.Context, isin, url string) (*qu
 	}
 
 	if c.wait > 0 {
-		time.Sleep(time.Duration(c.wait) * time.Millisecond)
+		select {
+		case <-time.After(time.Duration(c.wait) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, quotegetter.NewError(qg.source, isin, url, ctx.Err())
+		}
 	}
 	if c.err {
 		return nil, quotegetter.NewError(qg.source, isin, url, fmt.Errorf("generic error"))
@@ -65,16 +85,27 @@ func (qg *dummyQuoteGetter) GetQuote(ctx context.Context, isin, url string) (*qu
 		Isin:     isin,
 		Date:     time.Now(),
 		Currency: "EUR",
-		Price:    12.35,
+		Price:    "12.35",
+	}
+	if c.price != "" {
+		res.Price = c.price
 	}
 	return res, nil
 }
 
-func TestCheckListOfSourceIsins(t *testing.T) {
-	availableSources = map[string]fnNewQuoteGetter{
-		"source1": newDummyQuoteGetter,
-		"source2": newDummyQuoteGetter,
+// newDummyRegistry returns a new registry with the given sources,
+// each one using a dummyQuoteGetter.
+func newDummyRegistry(t *testing.T, names ...string) *Registry {
+	reg := NewRegistry()
+	for _, name := range names {
+		err := reg.Register(name, &SourceFactory{New: newDummyQuoteGetter})
+		require.NoError(t, err)
 	}
+	return reg
+}
+
+func TestCheckListOfSourceIsins(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2")
 
 	cases := []struct {
 		input  []*SourceIsins
@@ -115,6 +146,28 @@ func TestCheckListOfSourceIsins(t *testing.T) {
 			},
 			errmsg: "invalid workers",
 		},
+		{
+			input: []*SourceIsins{
+				{
+					Source:  "source1",
+					Workers: 1,
+					Timeout: -time.Second,
+					Isins:   []string{"isin1"},
+				},
+			},
+			errmsg: "invalid timeout",
+		},
+		{
+			input: []*SourceIsins{
+				{
+					Source:  "source1",
+					Workers: 1,
+					Retries: -1,
+					Isins:   []string{"isin1"},
+				},
+			},
+			errmsg: "invalid retries",
+		},
 		{
 			input: []*SourceIsins{
 				{
@@ -138,7 +191,7 @@ func TestCheckListOfSourceIsins(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		err := checkListOfSourceIsins(c.input)
+		err := checkListOfSourceIsins(reg, c.input)
 		if c.errmsg == "" {
 			assert.NoError(t, err)
 		} else {
@@ -150,10 +203,7 @@ func TestCheckListOfSourceIsins(t *testing.T) {
 }
 
 func TestGetResults(t *testing.T) {
-	availableSources = map[string]fnNewQuoteGetter{
-		"source1": newDummyQuoteGetter,
-		"source2": newDummyQuoteGetter,
-	}
+	reg := newDummyRegistry(t, "source1", "source2")
 	sis := []*SourceIsins{
 		{
 			Source:  "source1",
@@ -166,10 +216,161 @@ func TestGetResults(t *testing.T) {
 			Isins:   []string{"isin1", "isin2"},
 		},
 	}
-	res, err := getResults(sis, taskengine.All)
+	res, err := retrieve(context.Background(), reg, sis, nil, &taskengine.Options{Mode: taskengine.All})
 	if assert.NoError(t, err) {
 		assert.Equal(t, 3, len(res))
 		// t.Fatalf("res %v", jsonString(res))
 	}
 
+	status := map[string]*Result{}
+	for _, r := range res {
+		status[r.Source+"-"+r.Isin] = r
+	}
+	assert.Equal(t, taskengine.Success, status["source1-isin1"].Status)
+	assert.Equal(t, taskengine.Error, status["source2-isin2"].Status)
+
+	// the error of source2 for isin1 usually arrives
+	// after the success of source1
+	if r := status["source2-isin1"]; r.Status == taskengine.Canceled {
+		assert.Equal(t, "canceled: task succeeded", r.Reason)
+	} else {
+		assert.Equal(t, taskengine.Error, r.Status)
+	}
+}
+
+func TestSaveResultsStatus(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	errGeneric := errors.New("generic error")
+
+	results := []*Result{
+		{Isin: "isin1", Source: "source1", Price: "12.35", Currency: "EUR", Status: taskengine.Success},
+		{Isin: "isin1", Source: "source2", Err: errGeneric, ErrMsg: "generic error", Status: taskengine.Error},
+		{Isin: "isin1", Source: "source3", Err: context.Canceled, ErrMsg: "context canceled", Status: taskengine.Canceled},
+		{Isin: "isin1", Source: "source4", Err: taskengine.ErrCircuitOpen, ErrMsg: "skipped: circuit open", Status: taskengine.Skipped},
+	}
+	require.NoError(t, SaveResults(dbpath, results))
+
+	// the canceled and skipped results are not saved
+	items, err := selectHistory(dbpath, nil)
+	require.NoError(t, err)
+	sources := []string{}
+	for _, item := range items {
+		sources = append(sources, item.Source)
+	}
+	assert.ElementsMatch(t, []string{"source1", "source2"}, sources)
+}
+
+func TestGetPriority(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source3")
+
+	cases := map[string]struct {
+		priority   int
+		priorities map[string]int
+		window     time.Duration
+		want       string
+	}{
+		"no priority":         {0, nil, 200 * time.Millisecond, "source1"},
+		"priority":            {1, nil, 200 * time.Millisecond, "source3"},
+		"priority no window":  {1, nil, 0, "source1"},
+		"priority of isin":    {0, map[string]int{"isin1": 1}, 200 * time.Millisecond, "source3"},
+		"isin overrides item": {1, map[string]int{"isin1": -1}, 200 * time.Millisecond, "source1"},
+	}
+
+	for title, c := range cases {
+		// source1 is faster than source3
+		sis := []*SourceIsins{
+			{Source: "source1", Workers: 1, Isins: []string{"isin1"}},
+			{Source: "source3", Workers: 1, Isins: []string{"isin1"}, Priority: c.priority, Priorities: c.priorities},
+		}
+		opts := &taskengine.Options{
+			Mode:           taskengine.FirstSuccessOrLastError,
+			PriorityWindow: c.window,
+		}
+		res, err := retrieve(context.Background(), reg, sis, nil, opts)
+		if assert.NoError(t, err, title) && assert.Len(t, res, 1, title) {
+			assert.Equal(t, c.want, res[0].Source, title)
+		}
+	}
+}
+
+func TestGetCircuit(t *testing.T) {
+	reg := newDummyRegistry(t, "source2")
+	sis := []*SourceIsins{
+		{Source: "source2", Workers: 1, Isins: []string{"isin1", "isin2", "isin3", "isin4"}},
+	}
+	opts := &GetOptions{EngineOptions: EngineOptions{Mode: taskengine.All, CircuitThreshold: 2}}
+	engopts, err := opts.engineOptions()
+	require.NoError(t, err)
+
+	res, err := retrieve(context.Background(), reg, sis, nil, engopts)
+	require.NoError(t, err)
+	require.Len(t, res, 4)
+
+	skipped := 0
+	for _, r := range res {
+		assert.Equal(t, "source2", r.Source)
+		assert.False(t, r.Success())
+		if r.Status == taskengine.Skipped {
+			assert.Equal(t, "skipped: circuit open", r.Reason)
+			skipped++
+		}
+	}
+	assert.Equal(t, 2, skipped)
+
+	_, err = (&GetOptions{EngineOptions: EngineOptions{CircuitThreshold: -1}}).engineOptions()
+	assert.Error(t, err)
+}
+
+func TestGetStream(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2")
+	sis := []*SourceIsins{
+		{
+			Source:  "source1",
+			Workers: 1,
+			Isins:   []string{"isin1"},
+		},
+		{
+			Source:  "source2",
+			Workers: 2,
+			Isins:   []string{"isin1", "isin2"},
+		},
+	}
+
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	var buf bytes.Buffer
+
+	opts := &GetOptions{
+		EngineOptions: EngineOptions{Mode: taskengine.All, Registry: reg},
+		Database:      dbpath,
+		Output:        &buf,
+		Stream:        true,
+	}
+	err := Get(sis, opts)
+	require.NoError(t, err)
+
+	// one json object for each line
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Equal(t, 3, len(lines)) {
+		for _, line := range lines {
+			var r Result
+			assert.NoError(t, json.Unmarshal([]byte(line), &r), line)
+		}
+	}
+
+	// the results are saved in the database
+	items, err := selectHistory(dbpath, nil)
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, items)
+	}
+}
+
+func TestGetStreamInvalidFormat(t *testing.T) {
+	opts := &GetOptions{
+		Format: FormatTable,
+		Stream: true,
+	}
+	err := Get(nil, opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot be streamed")
+	}
 }
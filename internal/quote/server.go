@@ -0,0 +1,212 @@
+package quote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetterdb"
+)
+
+// ResolveFunc returns the sources and isins to retrieve
+// given the isins and the sources of a request.
+// If sources is empty, the enabled sources are used.
+type ResolveFunc func(isins, sources []string) ([]*SourceIsins, error)
+
+// ServerOptions contains the options of the http handler.
+type ServerOptions struct {
+	EngineOptions
+
+	// Resolve returns the sources and isins to retrieve. Mandatory.
+	Resolve ResolveFunc
+
+	// Sources contains the settings of the sources that can be requested:
+	// the workers of each source are shared by all the requests.
+	// The isins are not used. If empty, the sources of the registry
+	// are used with the default settings.
+	Sources []*SourceIsins
+
+	// Database is the sqlite3 database where the quotes are saved
+	// and read from. If empty, the quotes are not saved and
+	// the history is not available.
+	Database string
+
+	// Deadline is the maximum duration of the whole retrieval
+	// of the quotes of each /quotes request.
+	// If zero, no deadline is set.
+	Deadline time.Duration
+}
+
+// server is the http handler of the quote service.
+type server struct {
+	opts *ServerOptions
+	mux  *http.ServeMux
+	pool *sourcePool
+}
+
+// NewHandler returns the http handler of the quote service:
+//
+//	GET /quotes?isin=...&source=...   retrieves the quotes of the isins
+//	GET /history?isin=...&source=...&from=...&to=...&status=...
+//	                                  returns the quotes saved in the database
+//	GET /sources                      returns the available sources
+//
+// The isin and source parameters can be repeated or contain comma separated values.
+// The responses are json documents; in case of error the document is {"error": "message"}.
+func NewHandler(opts *ServerOptions) (http.Handler, error) {
+	if opts == nil || opts.Resolve == nil {
+		return nil, errors.New("resolve function not defined")
+	}
+	if opts.Registry == nil {
+		o := *opts
+		o.Registry = DefaultRegistry
+		opts = &o
+	}
+
+	sources := opts.Sources
+	if len(sources) == 0 {
+		for _, name := range opts.Registry.Names() {
+			sources = append(sources, &SourceIsins{Source: name})
+		}
+	}
+	engopts, err := opts.TaskEngineOptions()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newSourcePool(opts.Registry, sources, engopts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &server{opts: opts, mux: http.NewServeMux(), pool: pool}
+	s.mux.HandleFunc("/quotes", s.handleQuotes)
+	s.mux.HandleFunc("/history", s.handleHistory)
+	s.mux.HandleFunc("/sources", s.handleSources)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("path %q not found", r.URL.Path))
+	})
+	return s, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON writes the object as a json response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+// writeJSONError writes the error as a json response with the given status code.
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// queryValues returns the values of the query parameter.
+// Each value can contain a comma separated list of values.
+func queryValues(r *http.Request, key string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[key] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// queryDate returns the date of the query parameter in "YYYY-MM-DD" format.
+// A missing parameter returns the zero date.
+func queryDate(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s date %q: expected format is YYYY-MM-DD", key, value)
+	}
+	return t, nil
+}
+
+func (s *server) handleQuotes(w http.ResponseWriter, r *http.Request) {
+	isins := queryValues(r, "isin")
+	if len(isins) == 0 {
+		writeJSONError(w, http.StatusBadRequest, errors.New("isin parameter not defined"))
+		return
+	}
+
+	items, err := s.opts.Resolve(isins, queryValues(r, "source"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := runContext(r.Context(), s.opts.Deadline)
+	defer cancel()
+
+	// the quotes are retrieved even if the info urls cannot be loaded
+	urls, err := loadInfoURLs(s.opts.Database, items)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	results, err := s.pool.retrieve(ctx, items, urls)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the results are returned even if they cannot be saved
+	if err = SaveResults(s.opts.Database, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	if s.opts.Database == "" {
+		writeJSONError(w, http.StatusServiceUnavailable, errors.New("database not defined"))
+		return
+	}
+
+	flt := &quotegetterdb.QuoteFilter{
+		Isins:   queryValues(r, "isin"),
+		Sources: queryValues(r, "source"),
+	}
+	if flt.DateFrom, err = queryDate(r, "from"); err == nil {
+		if flt.DateTo, err = queryDate(r, "to"); err == nil {
+			flt.Status, err = quotegetterdb.ParseStatus(r.URL.Query().Get("status"))
+		}
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := selectHistory(s.opts.Database, flt)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) handleSources(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.opts.Registry.Infos())
+}
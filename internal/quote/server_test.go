@@ -0,0 +1,174 @@
+package quote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testResolve uses source1 and source2 for each isin,
+// unless the sources are passed.
+func testResolve(isins, sources []string) ([]*SourceIsins, error) {
+	if len(sources) == 0 {
+		sources = []string{"source1", "source2"}
+	}
+	items := make([]*SourceIsins, 0, len(sources))
+	for _, s := range sources {
+		if s != "source1" && s != "source2" {
+			return nil, fmt.Errorf("required source %q is not available", s)
+		}
+		items = append(items, &SourceIsins{Source: s, Isins: isins})
+	}
+	return items, nil
+}
+
+func TestServer(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+
+	h, err := NewHandler(&ServerOptions{
+		EngineOptions: EngineOptions{Mode: taskengine.All, Registry: newDummyRegistry(t, "source1", "source2")},
+		Resolve:       testResolve,
+		Database:      dbpath,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	cases := []struct {
+		title  string
+		method string
+		path   string
+		code   int
+		count  int
+		errmsg string
+	}{
+		{
+			title: "quotes",
+			path:  "/quotes?isin=isin1",
+			code:  http.StatusOK,
+			count: 2,
+		},
+		{
+			title: "quotes of a source",
+			path:  "/quotes?isin=isin1,isin2&source=source1",
+			code:  http.StatusOK,
+			count: 2,
+		},
+		{
+			title:  "quotes without isin",
+			path:   "/quotes?source=source1",
+			code:   http.StatusBadRequest,
+			errmsg: "isin parameter not defined",
+		},
+		{
+			title:  "quotes of unknown source",
+			path:   "/quotes?isin=isin1&source=sourceX",
+			code:   http.StatusBadRequest,
+			errmsg: "not available",
+		},
+		{
+			// the canceled work of source2 for isin1 is not saved
+			title: "history",
+			path:  "/history",
+			code:  http.StatusOK,
+			count: 3,
+		},
+		{
+			title: "history of a source",
+			path:  "/history?source=source1&isin=isin1&status=ok",
+			code:  http.StatusOK,
+			count: 2,
+		},
+		{
+			title: "history with dates",
+			path:  "/history?from=2000-01-01&to=2000-12-31",
+			code:  http.StatusOK,
+			count: 0,
+		},
+		{
+			title:  "history with invalid date",
+			path:   "/history?from=01/01/2000",
+			code:   http.StatusBadRequest,
+			errmsg: "invalid from date",
+		},
+		{
+			title:  "history with invalid status",
+			path:   "/history?status=xxx",
+			code:   http.StatusBadRequest,
+			errmsg: "invalid status",
+		},
+		{
+			title: "sources",
+			path:  "/sources",
+			code:  http.StatusOK,
+			count: 2,
+		},
+		{
+			title:  "not found",
+			path:   "/unknown",
+			code:   http.StatusNotFound,
+			errmsg: "not found",
+		},
+		{
+			title:  "method not allowed",
+			method: http.MethodPost,
+			path:   "/quotes?isin=isin1",
+			code:   http.StatusMethodNotAllowed,
+			errmsg: "not allowed",
+		},
+	}
+
+	// NOTE: the cases are executed in order, because history
+	// depends on the quotes retrieved by the previous cases.
+	for _, c := range cases {
+		method := c.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req, err := http.NewRequest(method, ts.URL+c.path, nil)
+		require.NoError(t, err, c.title)
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err, c.title)
+
+		assert.Equal(t, c.code, resp.StatusCode, c.title)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), c.title)
+
+		if c.errmsg != "" {
+			var body map[string]string
+			if assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body), c.title) {
+				assert.Contains(t, body["error"], c.errmsg, c.title)
+			}
+		} else {
+			var body []map[string]interface{}
+			if assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body), c.title) {
+				assert.Equal(t, c.count, len(body), c.title)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestServerWithoutDatabase(t *testing.T) {
+	h, err := NewHandler(&ServerOptions{
+		EngineOptions: EngineOptions{Registry: newDummyRegistry(t, "source1", "source2")},
+		Resolve:       testResolve,
+	})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "database not defined"))
+
+	_, err = NewHandler(&ServerOptions{})
+	assert.Error(t, err)
+}
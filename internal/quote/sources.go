@@ -1,79 +1,306 @@
 package quote
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mmbros/quote/internal/quotegetter"
 	"github.com/mmbros/quote/internal/quotegetter/jsons/cryptonatorcom"
 	"github.com/mmbros/quote/internal/quotegetter/scrapers/fondidocit"
 	"github.com/mmbros/quote/internal/quotegetter/scrapers/fundsquarenet"
 	"github.com/mmbros/quote/internal/quotegetter/scrapers/morningstarit"
+	"github.com/mmbros/quote/pkg/taskengine"
 )
 
-type fnNewQuoteGetter func(string, *http.Client) quotegetter.QuoteGetter
+// Asset kinds supported by the sources.
+const (
+	KindFund   = "fund"
+	KindETF    = "etf"
+	KindStock  = "stock"
+	KindCrypto = "crypto"
+)
+
+// NewQuoteGetterFunc is the function that creates the QuoteGetter of a source,
+// given the name of the source and the http client to use.
+type NewQuoteGetterFunc func(name string, client *http.Client) quotegetter.QuoteGetter
+
+// SourceFactory creates the QuoteGetter of a source
+// and contains the metadata of the source.
+type SourceFactory struct {
+	// New creates the QuoteGetter of the source. Mandatory.
+	New NewQuoteGetterFunc
+
+	// Kinds is the list of the asset kinds supported by the source.
+	Kinds []string
+
+	// Workers is the default number of workers of the source.
+	// If zero, a single worker is used.
+	Workers int
+
+	// BaseURL is the url of the site of the source.
+	BaseURL string
+}
+
+// workers returns n, if greater than zero,
+// or the default number of workers of the source.
+func (f *SourceFactory) workers(n int) int {
+	if n > 0 {
+		return n
+	}
+	if f.Workers > 0 {
+		return f.Workers
+	}
+	return 1
+}
+
+// SourceInfo contains the metadata of a registered source.
+type SourceInfo struct {
+	Name    string   `json:"name"`
+	Kinds   []string `json:"kinds,omitempty"`
+	Workers int      `json:"workers"`
+	BaseURL string   `json:"base_url,omitempty"`
+}
+
+var sourceInfoHeader = []string{"SOURCE", "KINDS", "WORKERS", "BASE URL"}
+
+func (si *SourceInfo) fields() []string {
+	return []string{
+		si.Name,
+		strings.Join(si.Kinds, ","),
+		strconv.Itoa(si.Workers),
+		si.BaseURL,
+	}
+}
+
+// Registry contains the available sources.
+// It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	sources  map[string]*SourceFactory
+	limiters map[string]*taskengine.RateLimiter // rate limiter of each source
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		sources:  map[string]*SourceFactory{},
+		limiters: map[string]*taskengine.RateLimiter{},
+	}
+}
+
+// Register adds a new source to the registry.
+// It returns an error if a source with the same name already exists.
+func (r *Registry) Register(name string, factory *SourceFactory) error {
+	if name == "" {
+		return fmt.Errorf("source name not defined")
+	}
+	if factory == nil || factory.New == nil {
+		return fmt.Errorf("source %q: function not defined", name)
+	}
+	if factory.Workers < 0 {
+		return fmt.Errorf("source %q with invalid workers %d", name, factory.Workers)
+	}
 
-var availableSources map[string]fnNewQuoteGetter
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[name]; ok {
+		return fmt.Errorf("source %q already registered", name)
+	}
+	// copy the factory to prevent changes after registration
+	f := *factory
+	f.Kinds = append([]string(nil), factory.Kinds...)
+	r.sources[name] = &f
+	return nil
+}
+
+// lookup returns the factory of the source, or nil if not found.
+func (r *Registry) lookup(name string) *SourceFactory {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sources[name]
+}
+
+// limiter returns the rate limiter of the source enforcing the rate limit.
+// The same limiter is returned while the rate limit of the source
+// doesn't change, so that all the retrievals share the same budget.
+// It returns nil if rl is nil.
+func (r *Registry) limiter(name string, rl *taskengine.RateLimit) (*taskengine.RateLimiter, error) {
+	if rl == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l := r.limiters[name]; l != nil && l.Limit() == *rl {
+		return l, nil
+	}
+	l, err := taskengine.NewRateLimiter(*rl)
+	if err != nil {
+		return nil, fmt.Errorf("source %q: %v", name, err)
+	}
+	r.limiters[name] = l
+	return l, nil
+}
+
+// Names returns the sorted list of the names of the sources.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	list := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		list = append(list, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(list)
+	return list
+}
+
+// Infos returns the metadata of the sources, sorted by name.
+func (r *Registry) Infos() []*SourceInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*SourceInfo, 0, len(r.sources))
+	for name, f := range r.sources {
+		list = append(list, &SourceInfo{
+			Name:    name,
+			Kinds:   append([]string(nil), f.Kinds...),
+			Workers: f.workers(0),
+			BaseURL: f.BaseURL,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// DefaultRegistry is the registry of the built-in sources.
+// It is used by the Get and Retrieve functions.
+var DefaultRegistry = NewRegistry()
 
 func init() {
 
-	fnCryptonatorcom := func(currency string) fnNewQuoteGetter {
+	fnCryptonatorcom := func(currency string) NewQuoteGetterFunc {
 		return func(name string, client *http.Client) quotegetter.QuoteGetter {
 			return cryptonatorcom.NewQuoteGetter(name, client, currency)
 		}
 	}
 
-	availableSources = map[string]fnNewQuoteGetter{
-		"fondidocit":         fondidocit.NewQuoteGetter,
-		"morningstarit":      morningstarit.NewQuoteGetter,
-		"fundsquarenet":      fundsquarenet.NewQuoteGetter,
-		"cryptonatorcom-EUR": fnCryptonatorcom("EUR"),
-		// "cryptonatorcom-USD": fnCryptonatorcom("USD"),
+	builtins := map[string]*SourceFactory{
+		"fondidocit": {
+			New:     fondidocit.NewQuoteGetter,
+			Kinds:   []string{KindFund},
+			BaseURL: "https://www.fondidoc.it",
+		},
+		"morningstarit": {
+			New:     morningstarit.NewQuoteGetter,
+			Kinds:   []string{KindFund, KindETF},
+			BaseURL: "https://www.morningstar.it",
+		},
+		"fundsquarenet": {
+			New:     fundsquarenet.NewQuoteGetter,
+			Kinds:   []string{KindFund},
+			BaseURL: "https://www.fundsquare.net",
+		},
+		"cryptonatorcom-EUR": {
+			New:     fnCryptonatorcom("EUR"),
+			Kinds:   []string{KindCrypto},
+			BaseURL: "https://api.cryptonator.com",
+		},
+		// "cryptonatorcom-USD": ...
 	}
 
+	for name, f := range builtins {
+		if err := DefaultRegistry.Register(name, f); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds a new source to the default registry.
+// It returns an error if a source with the same name already exists.
+func Register(name string, factory *SourceFactory) error {
+	return DefaultRegistry.Register(name, factory)
+}
+
+// Sources returns a sorted list of the names of the avaliable sources
+// of the default registry.
+func Sources() []string {
+	return DefaultRegistry.Names()
+}
+
+// SourcesInfo returns the metadata of the sources of the default registry.
+func SourcesInfo() []*SourceInfo {
+	return DefaultRegistry.Infos()
+}
+
+// SourceWorkers returns the default number of workers of the source
+// of the default registry: zero if it is not defined
+// or the source is not registered.
+func SourceWorkers(name string) int {
+	if f := DefaultRegistry.lookup(name); f != nil {
+		return f.Workers
+	}
+	return 0
 }
 
-func initQuoteGetters(src []*SourceIsins) (map[string]quotegetter.QuoteGetter, error) {
+// WriteSources writes to w the metadata of the available sources
+// in the given format.
+func WriteSources(w io.Writer, format string) error {
+	f, err := newFormatter(format, w, sourceInfoHeader)
+	if err != nil {
+		return err
+	}
+	for _, si := range SourcesInfo() {
+		if err = f.write(si); err != nil {
+			return err
+		}
+	}
+	return f.flush()
+}
+
+// clientKey identifies the http.Client parameters.
+type clientKey struct {
+	proxy   string
+	timeout time.Duration
+	retries int
+}
+
+func initQuoteGetters(reg *Registry, src []*SourceIsins) (map[string]quotegetter.QuoteGetter, error) {
 	quoteGetter := make(map[string]quotegetter.QuoteGetter)
 
-	proxyClient := map[string]*http.Client{}
+	clients := map[clientKey]*http.Client{}
 
 	for _, s := range src {
 		name := s.Source
 
-		client, ok := proxyClient[s.Proxy]
+		key := clientKey{s.Proxy, s.Timeout, s.Retries}
+		if key.timeout == 0 {
+			key.timeout = quotegetter.DefaultTimeout
+		}
+		client, ok := clients[key]
 		if !ok {
-			client, err := quotegetter.DefaultClient(s.Proxy)
+			var err error
+			client, err = quotegetter.NewClient(key.proxy, key.timeout, key.retries)
 			if err != nil {
 				return nil, err
 			}
-			proxyClient[s.Proxy] = client
+			clients[key] = client
 		}
 
-		fn := availableSources[name]
-		if fn == nil {
+		f := reg.lookup(name)
+		if f == nil {
 			panic("invalid source: " + name)
 		}
-		quoteGetter[name] = fn(name, client)
+		quoteGetter[name] = f.New(name, client)
 	}
 
 	return quoteGetter, nil
 }
-
-// getSources returns a list of the names of the available quoteGetters.
-func getSources() []string {
-
-	list := make([]string, 0, len(availableSources))
-	for name := range availableSources {
-		list = append(list, name)
-	}
-
-	return list
-}
-
-// Sources returns a sorted list of the names of the avaliable quoteGetters.
-func Sources() []string {
-	list := getSources()
-	sort.Strings(list)
-	return list
-}
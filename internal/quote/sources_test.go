@@ -1,7 +1,14 @@
 package quote
 
 import (
+	"bytes"
+	"context"
 	"testing"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSources(t *testing.T) {
@@ -13,3 +20,112 @@ func TestSources(t *testing.T) {
 		}
 	}
 }
+
+func TestRegister(t *testing.T) {
+	reg := newDummyRegistry(t, "source1")
+
+	cases := []struct {
+		name    string
+		factory *SourceFactory
+		errmsg  string
+	}{
+		{"source2", &SourceFactory{New: newDummyQuoteGetter, Workers: 3}, ""},
+		{"source1", &SourceFactory{New: newDummyQuoteGetter}, "already registered"},
+		{"source2", &SourceFactory{New: newDummyQuoteGetter}, "already registered"},
+		{"", &SourceFactory{New: newDummyQuoteGetter}, "name not defined"},
+		{"source3", nil, "function not defined"},
+		{"source3", &SourceFactory{}, "function not defined"},
+		{"source3", &SourceFactory{New: newDummyQuoteGetter, Workers: -1}, "invalid workers"},
+	}
+
+	for _, c := range cases {
+		err := reg.Register(c.name, c.factory)
+		if c.errmsg == "" {
+			assert.NoError(t, err, c.name)
+		} else if assert.Error(t, err, c.name) {
+			assert.Contains(t, err.Error(), c.errmsg, c.name)
+		}
+	}
+
+	assert.Equal(t, []string{"source1", "source2"}, reg.Names())
+}
+
+func TestRegistryInfos(t *testing.T) {
+	reg := NewRegistry()
+	kinds := []string{KindFund, KindETF}
+	reg.Register("sourceB", &SourceFactory{
+		New:     newDummyQuoteGetter,
+		Kinds:   kinds,
+		Workers: 4,
+		BaseURL: "https://www.example.com",
+	})
+	reg.Register("sourceA", &SourceFactory{New: newDummyQuoteGetter})
+
+	// changes to the factory after the registration are ignored
+	kinds[0] = KindStock
+
+	want := []*SourceInfo{
+		{Name: "sourceA", Workers: 1},
+		{Name: "sourceB", Kinds: []string{KindFund, KindETF}, Workers: 4, BaseURL: "https://www.example.com"},
+	}
+	assert.Equal(t, want, reg.Infos())
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	for _, si := range SourcesInfo() {
+		assert.NotEmpty(t, si.Kinds, si.Name)
+		assert.NotEmpty(t, si.BaseURL, si.Name)
+		assert.Greater(t, si.Workers, 0, si.Name)
+	}
+
+	var buf bytes.Buffer
+	if assert.NoError(t, WriteSources(&buf, FormatCSV)) {
+		assert.Contains(t, buf.String(), "source,kinds,workers,base url\n")
+		assert.Contains(t, buf.String(), "morningstarit,\"fund,etf\",1,https://www.morningstar.it\n")
+	}
+}
+
+func TestRegistryLimiter(t *testing.T) {
+	reg := newDummyRegistry(t, "source1")
+	rl := &taskengine.RateLimit{Spacing: time.Second}
+
+	l, err := reg.limiter("source1", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+
+	l1, err := reg.limiter("source1", rl)
+	require.NoError(t, err)
+	l2, err := reg.limiter("source1", &taskengine.RateLimit{Spacing: time.Second})
+	require.NoError(t, err)
+	assert.True(t, l1 == l2, "same rate limit: expected the same limiter")
+
+	l3, err := reg.limiter("source1", &taskengine.RateLimit{Spacing: time.Minute})
+	require.NoError(t, err)
+	assert.True(t, l1 != l3, "new rate limit: expected a new limiter")
+
+	_, err = reg.limiter("source1", &taskengine.RateLimit{Requests: 1})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "source \"source1\": invalid rate limit")
+	}
+}
+
+func TestRetrieveRate(t *testing.T) {
+	reg := newDummyRegistry(t, "source1")
+	spacing := 100 * time.Millisecond
+	sis := []*SourceIsins{
+		{
+			Source: "source1",
+			Isins:  []string{"isin1"},
+			Rate:   &taskengine.RateLimit{Spacing: spacing},
+		},
+	}
+
+	// the two retrievals share the same budget
+	start := time.Now()
+	for j := 0; j < 2; j++ {
+		res, err := retrieve(context.Background(), reg, sis, nil, &taskengine.Options{})
+		require.NoError(t, err)
+		assert.Len(t, res, 1)
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(spacing))
+}
@@ -0,0 +1,91 @@
+package quote
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+var sourceStatsHeader = []string{"SOURCE", "DONE", "SUCCESS", "ERRORS", "CANCELED", "SKIPPED", "P50", "P90", "P99", "MAX", "IDLE"}
+
+// sourceStats is the row of the statistics of a source.
+type sourceStats struct {
+	source string
+	*taskengine.WorkerStats
+}
+
+func (s *sourceStats) fields() []string {
+	itoa := strconv.Itoa
+	ms := func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	}
+	return []string{
+		s.source,
+		itoa(s.Done),
+		itoa(s.Success),
+		itoa(s.Errors),
+		itoa(s.Canceled),
+		itoa(s.Skipped),
+		ms(s.Latency.P50),
+		ms(s.Latency.P90),
+		ms(s.Latency.P99),
+		ms(s.Latency.Max),
+		ms(s.Idle),
+	}
+}
+
+var isinStatsHeader = []string{"ISIN", "ATTEMPTS", "WINNER"}
+
+// isinStats is the row of the statistics of an isin.
+type isinStats struct {
+	isin string
+	*taskengine.TaskStats
+}
+
+func (s *isinStats) fields() []string {
+	return []string{s.isin, strconv.Itoa(s.Attempts), string(s.Winner)}
+}
+
+// writeStats writes the statistics of a run to w:
+// a table with the sources, a table with the isins and the wall time.
+func writeStats(w io.Writer, st *taskengine.Stats) error {
+	sources := make([]string, 0, len(st.Workers))
+	for wid := range st.Workers {
+		sources = append(sources, string(wid))
+	}
+	sort.Strings(sources)
+
+	f := newTableFormatter(w, sourceStatsHeader)
+	for _, source := range sources {
+		if err := f.write(&sourceStats{source, st.Workers[taskengine.WorkerID(source)]}); err != nil {
+			return err
+		}
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	isins := make([]string, 0, len(st.Tasks))
+	for tid := range st.Tasks {
+		isins = append(isins, string(tid))
+	}
+	sort.Strings(isins)
+
+	fmt.Fprintln(w)
+	f = newTableFormatter(w, isinStatsHeader)
+	for _, isin := range isins {
+		if err := f.write(&isinStats{isin, st.Tasks[taskengine.TaskID(isin)]}); err != nil {
+			return err
+		}
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nWall time: %v\n", st.Wall.Round(time.Millisecond))
+	return err
+}
@@ -0,0 +1,39 @@
+package quote
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStats(t *testing.T) {
+	reg := newDummyRegistry(t, "source1", "source2")
+	sis := []*SourceIsins{
+		{Source: "source1", Workers: 1, Isins: []string{"isin1"}},
+		{Source: "source2", Workers: 1, Isins: []string{"isin1", "isin2"}},
+	}
+	stats := &taskengine.Stats{}
+	_, err := retrieve(context.Background(), reg, sis, nil, &taskengine.Options{Mode: taskengine.All, Stats: stats})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeStats(&buf, stats))
+
+	lines := strings.Split(buf.String(), "\n")
+	require.True(t, len(lines) > 8, buf.String())
+
+	assert.Regexp(t, `^SOURCE\s+DONE\s+SUCCESS\s+ERRORS\s+CANCELED\s+SKIPPED\s+P50`, lines[0])
+	assert.Regexp(t, `^source1\s+1\s+1\s+0\s+0\s+0\s+\d+ms`, lines[1])
+	// the error of source2 for isin1 is usually canceled
+	// by the success of source1: errors and canceled are not checked
+	assert.Regexp(t, `^source2\s+2\s+0\s`, lines[2])
+	assert.Regexp(t, `^ISIN\s+ATTEMPTS\s+WINNER`, lines[4])
+	assert.Regexp(t, `^isin1\s+2\s+source1`, lines[5])
+	assert.Regexp(t, `^isin2\s+1\s*$`, lines[6])
+	assert.Regexp(t, `^Wall time: `, lines[8])
+}
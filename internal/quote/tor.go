@@ -11,9 +11,9 @@ import (
 // TorCheck checks if a Tor connection is used,
 // retrieving the "https://check.torproject.org" page.
 // It returns:
-//  - bool:   true if Tor is used, false otherwise
-//  - string: the message contained in the html page
-//  - error:  if the message cannot be determined
+//   - bool:   true if Tor is used, false otherwise
+//   - string: the message contained in the html page
+//   - error:  if the message cannot be determined
 func TorCheck(proxy string) (bool, string, error) {
 	// URL to fetch
 	var webURL string = "https://check.torproject.org"
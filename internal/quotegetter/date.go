@@ -0,0 +1,129 @@
+package quotegetter
+
+import (
+	"fmt"
+	"time"
+
+	// embed the time zone database,
+	// so that the time zones of the sources are available on any system
+	_ "time/tzdata"
+)
+
+// DateKind is the meaning of the dates of the quotes of a source.
+type DateKind int
+
+// DateKind values.
+const (
+	// NAVDate is the calendar day of the price, without time of day,
+	// as the date of the net asset value of a fund.
+	NAVDate DateKind = iota
+
+	// Timestamp is the instant of the price, as the last trade of a crypto currency.
+	Timestamp
+)
+
+var dateKindNames = map[DateKind]string{
+	NAVDate:   "nav",
+	Timestamp: "timestamp",
+}
+
+// String returns the name of the date kind.
+func (k DateKind) String() string {
+	if s, ok := dateKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("DateKind(%d)", int(k))
+}
+
+// ParseDateKind returns the DateKind of the name: "nav" or "timestamp".
+// An empty name is NAVDate.
+func ParseDateKind(name string) (DateKind, error) {
+	if name == "" {
+		return NAVDate, nil
+	}
+	for k, s := range dateKindNames {
+		if s == name {
+			return k, nil
+		}
+	}
+	return NAVDate, fmt.Errorf("invalid date kind %q", name)
+}
+
+// DateSpec declares the time zone and the kind of the dates of a source.
+// The zero value declares NAV dates in UTC.
+type DateSpec struct {
+	// Location is the time zone of the dates without zone offset,
+	// and of the unix times. If nil, UTC is used.
+	Location *time.Location
+
+	// Kind is the meaning of the dates.
+	Kind DateKind
+}
+
+// NewDateSpec returns the DateSpec of the time zone name
+// (e.g. "Europe/Rome", empty for UTC) and of the date kind name.
+func NewDateSpec(timeZone, kind string) (DateSpec, error) {
+	var (
+		ds  DateSpec
+		err error
+	)
+	if timeZone != "" {
+		if ds.Location, err = time.LoadLocation(timeZone); err != nil {
+			return ds, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+	if ds.Kind, err = ParseDateKind(kind); err != nil {
+		return ds, err
+	}
+	return ds, nil
+}
+
+// MustLoadLocation is like time.LoadLocation but panics if the location
+// cannot be loaded. It is used to declare the time zone of the built-in sources.
+func MustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+func (ds DateSpec) location() *time.Location {
+	if ds.Location == nil {
+		return time.UTC
+	}
+	return ds.Location
+}
+
+// Normalize returns the date t normalized by the kind of the dates,
+// so that it does not depend on the time zone of the source or of the system:
+// a NAV date is the midnight UTC of the calendar day of t, in the location of t;
+// a timestamp is the instant t in UTC.
+// The zero time is returned as is.
+func (ds DateSpec) Normalize(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if ds.Kind == Timestamp {
+		return t.UTC()
+	}
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Parse parses the value with the layout (see time.Parse)
+// and returns the normalized date.
+// A value without zone offset is in the time zone of the source.
+func (ds DateSpec) Parse(layout, value string) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, value, ds.location())
+	if err != nil {
+		return t, err
+	}
+	return ds.Normalize(t), nil
+}
+
+// Unix returns the normalized date of the unix time:
+// the calendar day of a NAV date is the one of the time zone of the source.
+func (ds DateSpec) Unix(sec, nsec int64) time.Time {
+	return ds.Normalize(time.Unix(sec, nsec).In(ds.location()))
+}
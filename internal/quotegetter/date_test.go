@@ -0,0 +1,90 @@
+package quotegetter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateSpecParse(t *testing.T) {
+	rome := MustLoadLocation("Europe/Rome")
+	auckland := MustLoadLocation("Pacific/Auckland")
+
+	cases := []struct {
+		title  string
+		spec   DateSpec
+		layout string
+		value  string
+		want   time.Time
+	}{
+		{"nav date utc", DateSpec{}, "02/01/2006", "22/09/2020", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"nav date rome", DateSpec{Location: rome}, "02/01/2006", "22/09/2020", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"nav date auckland", DateSpec{Location: auckland}, "02/01/2006", "22/09/2020", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"nav date with time", DateSpec{Location: rome}, "02/01/2006 15:04", "22/09/2020 01:30", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"nav date with offset", DateSpec{}, time.RFC3339, "2020-09-22T00:00:00+02:00", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"timestamp rome", DateSpec{Location: rome, Kind: Timestamp}, "02/01/2006 15:04", "22/09/2020 01:30", time.Date(2020, 9, 21, 23, 30, 0, 0, time.UTC)},
+		{"timestamp with offset", DateSpec{Location: rome, Kind: Timestamp}, time.RFC3339, "2020-09-22T01:30:00Z", time.Date(2020, 9, 22, 1, 30, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		got, err := c.spec.Parse(c.layout, c.value)
+		if err != nil {
+			t.Errorf("%s: unexpected error %q", c.title, err)
+			continue
+		}
+		// the location must be UTC, not only the instant
+		if got != c.want {
+			t.Errorf("%s: expected %v, found %v", c.title, c.want, got)
+		}
+	}
+
+	if _, err := (DateSpec{}).Parse("02/01/2006", "2020-09-22"); err == nil {
+		t.Errorf("invalid date: expected error, found <nil>")
+	}
+}
+
+func TestDateSpecUnix(t *testing.T) {
+	const sec = 1604159942 // 2020-10-31 15:59:02 UTC
+
+	cases := []struct {
+		title string
+		spec  DateSpec
+		want  time.Time
+	}{
+		{"timestamp", DateSpec{Kind: Timestamp}, time.Date(2020, 10, 31, 15, 59, 2, 0, time.UTC)},
+		{"nav date utc", DateSpec{}, time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"nav date auckland", DateSpec{Location: MustLoadLocation("Pacific/Auckland")}, time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if got := c.spec.Unix(sec, 0); got != c.want {
+			t.Errorf("%s: expected %v, found %v", c.title, c.want, got)
+		}
+	}
+
+	if got := (DateSpec{}).Normalize(time.Time{}); !got.IsZero() {
+		t.Errorf("zero time: expected zero time, found %v", got)
+	}
+}
+
+func TestNewDateSpec(t *testing.T) {
+	cases := []struct {
+		timeZone, kind string
+		want           DateKind
+		wantErr        bool
+	}{
+		{"", "", NAVDate, false},
+		{"Europe/Rome", "nav", NAVDate, false},
+		{"UTC", "timestamp", Timestamp, false},
+		{"Europe/Nowhere", "", NAVDate, true},
+		{"", "datetime", NAVDate, true},
+	}
+	for _, c := range cases {
+		ds, err := NewDateSpec(c.timeZone, c.kind)
+		if (err != nil) != c.wantErr {
+			t.Errorf("NewDateSpec(%q, %q): unexpected error %v", c.timeZone, c.kind, err)
+			continue
+		}
+		if err == nil && ds.Kind != c.want {
+			t.Errorf("NewDateSpec(%q, %q): expected kind %s, found %s", c.timeZone, c.kind, c.want, ds.Kind)
+		}
+	}
+}
@@ -1,14 +1,50 @@
 package quotegetter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"syscall"
 	"time"
 )
 
-// DefaultClient xxx
+// DefaultTimeout is the timeout of the http requests of the DefaultClient.
+const DefaultTimeout = 10 * time.Second
+
+// Backoff parameters of the retried http requests:
+// the n-th retry waits retryBackoff * 2^(n-1), up to retryMaxBackoff.
+const (
+	retryBackoff    = 500 * time.Millisecond
+	retryMaxBackoff = 10 * time.Second
+)
+
+// StatusError is returned by DoHTTPRequest when the response status is not 200 OK.
+type StatusError struct {
+	Method     string
+	Status     string
+	StatusCode int
+}
+
+// Error return the string representation of the error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s response status = %v", e.Method, e.Status)
+}
+
+// DefaultClient returns an http.Client that uses the given proxy,
+// with DefaultTimeout and no retries.
 func DefaultClient(proxy string) (*http.Client, error) {
+	return NewClient(proxy, DefaultTimeout, 0)
+}
+
+// NewClient returns an http.Client that uses the given proxy.
+// Each request attempt is cancelled after the timeout, if greater than zero.
+// In case of transient failures (see IsTransient), an idempotent request
+// is retried up to retries times, with exponential backoff.
+func NewClient(proxy string, timeout time.Duration, retries int) (*http.Client, error) {
 	// tr := &http.Transport{}
 	tr := http.DefaultTransport.(*http.Transport).Clone()
 
@@ -23,20 +59,131 @@ func DefaultClient(proxy string) (*http.Client, error) {
 	}
 
 	client := &http.Client{
-		Transport: tr,
-		Timeout:   10 * time.Second,
+		Transport: &retryTransport{
+			base:    tr,
+			timeout: timeout,
+			retries: retries,
+			backoff: retryBackoff,
+		},
 	}
 	return client, nil
 }
 
 // DoHTTPRequest executes the http request.
+// In case of a response status other than 200 OK,
+// the response body is closed and a *StatusError is returned.
 func DoHTTPRequest(client *http.Client, req *http.Request) (*http.Response, error) {
 	if client == nil {
 		client, _ = DefaultClient("")
 	}
 	resp, err := client.Do(req)
 	if (err == nil) && (resp.StatusCode != http.StatusOK) {
-		err = fmt.Errorf("%s response status = %v", req.Method, resp.Status)
+		resp.Body.Close()
+		err = &StatusError{
+			Method:     req.Method,
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+		}
 	}
 	return resp, err
 }
+
+// IsTransient returns if the error is a transient failure,
+// so that the request can be retried:
+// a 5xx response status, a connection reset or an unexpected EOF.
+func IsTransient(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// retryTransport is an http.RoundTripper that sets a timeout to each
+// request attempt, and retries the transient failures.
+type retryTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+// cancelBody is a response body that cancels the context
+// of the request attempt when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// roundTrip executes a single attempt of the request.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// canRetry returns if the request can be executed more than once.
+func canRetry(req *http.Request) bool {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	return idempotent && (req.Body == nil || req.Body == http.NoBody)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retries := t.retries
+	if !canRetry(req) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(req)
+
+		transient := false
+		if err == nil {
+			transient = resp.StatusCode >= 500
+		} else {
+			// the timeout of the single attempt is a transient error,
+			// if the context of the request is not done.
+			transient = IsTransient(err) || errors.Is(err, context.DeadlineExceeded)
+		}
+		if !transient || attempt >= retries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		// discard the response before retrying
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		// exponential backoff
+		wait := t.backoff << attempt
+		if wait > retryMaxBackoff || wait <= 0 {
+			wait = retryMaxBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
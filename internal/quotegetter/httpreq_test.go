@@ -2,10 +2,16 @@ package quotegetter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -183,3 +189,116 @@ func TestDoHTTPRequestKO(t *testing.T) {
 	t.Log(err)
 	// t.Fail()
 }
+
+// newCountingServer returns a test server that responds with the status codes
+// of the list, one for each request, and 200 OK after the end of the list.
+// Each response is sent after the delay.
+func newCountingServer(delay time.Duration, codes ...int) (*httptest.Server, *int32) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&count, 1))
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if n <= len(codes) && codes[n-1] != http.StatusOK {
+			http.Error(w, http.StatusText(codes[n-1]), codes[n-1])
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	return server, &count
+}
+
+func newTestClient(t *testing.T, timeout time.Duration, retries int) *http.Client {
+	client, err := NewClient("", timeout, retries)
+	if err != nil {
+		t.Fatalf("NewClient: %q", err)
+	}
+	// no need to wait in tests
+	client.Transport.(*retryTransport).backoff = time.Millisecond
+	return client
+}
+
+func TestDoHTTPRequestRetry(t *testing.T) {
+	testCases := map[string]struct {
+		codes    []int
+		retries  int
+		attempts int32
+		status   int // 0 means success
+	}{
+		"ok":                {nil, 2, 1, 0},
+		"ok after retries":  {[]int{500, 502}, 2, 3, 0},
+		"retries exhausted": {[]int{503, 503, 503}, 2, 3, 503},
+		"no retries":        {[]int{500}, 0, 1, 500},
+		"not transient":     {[]int{404}, 2, 1, 404},
+	}
+
+	for title, tc := range testCases {
+		server, count := newCountingServer(0, tc.codes...)
+
+		client := newTestClient(t, time.Second, tc.retries)
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %q", err)
+		}
+		resp, err := DoHTTPRequest(client, req)
+		if tc.status == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %q", title, err)
+			} else {
+				resp.Body.Close()
+			}
+		} else {
+			var se *StatusError
+			if !errors.As(err, &se) {
+				t.Errorf("%s: expected StatusError, got %v", title, err)
+			} else if se.StatusCode != tc.status {
+				t.Errorf("%s: expected status %d, got %d", title, tc.status, se.StatusCode)
+			}
+		}
+		if got := atomic.LoadInt32(count); got != tc.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", title, tc.attempts, got)
+		}
+		server.Close()
+	}
+}
+
+func TestDoHTTPRequestRetryTimeout(t *testing.T) {
+	server, count := newCountingServer(100 * time.Millisecond)
+	defer server.Close()
+
+	client := newTestClient(t, 20*time.Millisecond, 1)
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %q", err)
+	}
+	_, err = DoHTTPRequest(client, req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error %q, got %v", context.DeadlineExceeded, err)
+	}
+	if got := atomic.LoadInt32(count); got != 2 {
+		t.Errorf("expected %d attempts, got %d", 2, got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("generic error"), false},
+		{&StatusError{"GET", "500 Internal Server Error", 500}, true},
+		{&StatusError{"GET", "503 Service Unavailable", 503}, true},
+		{&StatusError{"GET", "404 Not Found", 404}, false},
+		{fmt.Errorf("wrapped: %w", &StatusError{"GET", "502 Bad Gateway", 502}), true},
+		{&url.Error{Op: "Get", URL: "http://x", Err: syscall.ECONNRESET}, true},
+		{io.ErrUnexpectedEOF, true},
+		{context.Canceled, false},
+	}
+	for _, tc := range testCases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v): want %v, got %v", tc.err, tc.want, got)
+		}
+	}
+}
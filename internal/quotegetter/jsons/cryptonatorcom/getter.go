@@ -7,13 +7,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/decimal"
 )
 
+// dateSpec declares the dates of the source: the timestamps of the prices.
+var dateSpec = quotegetter.DateSpec{Kind: quotegetter.Timestamp}
+
 // getter gets cryptocurrrencies prices from cryptonator.com
 type getter struct {
 	name     string
@@ -106,7 +108,7 @@ func (g *getter) parseJSON(body []byte) (*quotegetter.Result, error) {
 	}
 
 	if res.Success {
-		price64, err := strconv.ParseFloat(res.Ticker.Price, 32)
+		price, err := decimal.Parse(res.Ticker.Price)
 		if err != nil {
 			return nil, err
 		}
@@ -115,8 +117,8 @@ func (g *getter) parseJSON(body []byte) (*quotegetter.Result, error) {
 			Isin:     res.Ticker.Base,
 			Currency: res.Ticker.Target,
 			Source:   g.Source(),
-			Date:     time.Unix(res.Timestamp, 0),
-			Price:    float32(price64),
+			Date:     dateSpec.Unix(res.Timestamp, 0),
+			Price:    price,
 		}
 		return r, nil
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 // func TestGetJson(t *testing.T) {
@@ -62,6 +63,18 @@ func TestParseJson(t *testing.T) {
 	eq("price", "11872.29709977", res.Ticker.Price)
 	eqi("timestamp", 1604159942, res.Timestamp)
 
+	// the price of the quote is exact
+	g := &getter{name: "cryptonator-eur", currency: "EUR"}
+	r, err := g.parseJSON([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq("quote price", "11872.29709977", r.Price.String())
+
+	// the date is the timestamp in UTC
+	if !r.Date.Equal(time.Unix(1604159942, 0)) || r.Date.Location() != time.UTC {
+		t.Errorf("quote date: expected %v, found %v", time.Unix(1604159942, 0).UTC(), r.Date)
+	}
 }
 
 func TestGetQuote(t *testing.T) {
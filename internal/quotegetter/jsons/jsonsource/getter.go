@@ -0,0 +1,345 @@
+// Package jsonsource implements a quote getter of a json api defined declaratively,
+// without writing Go code: the url of the api is a text/template string,
+// and the fields of the quote are selected from the json response
+// using path expressions (see Path).
+//
+// Example of a definition in yaml format:
+//
+//	kinds: [crypto]
+//	base_url: https://api.example.com
+//	url: https://api.example.com/ticker/{{.Isin | lower}}-eur
+//	price: ticker.price
+//	currency: ticker.target
+//	date: timestamp
+//	date_format: unix
+//	error: error
+package jsonsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/decimal"
+)
+
+// Date formats of the timestamp values, besides the time.Parse layouts.
+const (
+	// DateFormatUnix is the number of seconds since January 1, 1970 UTC.
+	DateFormatUnix = "unix"
+
+	// DateFormatUnixMilli is the number of milliseconds since January 1, 1970 UTC.
+	DateFormatUnixMilli = "unixms"
+)
+
+// Errors returned by the getter.
+var (
+	ErrPriceNotFound = errors.New("price not found")
+	ErrDateNotFound  = errors.New("date not found")
+	ErrIsinMismatch  = errors.New("isin mismatch")
+)
+
+// Definition is the declarative definition of a json api source.
+type Definition struct {
+	// Kinds is the list of the asset kinds supported by the source.
+	Kinds []string `json:"kinds,omitempty"`
+
+	// BaseURL is the url of the site of the source.
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url" toml:"base_url"`
+
+	// URL is the template of the url of the api.
+	// The template data has the Isin field.
+	// The lower and upper functions are available.
+	URL string `json:"url"`
+
+	// Headers are the http headers of the request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Path expressions of the fields of the quote.
+	// Price is mandatory.
+	// If Isin is defined, the value is compared with the requested isin (ignoring case).
+	// If Error is defined and the value is not empty, null or false,
+	// the request fails with the value as error message.
+	Price    string `json:"price"`
+	Currency string `json:"currency,omitempty"`
+	Date     string `json:"date,omitempty"`
+	Isin     string `json:"isin,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	// DefaultCurrency is the currency used if the Currency path is not defined
+	// or the value is empty.
+	DefaultCurrency string `json:"default_currency,omitempty" yaml:"default_currency" toml:"default_currency"`
+
+	// DateFormat is the format of the date value: "unix" (seconds), "unixms" (milliseconds)
+	// or a layout as defined by time.Parse. Default is time.RFC3339.
+	DateFormat string `json:"date_format,omitempty" yaml:"date_format" toml:"date_format"`
+
+	// TimeZone is the time zone of the dates without zone offset,
+	// e.g. "Europe/Rome". Default is UTC.
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone" toml:"time_zone"`
+
+	// DateKind is the meaning of the date value: "nav", the calendar day
+	// of the price, or "timestamp", the instant of the price. Default is "timestamp".
+	DateKind string `json:"date_kind,omitempty" yaml:"date_kind" toml:"date_kind"`
+}
+
+// templateData is the data used to execute the url template.
+type templateData struct {
+	Isin string
+}
+
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// compiled is a checked definition, with the parsed url template and paths.
+type compiled struct {
+	def      *Definition
+	url      *template.Template
+	price    *Path
+	currency *Path
+	date     *Path
+	isin     *Path
+	err      *Path
+	dateSpec quotegetter.DateSpec
+}
+
+// getter gets the quotes as specified by the definition.
+type getter struct {
+	name   string
+	client *http.Client
+	*compiled
+}
+
+// NewQuoteGetterFunc checks the definition and returns the function
+// that creates the QuoteGetter of the source.
+func NewQuoteGetterFunc(def *Definition) (func(name string, client *http.Client) quotegetter.QuoteGetter, error) {
+	c, err := compile(def)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string, client *http.Client) quotegetter.QuoteGetter {
+		return &getter{name, client, c}
+	}, nil
+}
+
+func compile(def *Definition) (*compiled, error) {
+	var err error
+
+	if def == nil {
+		return nil, errors.New("definition not defined")
+	}
+	if def.URL == "" {
+		return nil, errors.New("url not defined")
+	}
+	if def.Price == "" {
+		return nil, errors.New("price path not defined")
+	}
+
+	c := &compiled{def: def}
+	if c.url, err = template.New("url").Funcs(templateFuncs).Parse(def.URL); err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	paths := []struct {
+		expr string
+		path **Path
+	}{
+		{def.Price, &c.price},
+		{def.Currency, &c.currency},
+		{def.Date, &c.date},
+		{def.Isin, &c.isin},
+		{def.Error, &c.err},
+	}
+	for _, p := range paths {
+		if p.expr == "" {
+			continue
+		}
+		if *p.path, err = CompilePath(p.expr); err != nil {
+			return nil, err
+		}
+	}
+
+	kind := def.DateKind
+	if kind == "" {
+		kind = quotegetter.Timestamp.String()
+	}
+	if c.dateSpec, err = quotegetter.NewDateSpec(def.TimeZone, kind); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// parseDate parses the date value with the given format,
+// and returns the date normalized as declared by spec.
+func parseDate(value, format string, spec quotegetter.DateSpec) (time.Time, error) {
+	switch format {
+	case "":
+		return spec.Parse(time.RFC3339, value)
+	case DateFormatUnix, DateFormatUnixMilli:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			// fractional timestamp
+			f, errFloat := strconv.ParseFloat(value, 64)
+			if errFloat != nil {
+				return time.Time{}, err
+			}
+			if format == DateFormatUnixMilli {
+				f /= 1000
+			}
+			return spec.Unix(0, int64(f*1e9)), nil
+		}
+		if format == DateFormatUnixMilli {
+			return spec.Unix(0, n*int64(time.Millisecond)), nil
+		}
+		return spec.Unix(n, 0), nil
+	}
+	return spec.Parse(format, value)
+}
+
+// Source returns the name of the getter
+func (g *getter) Source() string {
+	return g.name
+}
+
+// Client returns the http.Client of the getter
+func (g *getter) Client() *http.Client {
+	return g.client
+}
+
+// GetQuote gets the quote of the isin.
+// If url is empty, it is built from the url template.
+func (g *getter) GetQuote(ctx context.Context, isin, url string) (*quotegetter.Result, error) {
+	var (
+		err error
+		res *http.Response
+		r   *quotegetter.Result
+	)
+
+	if url == "" {
+		var buf bytes.Buffer
+		err = g.url.Execute(&buf, &templateData{Isin: isin})
+		url = buf.String()
+	}
+
+	var req *http.Request
+	if err == nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+	if err == nil {
+		for k, v := range g.def.Headers {
+			req.Header.Set(k, v)
+		}
+		res, err = quotegetter.DoHTTPRequest(g.client, req)
+	}
+	if err == nil {
+		r, err = g.parse(res, isin)
+		res.Body.Close()
+	}
+
+	if err != nil {
+		return nil, quotegetter.NewError(g.Source(), isin, url, err)
+	}
+	r.URL = url
+	return r, nil
+}
+
+// lookup returns the string value selected by the path.
+// It returns the empty string if the path is nil or the value is not found.
+func lookup(doc interface{}, path *Path) (string, error) {
+	if path == nil {
+		return "", nil
+	}
+	v, ok := path.Eval(doc)
+	if !ok {
+		return "", nil
+	}
+	s, err := valueString(v)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// parse decodes the json response and returns the quote.
+func (g *getter) parse(res *http.Response, isin string) (*quotegetter.Result, error) {
+	var doc interface{}
+
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	// error
+	msg, err := lookup(doc, g.err)
+	if err != nil {
+		return nil, err
+	}
+	if msg != "" && msg != "false" {
+		return nil, errors.New(msg)
+	}
+
+	// isin
+	if g.isin != nil {
+		s, err := lookup(doc, g.isin)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(s, isin) {
+			return nil, fmt.Errorf("%w: expected %q, found %q", ErrIsinMismatch, isin, s)
+		}
+	}
+
+	r := &quotegetter.Result{
+		Source: g.Source(),
+		Isin:   isin,
+	}
+
+	// price
+	s, err := lookup(doc, g.price)
+	if err != nil {
+		return nil, err
+	}
+	if s == "" {
+		return nil, ErrPriceNotFound
+	}
+	if r.Price, err = decimal.Parse(s); err != nil {
+		return nil, err
+	}
+
+	// currency
+	if r.Currency, err = lookup(doc, g.currency); err != nil {
+		return nil, err
+	}
+	if r.Currency == "" {
+		r.Currency = g.def.DefaultCurrency
+	}
+	r.Currency = quotegetter.NormalizeCurrency(r.Currency)
+
+	// date
+	if g.date != nil {
+		s, err := lookup(doc, g.date)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			return nil, ErrDateNotFound
+		}
+		if r.Date, err = parseDate(s, g.def.DateFormat, g.dateSpec); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
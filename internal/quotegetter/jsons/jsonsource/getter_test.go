@@ -0,0 +1,265 @@
+package jsonsource
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tickerDefinition is the definition of a cryptonator.com like api.
+var tickerDefinition = &Definition{
+	URL:        "http://127.0.0.1/ticker/{{.Isin | lower}}",
+	Price:      "ticker.price",
+	Currency:   "ticker.target",
+	Date:       "timestamp",
+	DateFormat: DateFormatUnix,
+	Isin:       "ticker.base",
+	Error:      "error",
+}
+
+// parseBody parses the json body with the getter of the definition.
+func parseBody(t *testing.T, def *Definition, body, isin string) (*quotegetter.Result, error) {
+	c, err := compile(def)
+	require.NoError(t, err)
+	g := &getter{"test", nil, c}
+	res := &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+	return g.parse(res, isin)
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		title    string
+		def      *Definition
+		body     string
+		isin     string
+		price    decimal.Decimal
+		currency string
+		date     time.Time
+		errmsg   string
+	}{
+		{
+			title:    "ok",
+			def:      tickerDefinition,
+			body:     `{"ticker":{"base":"BTC","target":"EUR","price":"11872.29"},"timestamp":1604159942,"success":true,"error":""}`,
+			isin:     "BTC",
+			price:    "11872.29",
+			currency: "EUR",
+			date:     time.Unix(1604159942, 0).UTC(),
+		},
+		{
+			title:    "number price",
+			def:      tickerDefinition,
+			body:     `{"ticker":{"base":"ETH","target":"EUR","price":321.5},"timestamp":1604159942,"error":null}`,
+			isin:     "ETH",
+			price:    "321.5",
+			currency: "EUR",
+			date:     time.Unix(1604159942, 0).UTC(),
+		},
+		{
+			title: "default currency",
+			def: &Definition{
+				URL:             "http://127.0.0.1/{{.Isin}}",
+				Price:           "ticker.price",
+				Currency:        "ticker.target",
+				DefaultCurrency: "USD",
+			},
+			body:     `{"ticker":{"base":"ETH","target":"","price":321.5}}`,
+			isin:     "ETH",
+			price:    "321.5",
+			currency: "USD",
+		},
+		{
+			title:  "error field",
+			def:    tickerDefinition,
+			body:   `{"ticker":{"base":"","target":"","price":""},"timestamp":1604159942,"success":false,"error":"Pair not found"}`,
+			isin:   "XXX",
+			errmsg: "Pair not found",
+		},
+		{
+			title: "error field false",
+			def: &Definition{
+				URL:   "http://127.0.0.1/{{.Isin}}",
+				Price: "price",
+				Error: "failed",
+			},
+			body:  `{"price":"1","failed":false}`,
+			isin:  "YYY",
+			price: "1",
+		},
+		{
+			title:  "isin mismatch",
+			def:    tickerDefinition,
+			body:   `{"ticker":{"base":"BTC","target":"EUR","price":"11872.29"},"timestamp":1604159942,"error":""}`,
+			isin:   "ZZZ",
+			errmsg: "isin mismatch",
+		},
+		{
+			title: "price not found",
+			def: &Definition{
+				URL:   "http://127.0.0.1/{{.Isin}}",
+				Price: "ticker.price",
+			},
+			body:   `{"ticker":{"base":"","target":"","price":""}}`,
+			isin:   "XXX",
+			errmsg: "price not found",
+		},
+		{
+			title:  "date not found",
+			def:    &Definition{URL: "http://127.0.0.1/{{.Isin}}", Price: "price", Date: "missing"},
+			body:   `{"price":"1"}`,
+			isin:   "YYY",
+			errmsg: "date not found",
+		},
+		{
+			title:  "invalid date",
+			def:    tickerDefinition,
+			body:   `{"ticker":{"base":"YYY","target":"EUR","price":"1"},"timestamp":"2020-10-31T16:59:02Z","error":""}`,
+			isin:   "YYY",
+			errmsg: "invalid syntax",
+		},
+		{
+			title:  "not scalar",
+			def:    &Definition{URL: "http://127.0.0.1/{{.Isin}}", Price: "ticker"},
+			body:   `{"ticker":{"price":"1"}}`,
+			isin:   "YYY",
+			errmsg: "not a scalar",
+		},
+		{
+			title:  "invalid json",
+			def:    tickerDefinition,
+			body:   `{"ticker":`,
+			isin:   "BAD",
+			errmsg: "unexpected EOF",
+		},
+	}
+
+	for _, c := range cases {
+		res, err := parseBody(t, c.def, c.body, c.isin)
+		if c.errmsg != "" {
+			if assert.Error(t, err, c.title) {
+				assert.Contains(t, err.Error(), c.errmsg, c.title)
+			}
+			continue
+		}
+		if assert.NoError(t, err, c.title) {
+			assert.Equal(t, c.isin, res.Isin, c.title)
+			assert.Equal(t, "test", res.Source, c.title)
+			assert.Equal(t, c.price, res.Price, c.title)
+			assert.Equal(t, c.currency, res.Currency, c.title)
+			assert.True(t, c.date.Equal(res.Date), "%s: date %v", c.title, res.Date)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	cases := []struct {
+		value    string
+		format   string
+		timeZone string
+		kind     string
+		date     time.Time
+	}{
+		{"1604159942", DateFormatUnix, "", "", time.Unix(1604159942, 0).UTC()},
+		{"1604159942.5", DateFormatUnix, "", "", time.Unix(1604159942, 5e8).UTC()},
+		{"1604159942000", DateFormatUnixMilli, "", "", time.Unix(1604159942, 0).UTC()},
+		{"2020-10-31T16:59:02Z", "", "", "", time.Date(2020, 10, 31, 16, 59, 2, 0, time.UTC)},
+		{"2020-10-31T16:59:02Z", "2006-01-02T15:04:05Z07:00", "", "", time.Date(2020, 10, 31, 16, 59, 2, 0, time.UTC)},
+		{"2020-10-31T16:59:02Z", "", "", "nav", time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"1604159942", DateFormatUnix, "Pacific/Auckland", "nav", time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		title := fmt.Sprintf("%s %s %s %s", c.value, c.format, c.timeZone, c.kind)
+		kind := c.kind
+		if kind == "" {
+			kind = "timestamp"
+		}
+		spec, err := quotegetter.NewDateSpec(c.timeZone, kind)
+		require.NoError(t, err, title)
+
+		date, err := parseDate(c.value, c.format, spec)
+		if assert.NoError(t, err, title) {
+			assert.True(t, c.date.Equal(date), "%s: date %v", title, date)
+		}
+	}
+}
+
+func TestGetQuote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/ticker/"))
+		fmt.Fprintf(w, `{"ticker":{"base":%q,"target":"EUR","price":"1.5"},"error":%q}`,
+			base, r.Header.Get("X-Error"))
+	}))
+	defer server.Close()
+
+	fn, err := NewQuoteGetterFunc(&Definition{
+		URL:      server.URL + "/ticker/{{.Isin | lower}}",
+		Price:    "ticker.price",
+		Currency: "ticker.target",
+		Isin:     "ticker.base",
+		Error:    "error",
+	})
+	require.NoError(t, err)
+
+	qg := fn("test", server.Client())
+	assert.Equal(t, "test", qg.Source())
+
+	// url from the template
+	res, err := qg.GetQuote(context.Background(), "BTC", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, decimal.Decimal("1.5"), res.Price)
+		assert.Equal(t, server.URL+"/ticker/btc", res.URL)
+	}
+
+	// given url
+	res, err = qg.GetQuote(context.Background(), "ETH", server.URL+"/ticker/eth")
+	if assert.NoError(t, err) {
+		assert.Equal(t, server.URL+"/ticker/eth", res.URL)
+	}
+
+	// headers: the server returns the header in the error field
+	fn, err = NewQuoteGetterFunc(&Definition{
+		URL:     server.URL + "/ticker/{{.Isin | lower}}",
+		Headers: map[string]string{"X-Error": "secret"},
+		Price:   "ticker.price",
+		Error:   "error",
+	})
+	require.NoError(t, err)
+	_, err = fn("test", server.Client()).GetQuote(context.Background(), "BTC", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "secret")
+	}
+}
+
+func TestNewQuoteGetterFuncErrors(t *testing.T) {
+	cases := []struct {
+		title  string
+		def    *Definition
+		errmsg string
+	}{
+		{"no definition", nil, "definition not defined"},
+		{"no url", &Definition{Price: "price"}, "url not defined"},
+		{"invalid url", &Definition{URL: "http://x/{{.Isin | title}}", Price: "price"}, "invalid url"},
+		{"no price", &Definition{URL: "http://x/"}, "price path not defined"},
+		{"invalid path", &Definition{URL: "http://x/", Price: "price", Currency: "ticker..target"}, "invalid path"},
+		{"invalid time zone", &Definition{URL: "http://x/", Price: "price", TimeZone: "Europe/Nowhere"}, "invalid time zone"},
+		{"invalid date kind", &Definition{URL: "http://x/", Price: "price", DateKind: "datetime"}, "invalid date kind"},
+	}
+
+	for _, c := range cases {
+		_, err := NewQuoteGetterFunc(c.def)
+		if assert.Error(t, err, c.title) {
+			assert.Contains(t, err.Error(), c.errmsg, c.title)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package jsonsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled path expression that selects a value of a json document.
+//
+// The expression is a sequence of steps:
+//
+//	key        the member of an object (first step only)
+//	.key       the member of an object
+//	["key"]    the member of an object, with any character in the key
+//	[n]        the n-th element of an array; negative n counts from the end
+//
+// An optional leading "$" represents the root of the document.
+// Examples: "ticker.price", "$.data[0].nav", `rates["EUR"]`, "prices[-1][1]".
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// step is a single step of a path: a key or an index.
+type step struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// CompilePath parses a path expression.
+func CompilePath(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+
+	errInvalid := func(msg string) (*Path, error) {
+		return nil, fmt.Errorf("invalid path %q: %s", expr, msg)
+	}
+
+	first := true
+	for len(s) > 0 {
+		switch {
+		case s[0] == '.':
+			s = s[1:]
+			n := keyLen(s)
+			if n == 0 {
+				return errInvalid("empty key")
+			}
+			p.steps = append(p.steps, step{key: s[:n], isKey: true})
+			s = s[n:]
+
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if strings.HasPrefix(s, `["`) {
+				// quoted key: find the closing quote followed by ']'
+				end = strings.Index(s[2:], `"]`)
+				if end < 0 {
+					return errInvalid("unterminated quoted key")
+				}
+				key, err := strconv.Unquote(s[1 : end+3])
+				if err != nil {
+					return errInvalid("invalid quoted key")
+				}
+				p.steps = append(p.steps, step{key: key, isKey: true})
+				s = s[end+4:]
+				break
+			}
+			if end < 0 {
+				return errInvalid("missing ']'")
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return errInvalid("invalid index")
+			}
+			p.steps = append(p.steps, step{index: idx})
+			s = s[end+1:]
+
+		case first:
+			n := keyLen(s)
+			p.steps = append(p.steps, step{key: s[:n], isKey: true})
+			s = s[n:]
+
+		default:
+			return errInvalid(fmt.Sprintf("unexpected %q", s[0]))
+		}
+		first = false
+	}
+	return p, nil
+}
+
+// keyLen returns the length of the unquoted key at the start of s.
+func keyLen(s string) int {
+	n := strings.IndexAny(s, ".[")
+	if n < 0 {
+		return len(s)
+	}
+	return n
+}
+
+// String returns the path expression.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Eval returns the value selected by the path in the json document,
+// and false if the value is not found.
+// The document is the result of json.Unmarshal into an interface{} value.
+func (p *Path) Eval(doc interface{}) (interface{}, bool) {
+	v := doc
+	for _, st := range p.steps {
+		if st.isKey {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[st.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		idx := st.index
+		if idx < 0 {
+			idx += len(arr)
+		}
+		if idx < 0 || idx >= len(arr) {
+			return nil, false
+		}
+		v = arr[idx]
+	}
+	return v, true
+}
+
+// valueString returns the string representation of a scalar json value.
+// Null values are represented by the empty string.
+func valueString(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case json.Number:
+		return x.String(), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	}
+	return "", fmt.Errorf("value is not a scalar")
+}
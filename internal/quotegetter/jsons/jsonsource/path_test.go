@@ -0,0 +1,99 @@
+package jsonsource
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDoc = `{
+  "ticker": {"base": "BTC", "target": "EUR", "price": "11872.29709977"},
+  "timestamp": 1604159942,
+  "success": true,
+  "error": "",
+  "data": [
+    {"nav": 12.5, "date": "2020-10-01"},
+    {"nav": 12.75, "date": "2020-10-02"}
+  ],
+  "rates": {"EUR.X": 1.1, "USD": null},
+  "prices": [[1604159942000, 11872.3], [1604160000000, 11880.1]]
+}`
+
+func decodeTestDoc(t *testing.T) interface{} {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(testDoc))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&doc))
+	return doc
+}
+
+func TestPath(t *testing.T) {
+	doc := decodeTestDoc(t)
+
+	cases := []struct {
+		expr  string
+		want  string
+		found bool
+	}{
+		{"ticker.price", "11872.29709977", true},
+		{"$.ticker.base", "BTC", true},
+		{"timestamp", "1604159942", true},
+		{"success", "true", true},
+		{"error", "", true},
+		{"data[0].nav", "12.5", true},
+		{"data[-1].date", "2020-10-02", true},
+		{"data[ 1 ].nav", "12.75", true},
+		{`rates["EUR.X"]`, "1.1", true},
+		{`$["rates"].USD`, "", true},
+		{"prices[-1][1]", "11880.1", true},
+		{"ticker.missing", "", false},
+		{"data[2].nav", "", false},
+		{"data[-3].nav", "", false},
+		{"ticker[0]", "", false},
+		{"data.nav", "", false},
+	}
+
+	for _, c := range cases {
+		p, err := CompilePath(c.expr)
+		require.NoError(t, err, c.expr)
+		assert.Equal(t, c.expr, p.String())
+
+		v, found := p.Eval(doc)
+		if assert.Equal(t, c.found, found, c.expr) && found {
+			s, err := valueString(v)
+			if assert.NoError(t, err, c.expr) {
+				assert.Equal(t, c.want, s, c.expr)
+			}
+		}
+	}
+}
+
+func TestPathNotScalar(t *testing.T) {
+	doc := decodeTestDoc(t)
+	p, err := CompilePath("ticker")
+	require.NoError(t, err)
+	v, found := p.Eval(doc)
+	require.True(t, found)
+	_, err = valueString(v)
+	assert.Error(t, err)
+}
+
+func TestCompilePathErrors(t *testing.T) {
+	cases := map[string]string{
+		"ticker..price": "empty key",
+		"ticker.":       "empty key",
+		"data[0":        "missing ']'",
+		"data[x]":       "invalid index",
+		`rates["EUR`:    "unterminated quoted key",
+		"data[0]x":      "unexpected",
+	}
+	for expr, errmsg := range cases {
+		_, err := CompilePath(expr)
+		if assert.Error(t, err, expr) {
+			assert.Contains(t, err.Error(), errmsg, expr)
+		}
+	}
+}
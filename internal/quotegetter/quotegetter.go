@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/mmbros/quote/pkg/decimal"
 )
 
 // QuoteGetter interface
@@ -20,7 +22,7 @@ type Result struct {
 	Source   string
 	Isin     string
 	URL      string
-	Price    float32
+	Price    decimal.Decimal
 	Currency string
 	Date     time.Time
 }
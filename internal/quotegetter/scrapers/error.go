@@ -89,4 +89,5 @@ var (
 	ErrInfoRequestIsNil = errors.New("info request is nil")
 	ErrPriceNotFound    = errors.New("price not found")
 	ErrDateNotFound     = errors.New("date not found")
+	ErrInvalidNumber    = errors.New("invalid number")
 )
@@ -11,6 +11,9 @@ import (
 	"github.com/mmbros/quote/internal/quotegetter/scrapers"
 )
 
+// dateSpec declares the NAV dates of the source, in the Europe/Rome time zone.
+var dateSpec = quotegetter.DateSpec{Location: quotegetter.MustLoadLocation("Europe/Rome")}
+
 // scraper gets stock/fund prices from fondidoc.it
 type scraper struct {
 	name   string
@@ -118,6 +121,8 @@ func (s *scraper) ParseInfo(doc *goquery.Document, isin string) (*scrapers.Parse
 
 	r := new(scrapers.ParseInfoResult)
 	r.DateLayout = "02/01/2006"
+	r.DateSpec = dateSpec
+	r.NumberFormat = scrapers.NumberFormatIT
 
 	r.IsinStr = doc.Find("div.page-header small").Text()
 
@@ -11,6 +11,9 @@ import (
 	"github.com/mmbros/quote/internal/quotegetter/scrapers"
 )
 
+// dateSpec declares the NAV dates of the source, in the Europe/Luxembourg time zone.
+var dateSpec = quotegetter.DateSpec{Location: quotegetter.MustLoadLocation("Europe/Luxembourg")}
+
 // scraper gets stock/fund prices from fundsquare.net
 type scraper struct {
 	name   string
@@ -145,6 +148,8 @@ func (s *scraper) ParseInfo(doc *goquery.Document, isin string) (*scrapers.Parse
 
 	r := new(scrapers.ParseInfoResult)
 	r.DateLayout = "02/01/2006"
+	r.DateSpec = dateSpec
+	r.NumberFormat = scrapers.NumberFormatEN
 	var txtPriceCurrency string
 
 	isLastNavAvailable := false
@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	neturl "net/url"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/decimal"
 )
 
 // Scraper interface
@@ -30,6 +30,12 @@ type ParseInfoResult struct {
 	CurrencyStr string
 	DateStr     string
 	DateLayout  string
+
+	// DateSpec is the time zone and the kind of the date.
+	DateSpec quotegetter.DateSpec
+
+	// NumberFormat is the format of the price.
+	NumberFormat NumberFormat
 }
 
 // quoteGetter is ...
@@ -88,13 +94,13 @@ func getInfoFromDoc(docInfo *goquery.Document, isin, url string, scr Scraper) (*
 	}
 
 	// parse price
-	vPrice, err := parsePrice(pir.PriceStr)
+	vPrice, err := parsePrice(pir.PriceStr, pir.NumberFormat)
 	if err != nil {
 		return theError(err, InvalidPriceError)
 	}
 
 	// parse date
-	vDate, err := parseDate(pir.DateStr, pir.DateLayout)
+	vDate, err := parseDate(pir.DateStr, pir.DateLayout, pir.DateSpec)
 	if err != nil {
 		return theError(err, InvalidDateError)
 	}
@@ -157,6 +163,10 @@ func getQuote(ctx context.Context, isin, url string, scr Scraper) (*quotegetter.
 			// docSearch, err = goquery.NewDocumentFromResponse(respSearch)
 			doc, err = goquery.NewDocumentFromReader(resp.Body)
 			// err != nil is handled below
+			if err == nil {
+				// used by ParseSearch to resolve the relative urls
+				doc.Url = resp.Request.URL
+			}
 		}
 
 		if err == nil {
@@ -218,24 +228,18 @@ func getQuote(ctx context.Context, isin, url string, scr Scraper) (*quotegetter.
 // ============================================================================
 // aux functions
 
-func parseDate(str, layout string) (time.Time, error) {
-	var t time.Time
+func parseDate(str, layout string, spec quotegetter.DateSpec) (time.Time, error) {
 	if str == "" {
-		return t, ErrDateNotFound
-	}
-	loc, err := time.LoadLocation("Europe/Rome")
-	if err != nil {
-		return t, err
+		return time.Time{}, ErrDateNotFound
 	}
-	return time.ParseInLocation(layout, str, loc)
+	return spec.Parse(layout, str)
 }
 
-func parsePrice(str string) (float32, error) {
+func parsePrice(str string, nf NumberFormat) (decimal.Decimal, error) {
 	if str == "" {
-		return 0.0, ErrPriceNotFound
+		return "", ErrPriceNotFound
 	}
-	price, err := strconv.ParseFloat(strings.Replace(str, ",", ".", 1), 32)
-	return float32(price), err
+	return nf.Parse(str)
 }
 
 // SplitPriceCurrency is ...
@@ -13,13 +13,14 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/mmbros/quote/internal/quotegetter/scrapers/testingscraper"
 	"github.com/mmbros/quote/internal/quotetesting"
+	"github.com/mmbros/quote/pkg/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 type testCaseGetQuote struct {
 	title    string
-	price    float32
+	price    decimal.Decimal
 	currency string
 	date     time.Time
 	// err      error
@@ -29,19 +30,19 @@ type testCaseGetQuote struct {
 var testCasesGetQuote = map[string]*testCaseGetQuote{
 	"ISIN00000001": {
 		title:    "ok",
-		price:    12.34,
+		price:    "12.34",
 		currency: "EUR",
 		date:     time.Date(2020, time.February, 23, 0, 0, 0, 0, time.UTC),
 	},
 	"ISIN00000002": {
 		title:    "ok, abs-url",
-		price:    12.34,
+		price:    "12.34",
 		currency: "EUR",
 		date:     time.Date(2020, time.February, 23, 0, 0, 0, 0, time.UTC),
 	},
 	"ISIN00000003": {
 		title:    "ko-no-price",
-		price:    0,
+		price:    "",
 		currency: "EUR",
 		date:     time.Date(2020, time.February, 23, 0, 0, 0, 0, time.UTC),
 		// err:      ErrPriceNotFound,
@@ -49,7 +50,7 @@ var testCasesGetQuote = map[string]*testCaseGetQuote{
 	},
 	"ISIN00000004": {
 		title:    "ko-no-date",
-		price:    123,
+		price:    "123",
 		currency: "EUR",
 		// err:      ErrDateNotFound,
 		errstr: "date not found",
@@ -61,7 +62,7 @@ var testCasesGetQuote = map[string]*testCaseGetQuote{
 	},
 	"ISIN00000006": {
 		title:    "ko, isin-mismatch",
-		price:    12.34,
+		price:    "12.34",
 		currency: "EUR",
 		date:     time.Date(2020, time.February, 23, 0, 0, 0, 0, time.UTC),
 		// err:      ErrIsinMismatch,
@@ -189,8 +190,8 @@ newline`
 	if !tc.date.IsZero() {
 		q.Set("date", tc.date.Format(time.RFC3339)[:10])
 	}
-	if tc.price > 0.000001 {
-		q.Set("price", fmt.Sprintf("%f", tc.price))
+	if tc.price != "" {
+		q.Set("price", tc.price.String())
 	}
 	if len(tc.currency) > 0 {
 		q.Set("currency", tc.currency)
@@ -0,0 +1,331 @@
+// Package htmlsource implements a scraper defined declaratively,
+// without writing Go code: the urls of the search and info pages are
+// text/template strings, and the fields of the quote are extracted
+// from the info page using goquery (CSS) selectors.
+//
+// Example of a definition in yaml format:
+//
+//	kinds: [fund]
+//	base_url: https://www.example.com
+//	search:
+//	  url: https://www.example.com/search?q={{.Isin}}
+//	  link: "table.results td.name > a"
+//	info:
+//	  isin: "h1 small"
+//	  price: "div.nav span.value"
+//	  currency: "div.nav span.currency"
+//	  date: "div.nav span.date"
+//	  date_layout: "02/01/2006"
+//
+// If the search section is missing, the info url must be defined.
+// If the currency selector is missing, the text selected by the price
+// selector is splitted in price and currency (see scrapers.SplitPriceCurrency).
+package htmlsource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"text/template"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers"
+)
+
+// defaultLinkAttr is the attribute containing the info url
+// of the element selected in the search page.
+const defaultLinkAttr = "href"
+
+// Definition is the declarative definition of a scraper source.
+type Definition struct {
+	// Kinds is the list of the asset kinds supported by the source.
+	Kinds []string `json:"kinds,omitempty"`
+
+	// BaseURL is the url of the site of the source.
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url" toml:"base_url"`
+
+	// Search defines how to get the url of the info page.
+	// If nil, the info url is built from the Info.URL template.
+	Search *SearchDefinition `json:"search,omitempty"`
+
+	// Info defines how to get the quote from the info page.
+	Info *InfoDefinition `json:"info"`
+}
+
+// SearchDefinition defines the search page of a scraper source.
+type SearchDefinition struct {
+	// URL is the template of the url of the search page.
+	// The template data has the Isin field, escaped as a query parameter.
+	URL string `json:"url"`
+
+	// Headers are the http headers of the request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Link is the selector of the element containing the info url.
+	// A relative url is resolved against the url of the search page.
+	Link string `json:"link"`
+
+	// LinkAttr is the attribute of the Link element containing the info url.
+	// Default is "href".
+	LinkAttr string `json:"link_attr,omitempty" yaml:"link_attr" toml:"link_attr"`
+}
+
+// InfoDefinition defines the info page of a scraper source.
+type InfoDefinition struct {
+	// URL is the template of the url of the info page.
+	// Mandatory if the search page is not defined, ignored otherwise.
+	// The template data has the Isin field, escaped as a path segment.
+	URL string `json:"url,omitempty"`
+
+	// Headers are the http headers of the request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Selectors of the elements containing the isin, price, currency and date.
+	// If Isin is empty, the isin is not checked.
+	// If Currency is empty, the Price element must contain both price and currency.
+	Isin     string `json:"isin,omitempty"`
+	Price    string `json:"price"`
+	Currency string `json:"currency,omitempty"`
+	Date     string `json:"date"`
+
+	// DateLayout is the layout used to parse the date (see time.Parse).
+	DateLayout string `json:"date_layout" yaml:"date_layout" toml:"date_layout"`
+
+	// TimeZone is the time zone of the dates without zone offset,
+	// e.g. "Europe/Rome". Default is UTC.
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone" toml:"time_zone"`
+
+	// DateKind is the meaning of the date: "nav", the calendar day
+	// of the price, or "timestamp", the instant of the price. Default is "nav".
+	DateKind string `json:"date_kind,omitempty" yaml:"date_kind" toml:"date_kind"`
+
+	// PriceFirst specifies if the price precedes the currency
+	// in the text of the Price element. Used only if Currency is empty.
+	PriceFirst bool `json:"price_first,omitempty" yaml:"price_first" toml:"price_first"`
+
+	// Locale of the price, e.g. "it" or "en-US".
+	// DecimalSeparator and GroupingSeparator override the separators of the locale.
+	// By default the decimal separator is either a comma or a dot,
+	// without grouping separator.
+	Locale            string `json:"locale,omitempty"`
+	DecimalSeparator  string `json:"decimal_separator,omitempty" yaml:"decimal_separator" toml:"decimal_separator"`
+	GroupingSeparator string `json:"grouping_separator,omitempty" yaml:"grouping_separator" toml:"grouping_separator"`
+}
+
+// templateData is the data used to execute the url templates.
+type templateData struct {
+	Isin string
+}
+
+// compiled is a checked definition, with the parsed url templates.
+type compiled struct {
+	def          *Definition
+	searchURL    *template.Template
+	infoURL      *template.Template
+	searchLink   string
+	searchAttr   string
+	numberFormat scrapers.NumberFormat
+	dateSpec     quotegetter.DateSpec
+}
+
+// scraper gets stock/fund prices as specified by the definition.
+type scraper struct {
+	name   string
+	client *http.Client
+	*compiled
+}
+
+// NewQuoteGetterFunc checks the definition and returns the function
+// that creates the QuoteGetter of the source.
+func NewQuoteGetterFunc(def *Definition) (func(name string, client *http.Client) quotegetter.QuoteGetter, error) {
+	c, err := compile(def)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string, client *http.Client) quotegetter.QuoteGetter {
+		return scrapers.NewQuoteGetter(&scraper{name, client, c})
+	}, nil
+}
+
+func compile(def *Definition) (*compiled, error) {
+	var err error
+
+	if def == nil {
+		return nil, errors.New("definition not defined")
+	}
+	if def.Info == nil {
+		return nil, errors.New("info not defined")
+	}
+
+	c := &compiled{def: def}
+
+	if def.Search != nil {
+		if def.Search.URL == "" {
+			return nil, errors.New("search url not defined")
+		}
+		if c.searchURL, err = template.New("search").Parse(def.Search.URL); err != nil {
+			return nil, fmt.Errorf("invalid search url: %w", err)
+		}
+		if def.Search.Link == "" {
+			return nil, errors.New("search link selector not defined")
+		}
+		c.searchLink = def.Search.Link
+		c.searchAttr = def.Search.LinkAttr
+		if c.searchAttr == "" {
+			c.searchAttr = defaultLinkAttr
+		}
+	} else {
+		if def.Info.URL == "" {
+			return nil, errors.New("info url not defined")
+		}
+		if c.infoURL, err = template.New("info").Parse(def.Info.URL); err != nil {
+			return nil, fmt.Errorf("invalid info url: %w", err)
+		}
+	}
+
+	if def.Info.Price == "" {
+		return nil, errors.New("price selector not defined")
+	}
+	if def.Info.Date == "" {
+		return nil, errors.New("date selector not defined")
+	}
+	if def.Info.DateLayout == "" {
+		return nil, errors.New("date layout not defined")
+	}
+	if c.dateSpec, err = quotegetter.NewDateSpec(def.Info.TimeZone, def.Info.DateKind); err != nil {
+		return nil, err
+	}
+	c.numberFormat, err = scrapers.NewNumberFormat(def.Info.Locale, def.Info.DecimalSeparator, def.Info.GroupingSeparator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price format: %w", err)
+	}
+
+	return c, nil
+}
+
+// execute returns the url built from the template for the isin,
+// escaped with the escape function.
+func execute(tmpl *template.Template, isin string, escape func(string) string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &templateData{Isin: escape(isin)}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// newRequest creates a GET request with the given headers.
+func newRequest(ctx context.Context, url string, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// selectText returns the trimmed text of the first element matching the selector.
+func selectText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	return strings.TrimSpace(doc.Find(selector).First().Text())
+}
+
+// Source returns the name of the scraper
+func (s *scraper) Source() string {
+	return s.name
+}
+
+// Client returns the http.Client of the scraper
+func (s *scraper) Client() *http.Client {
+	return s.client
+}
+
+// GetSearch creates the http.Request to get the search page for the specified `isin`.
+// It returns nil if the search page is not defined:
+// in that case the url of the info page is built directly from the `isin`.
+func (s *scraper) GetSearch(ctx context.Context, isin string) (*http.Request, error) {
+	if s.searchURL == nil {
+		return nil, nil
+	}
+	url, err := execute(s.searchURL, isin, neturl.QueryEscape)
+	if err != nil {
+		return nil, err
+	}
+	return newRequest(ctx, url, s.def.Search.Headers)
+}
+
+// ParseSearch parse the html of the search page to find the URL of the info page.
+// `doc` is nil if the search page is not defined.
+// A relative URL is resolved against the URL of the document, if set,
+// or else against the URL of the search page built from the `isin`.
+func (s *scraper) ParseSearch(doc *goquery.Document, isin string) (string, error) {
+	if doc == nil {
+		return execute(s.infoURL, isin, neturl.PathEscape)
+	}
+
+	link, ok := doc.Find(s.searchLink).First().Attr(s.searchAttr)
+	link = strings.TrimSpace(link)
+	if !ok || link == "" {
+		return "", scrapers.ErrNoResultFound
+	}
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	base := doc.Url
+	if base == nil {
+		url, err := execute(s.searchURL, isin, neturl.QueryEscape)
+		if err != nil {
+			return "", err
+		}
+		if base, err = neturl.Parse(url); err != nil {
+			return "", err
+		}
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// GetInfo creates the http.Request to get the info page.
+func (s *scraper) GetInfo(ctx context.Context, isin, url string) (*http.Request, error) {
+	return newRequest(ctx, url, s.def.Info.Headers)
+}
+
+// ParseInfo extracts the isin, price, currency and date from the info page.
+func (s *scraper) ParseInfo(doc *goquery.Document, isin string) (*scrapers.ParseInfoResult, error) {
+	info := s.def.Info
+
+	r := &scrapers.ParseInfoResult{
+		DateLayout:   info.DateLayout,
+		DateStr:      selectText(doc, info.Date),
+		PriceStr:     selectText(doc, info.Price),
+		IsinStr:      isin,
+		NumberFormat: s.numberFormat,
+		DateSpec:     s.dateSpec,
+	}
+	if info.Isin != "" {
+		r.IsinStr = selectText(doc, info.Isin)
+	}
+
+	if r.DateStr == "" && r.PriceStr == "" {
+		return r, scrapers.ErrNoResultFound
+	}
+
+	if info.Currency != "" {
+		r.CurrencyStr = selectText(doc, info.Currency)
+		return r, nil
+	}
+
+	// split price and currency
+	var err error
+	r.PriceStr, r.CurrencyStr, err = scrapers.SplitPriceCurrency(r.PriceStr, info.PriceFirst)
+	return r, err
+}
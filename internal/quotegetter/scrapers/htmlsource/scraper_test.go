@@ -0,0 +1,494 @@
+package htmlsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers"
+	"github.com/mmbros/quote/internal/quotegetter/scrapers/testingscraper"
+)
+
+// getTestScraper returns the scraper of the definition.
+// It panics if the definition is not valid.
+func getTestScraper(def *Definition) *scraper {
+	c, err := compile(def)
+	if err != nil {
+		panic(err)
+	}
+	return &scraper{"htmlsource", nil, c}
+}
+
+// searchScraper returns a scraper with the search page.
+func searchScraper() *scraper {
+	return getTestScraper(&Definition{
+		Search: &SearchDefinition{
+			URL:     "http://127.0.0.1/search?q={{.Isin}}",
+			Headers: map[string]string{"X-Test": "search"},
+			Link:    "table.results td.name > a",
+		},
+		Info: &InfoDefinition{
+			Headers:    map[string]string{"X-Test": "info"},
+			Price:      "div.nav span.pricecurrency",
+			Date:       "div.nav span.date",
+			DateLayout: "02/01/2006",
+		},
+	})
+}
+
+func TestSource(t *testing.T) {
+	const expected = "dummy"
+	scr := &scraper{expected, nil, nil}
+	if actual := scr.Source(); actual != expected {
+		t.Errorf("Source: expected %q, found %q", expected, actual)
+	}
+}
+
+func TestGetSearch(t *testing.T) {
+	scr := searchScraper()
+	req, err := testingscraper.TestGetSearch(t, "", scr)
+	if err != nil {
+		return
+	}
+	url := "http://127.0.0.1/search?q=" + testingscraper.TestIsin
+	if requrl := req.URL.String(); requrl != url {
+		t.Errorf("GetSearch: invalid URL: expected %q, found %q", url, requrl)
+	}
+	if h := req.Header.Get("X-Test"); h != "search" {
+		t.Errorf("GetSearch: header: expected %q, found %q", "search", h)
+	}
+
+	// the isin is escaped
+	req, err = scr.GetSearch(context.Background(), "A&B C")
+	url = "http://127.0.0.1/search?q=A%26B+C"
+	if err != nil {
+		t.Errorf("GetSearch[escape]: unexpected error %v", err)
+	} else if requrl := req.URL.String(); requrl != url {
+		t.Errorf("GetSearch[escape]: invalid URL: expected %q, found %q", url, requrl)
+	}
+
+	// without search page
+	scr = getTestScraper(&Definition{
+		Info: &InfoDefinition{
+			URL:        "http://127.0.0.1/info/{{.Isin}}",
+			Price:      "div.nav span.pricecurrency",
+			Date:       "div.nav span.date",
+			DateLayout: "02/01/2006",
+		},
+	})
+	req, err = scr.GetSearch(context.Background(), testingscraper.TestIsin)
+	if err != nil || req != nil {
+		t.Errorf("GetSearch: without search page: expected nil request, found %v, %v", req, err)
+	}
+}
+
+func TestParseSearch(t *testing.T) {
+
+	testCases := []struct {
+		title    string
+		linkAttr string
+		docURL   string
+		url      string
+		err      error
+		html     string
+	}{
+		{
+			title: "ok",
+			url:   "http://127.0.0.1/info/ISIN00001234",
+			html: `<table class="results">
+<tr><td class="name"><a href=" /info/ISIN00001234 " data-url="/data/ISIN00001234">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title:    "link attr",
+			linkAttr: "data-url",
+			url:      "http://127.0.0.1/data/ISIN00001234",
+			html: `<table class="results">
+<tr><td class="name"><a href="/info/ISIN00001234" data-url="/data/ISIN00001234">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title: "relative link",
+			url:   "http://127.0.0.1/info?id=ISIN00001234",
+			html: `<table class="results">
+<tr><td class="name"><a href="info?id=ISIN00001234">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title:  "relative link of the document",
+			docURL: "https://www.example.com/funds/search?q=ISIN00001234",
+			url:    "https://www.example.com/funds/info?id=ISIN00001234",
+			html: `<table class="results">
+<tr><td class="name"><a href="info?id=ISIN00001234">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title: "absolute link",
+			url:   "https://www.example.com/info/ISIN00001234",
+			html: `<table class="results">
+<tr><td class="name"><a href="https://www.example.com/info/ISIN00001234">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title: "ko-empty-link",
+			err:   scrapers.ErrNoResultFound,
+			html: `<table class="results">
+<tr><td class="name"><a href="">Fund</a></td></tr>
+</table>`,
+		},
+		{
+			title: "ko-no-result",
+			err:   scrapers.ErrNoResultFound,
+			html:  `<p>No result found</p>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		prefix := fmt.Sprintf("ParseSearch[%s]", tc.title)
+
+		scr := searchScraper()
+		if tc.linkAttr != "" {
+			scr.searchAttr = tc.linkAttr
+		}
+
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.html))
+		if testingscraper.CheckError(t, prefix+": Goquery", err, nil) {
+			continue
+		}
+		if tc.docURL != "" {
+			doc.Url, _ = neturl.Parse(tc.docURL)
+		}
+
+		url, err := scr.ParseSearch(doc, testingscraper.TestIsin)
+		if testingscraper.CheckError(t, prefix, err, tc.err) {
+			continue
+		}
+
+		if url != tc.url {
+			t.Errorf("%s: URL expected %q, found %q", prefix, tc.url, url)
+		}
+	}
+
+	// without search page, the info url is built from the isin
+	scr := getTestScraper(&Definition{
+		Info: &InfoDefinition{
+			URL:        "http://127.0.0.1/info/{{.Isin}}",
+			Price:      "div.nav span.pricecurrency",
+			Date:       "div.nav span.date",
+			DateLayout: "02/01/2006",
+		},
+	})
+	url, err := scr.ParseSearch(nil, testingscraper.TestIsin)
+	if !testingscraper.CheckError(t, "ParseSearch[info url]", err, nil) && url != testingscraper.TestInfoURL {
+		t.Errorf("ParseSearch[info url]: URL expected %q, found %q", testingscraper.TestInfoURL, url)
+	}
+	url, err = scr.ParseSearch(nil, "A/B C")
+	if expected := "http://127.0.0.1/info/A%2FB%20C"; err != nil || url != expected {
+		t.Errorf("ParseSearch[info url escape]: URL expected %q, found %q, %v", expected, url, err)
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	scr := searchScraper()
+	req, err := testingscraper.TestGetInfo(t, "", scr)
+	if err != nil {
+		return
+	}
+	if h := req.Header.Get("X-Test"); h != "info" {
+		t.Errorf("GetInfo: header: expected %q, found %q", "info", h)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+
+	const html = `<h1>Fund <small>ISIN00005678</small></h1>
+<div class="nav">
+  <span class="date">23/02/2020</span>
+  <span class="value">1.234,56</span>
+  <span class="currency">EUR</span>
+  <span class="pricecurrency">12,34&nbsp;EUR</span>
+  <span class="currencyprice">USD 12.34</span>
+</div>`
+
+	testCases := []struct {
+		title       string
+		info        *InfoDefinition
+		isinStr     string
+		priceStr    string
+		currencyStr string
+		dateStr     string
+		err         error
+		html        string
+	}{
+		{
+			title: "price first",
+			info: &InfoDefinition{
+				Isin:       "h1 small",
+				Price:      "div.nav span.pricecurrency",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+				PriceFirst: true,
+			},
+			isinStr:     "ISIN00005678",
+			priceStr:    "12,34",
+			currencyStr: "EUR",
+			dateStr:     "23/02/2020",
+			html:        html,
+		},
+		{
+			title: "currency first",
+			info: &InfoDefinition{
+				Isin:       "h1 small",
+				Price:      "div.nav span.currencyprice",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+			},
+			isinStr:     "ISIN00005678",
+			priceStr:    "12.34",
+			currencyStr: "USD",
+			dateStr:     "23/02/2020",
+			html:        html,
+		},
+		{
+			title: "currency selector",
+			info: &InfoDefinition{
+				Isin:       "h1 small",
+				Price:      "div.nav span.value",
+				Currency:   "div.nav span.currency",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+			},
+			isinStr:     "ISIN00005678",
+			priceStr:    "1.234,56",
+			currencyStr: "EUR",
+			dateStr:     "23/02/2020",
+			html:        html,
+		},
+		{
+			title: "isin not checked",
+			info: &InfoDefinition{
+				Price:      "div.nav span.value",
+				Currency:   "div.nav span.currency",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+			},
+			isinStr:     testingscraper.TestIsin,
+			priceStr:    "1.234,56",
+			currencyStr: "EUR",
+			dateStr:     "23/02/2020",
+			html:        html,
+		},
+		{
+			title: "ko-price",
+			info: &InfoDefinition{
+				Price:      "div.missing",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+				PriceFirst: true,
+			},
+			err:  errors.New("Invalid price and currency string: \"\""),
+			html: html,
+		},
+		{
+			title: "ko-price-date",
+			info: &InfoDefinition{
+				Price:      "div.nav span.pricecurrency",
+				Date:       "div.nav span.date",
+				DateLayout: "02/01/2006",
+			},
+			err:  scrapers.ErrNoResultFound,
+			html: `<p>No result found</p>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		prefix := fmt.Sprintf("ParseInfo[%s]", tc.title)
+
+		// the info url is not used to parse the info page
+		info := *tc.info
+		info.URL = testingscraper.TestInfoURL
+		scr := getTestScraper(&Definition{Info: &info})
+
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.html))
+		if testingscraper.CheckError(t, prefix+": Goquery", err, nil) {
+			continue
+		}
+
+		res, err := scr.ParseInfo(doc, testingscraper.TestIsin)
+		if testingscraper.CheckError(t, prefix, err, tc.err) {
+			continue
+		}
+
+		if res.IsinStr != tc.isinStr {
+			t.Errorf("%s: IsinStr: expected %q, found %q", prefix, tc.isinStr, res.IsinStr)
+		}
+		if res.PriceStr != tc.priceStr {
+			t.Errorf("%s: PriceStr: expected %q, found %q", prefix, tc.priceStr, res.PriceStr)
+		}
+		if res.CurrencyStr != tc.currencyStr {
+			t.Errorf("%s: CurrencyStr: expected %q, found %q", prefix, tc.currencyStr, res.CurrencyStr)
+		}
+		if res.DateStr != tc.dateStr {
+			t.Errorf("%s: DateStr: expected %q, found %q", prefix, tc.dateStr, res.DateStr)
+		}
+		if res.DateLayout != tc.info.DateLayout {
+			t.Errorf("%s: DateLayout: expected %q, found %q", prefix, tc.info.DateLayout, res.DateLayout)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+
+	testCases := []struct {
+		title        string
+		info         *InfoDefinition
+		numberFormat scrapers.NumberFormat
+		location     string
+	}{
+		{
+			title: "default",
+			info: &InfoDefinition{
+				URL:        "http://127.0.0.1/info/{{.Isin}}",
+				Price:      "span.value",
+				Date:       "span.date",
+				DateLayout: "02/01/2006",
+			},
+			numberFormat: scrapers.NumberFormat{},
+			location:     "UTC",
+		},
+		{
+			title: "locale",
+			info: &InfoDefinition{
+				URL:        "http://127.0.0.1/info/{{.Isin}}",
+				Price:      "span.value",
+				Date:       "span.date",
+				DateLayout: "02/01/2006",
+				Locale:     "it_IT",
+			},
+			numberFormat: scrapers.NumberFormat{Decimal: ',', Grouping: '.'},
+			location:     "UTC",
+		},
+		{
+			title: "separators",
+			info: &InfoDefinition{
+				URL:               "http://127.0.0.1/info/{{.Isin}}",
+				Price:             "span.value",
+				Date:              "span.date",
+				DateLayout:        "02/01/2006",
+				DecimalSeparator:  ",",
+				GroupingSeparator: " ",
+			},
+			numberFormat: scrapers.NumberFormat{Decimal: ',', Grouping: ' '},
+			location:     "UTC",
+		},
+		{
+			title: "time zone",
+			info: &InfoDefinition{
+				URL:        "http://127.0.0.1/info/{{.Isin}}",
+				Price:      "span.value",
+				Date:       "span.date",
+				DateLayout: "02/01/2006",
+				TimeZone:   "Europe/Rome",
+			},
+			location: "Europe/Rome",
+		},
+	}
+
+	for _, tc := range testCases {
+		prefix := fmt.Sprintf("compile[%s]", tc.title)
+
+		c, err := compile(&Definition{Info: tc.info})
+		if testingscraper.CheckError(t, prefix, err, nil) {
+			continue
+		}
+		if c.numberFormat != tc.numberFormat {
+			t.Errorf("%s: NumberFormat: expected %+v, found %+v", prefix, tc.numberFormat, c.numberFormat)
+		}
+		if c.dateSpec.Kind != quotegetter.NAVDate {
+			t.Errorf("%s: DateSpec: expected kind %v, found %v", prefix, quotegetter.NAVDate, c.dateSpec.Kind)
+		}
+		location := "UTC"
+		if c.dateSpec.Location != nil {
+			location = c.dateSpec.Location.String()
+		}
+		if location != tc.location {
+			t.Errorf("%s: DateSpec: expected location %q, found %q", prefix, tc.location, location)
+		}
+	}
+}
+
+func TestNewQuoteGetterFunc(t *testing.T) {
+	fn, err := NewQuoteGetterFunc(searchScraper().def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qg := fn("dummy", nil)
+	if qg == nil {
+		t.Fatal("NewQuoteGetterFunc: returned nil")
+	}
+	if source := qg.Source(); source != "dummy" {
+		t.Errorf("Source: expected %q, found %q", "dummy", source)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	testCases := []struct {
+		title  string
+		def    *Definition
+		errmsg string
+	}{
+		{"no definition", nil, "definition not defined"},
+		{"no info", &Definition{}, "info not defined"},
+		{"no search url",
+			&Definition{Search: &SearchDefinition{Link: "a"}, Info: &InfoDefinition{}},
+			"search url not defined"},
+		{"invalid search url",
+			&Definition{Search: &SearchDefinition{URL: "http://x/{{.Isin", Link: "a"}, Info: &InfoDefinition{}},
+			"invalid search url"},
+		{"no search link",
+			&Definition{Search: &SearchDefinition{URL: "http://x/"}, Info: &InfoDefinition{}},
+			"search link selector not defined"},
+		{"no info url",
+			&Definition{Info: &InfoDefinition{}},
+			"info url not defined"},
+		{"no price",
+			&Definition{Info: &InfoDefinition{URL: "http://x/"}},
+			"price selector not defined"},
+		{"no date",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p"}},
+			"date selector not defined"},
+		{"no date layout",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d"}},
+			"date layout not defined"},
+		{"invalid time zone",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d", DateLayout: "2006", TimeZone: "Europe/Nowhere"}},
+			"invalid time zone"},
+		{"invalid date kind",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d", DateLayout: "2006", DateKind: "datetime"}},
+			"invalid date kind"},
+		{"unknown locale",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d", DateLayout: "2006", Locale: "xx"}},
+			"unknown locale"},
+		{"invalid decimal separator",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d", DateLayout: "2006", DecimalSeparator: ",."}},
+			"invalid decimal separator"},
+		{"same separators",
+			&Definition{Info: &InfoDefinition{URL: "http://x/", Price: "p", Date: "d", DateLayout: "2006", Locale: "it", GroupingSeparator: ","}},
+			"decimal and grouping separators are the same"},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewQuoteGetterFunc(tc.def)
+		if err == nil {
+			t.Errorf("%s: expected error %q, found <nil>", tc.title, tc.errmsg)
+		} else if !strings.Contains(err.Error(), tc.errmsg) {
+			t.Errorf("%s: expected error %q, found %q", tc.title, tc.errmsg, err)
+		}
+	}
+}
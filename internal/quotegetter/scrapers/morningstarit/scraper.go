@@ -10,6 +10,9 @@ import (
 	"github.com/mmbros/quote/internal/quotegetter/scrapers"
 )
 
+// dateSpec declares the NAV dates of the source, in the Europe/Rome time zone.
+var dateSpec = quotegetter.DateSpec{Location: quotegetter.MustLoadLocation("Europe/Rome")}
+
 // scraper gets stock/fund prices from www.morningstar.it
 type scraper struct {
 	name   string
@@ -114,6 +117,8 @@ func (s *scraper) ParseInfo(doc *goquery.Document, isin string) (*scrapers.Parse
 
 	r := new(scrapers.ParseInfoResult)
 	r.DateLayout = "02/01/2006"
+	r.DateSpec = dateSpec
+	r.NumberFormat = scrapers.NumberFormatIT
 	var txtPriceCurrency string
 
 	doc.Find("table.overviewKeyStatsTable td").EachWithBreak(func(i int, s *goquery.Selection) bool {
@@ -0,0 +1,231 @@
+package scrapers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mmbros/quote/pkg/decimal"
+)
+
+// NumberFormat specifies the separators used by a source to format the numbers.
+//
+// The zero value accepts either a comma or a dot as decimal separator,
+// without grouping separator: "126,370" and "126.370" are the same number.
+type NumberFormat struct {
+	// Decimal is the decimal separator.
+	Decimal rune
+	// Grouping is the grouping (thousands) separator, or 0 if not used.
+	// A space matches any kind of space, as the non-breaking space.
+	Grouping rune
+}
+
+// Number formats of the most common locales.
+var (
+	NumberFormatIT = NumberFormat{Decimal: ',', Grouping: '.'}
+	NumberFormatEN = NumberFormat{Decimal: '.', Grouping: ','}
+	NumberFormatFR = NumberFormat{Decimal: ',', Grouping: ' '}
+	NumberFormatCH = NumberFormat{Decimal: '.', Grouping: '\''}
+)
+
+// localeNumberFormats are the number formats by language
+// or by language and region, if the region changes the format.
+var localeNumberFormats = map[string]NumberFormat{
+	"it":    NumberFormatIT,
+	"de":    NumberFormatIT,
+	"es":    NumberFormatIT,
+	"nl":    NumberFormatIT,
+	"pt":    NumberFormatIT,
+	"en":    NumberFormatEN,
+	"fr":    NumberFormatFR,
+	"de-ch": NumberFormatCH,
+	"it-ch": NumberFormatCH,
+}
+
+// LocaleNumberFormat returns the NumberFormat of the locale,
+// e.g. "it", "en-US" or "de_CH".
+func LocaleNumberFormat(locale string) (NumberFormat, error) {
+	tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if nf, ok := localeNumberFormats[tag]; ok {
+		return nf, nil
+	}
+	if j := strings.IndexByte(tag, '-'); j > 0 {
+		if nf, ok := localeNumberFormats[tag[:j]]; ok {
+			return nf, nil
+		}
+	}
+	return NumberFormat{}, fmt.Errorf("unknown locale %q", locale)
+}
+
+// NewNumberFormat returns the NumberFormat of the locale,
+// with the decimal and grouping separators overridden if not empty.
+// Without locale, the grouping separator is not used by default.
+func NewNumberFormat(locale, decimalSep, groupingSep string) (NumberFormat, error) {
+	var (
+		nf  NumberFormat
+		err error
+	)
+	if locale != "" {
+		if nf, err = LocaleNumberFormat(locale); err != nil {
+			return nf, err
+		}
+	}
+
+	separator := func(name, sep string) (rune, error) {
+		r, size := utf8.DecodeRuneInString(sep)
+		if size != len(sep) || r == utf8.RuneError || unicode.IsDigit(r) || r == '-' || r == '+' {
+			return 0, fmt.Errorf("invalid %s separator %q", name, sep)
+		}
+		return normalizeRune(r), nil
+	}
+	if decimalSep != "" {
+		if nf.Decimal, err = separator("decimal", decimalSep); err != nil {
+			return nf, err
+		}
+	}
+	if groupingSep != "" {
+		if nf.Grouping, err = separator("grouping", groupingSep); err != nil {
+			return nf, err
+		}
+	}
+
+	if nf.Grouping != 0 && nf.Decimal == 0 {
+		return nf, fmt.Errorf("decimal separator not defined with grouping separator %q", groupingSep)
+	}
+	if nf.Grouping == nf.Decimal && nf.Decimal != 0 {
+		return nf, fmt.Errorf("decimal and grouping separators are the same %q", nf.Decimal)
+	}
+	return nf, nil
+}
+
+// normalizeRune replaces any kind of space with a space,
+// the unicode minus sign with a hyphen
+// and the right single quotation mark with an apostrophe.
+func normalizeRune(r rune) rune {
+	switch {
+	case unicode.IsSpace(r):
+		return ' '
+	case r == '−':
+		return '-'
+	case r == '’':
+		return '\''
+	}
+	return r
+}
+
+// isSymbol returns true if r is a letter or a currency symbol,
+// as the currency that precedes or follows the number.
+func isSymbol(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Sc, r)
+}
+
+// isDigits returns true if s contains only decimal digits.
+func isDigits(s string) bool {
+	for j := 0; j < len(s); j++ {
+		if s[j] < '0' || s[j] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse returns the number represented by the string s.
+//
+// The currency symbols or codes and the spaces that precede or follow
+// the number are ignored: e.g. "EUR 1.234,56" or "1 234,56 €".
+// The number is negative if it has a leading or trailing minus sign,
+// as "-12,5" and "12,5-", or if it is enclosed in parentheses without sign,
+// as "(12,5)". A percentage is returned as a fraction: "12,5%" is 0.125.
+// The grouping separators must divide the integer part in groups of three digits.
+func (nf NumberFormat) Parse(s string) (decimal.Decimal, error) {
+	invalid := func() (decimal.Decimal, error) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+
+	str := strings.Map(normalizeRune, s)
+
+	// remove the currency, the sign, the percent and the parentheses
+	var neg, signed, percent, paren bool
+loop:
+	for {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			return invalid()
+		}
+		first, firstSize := utf8.DecodeRuneInString(str)
+		last, lastSize := utf8.DecodeLastRuneInString(str)
+
+		switch {
+		case isSymbol(first):
+			str = str[firstSize:]
+			continue
+		case isSymbol(last):
+			str = str[:len(str)-lastSize]
+			continue
+		}
+
+		switch {
+		case (first == '-' || first == '+') && !signed:
+			neg, signed = first == '-', true
+			str = str[firstSize:]
+		case last == '-' && !signed:
+			neg, signed = true, true
+			str = str[:len(str)-lastSize]
+		case (first == '%' || last == '%') && !percent:
+			percent = true
+			str = strings.TrimSuffix(strings.TrimPrefix(str, "%"), "%")
+		case first == '(' && last == ')' && !paren:
+			paren = true
+			str = str[firstSize : len(str)-lastSize]
+		default:
+			break loop
+		}
+	}
+
+	// split the integer and the fraction parts
+	intPart, fracPart := str, ""
+	var j int
+	if nf.Decimal == 0 {
+		j = strings.IndexAny(str, ",.")
+	} else {
+		j = strings.IndexRune(str, nf.Decimal)
+	}
+	if j >= 0 {
+		_, size := utf8.DecodeRuneInString(str[j:])
+		intPart, fracPart = str[:j], str[j+size:]
+	}
+	if intPart == "" && fracPart == "" {
+		return invalid()
+	}
+	if !isDigits(fracPart) {
+		return invalid()
+	}
+
+	// remove the grouping separators
+	if nf.Grouping != 0 && strings.ContainsRune(intPart, nf.Grouping) {
+		groups := strings.Split(intPart, string(nf.Grouping))
+		for k, g := range groups {
+			if (k == 0 && (g == "" || len(g) > 3)) || (k > 0 && len(g) != 3) {
+				return invalid()
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+	if !isDigits(intPart) {
+		return invalid()
+	}
+
+	num := intPart + "." + fracPart
+	if neg || (paren && !signed) {
+		num = "-" + num
+	}
+	if percent {
+		num += "e-2"
+	}
+	d, err := decimal.Parse(num)
+	if err != nil {
+		return invalid()
+	}
+	return d, nil
+}
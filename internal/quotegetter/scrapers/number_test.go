@@ -0,0 +1,202 @@
+package scrapers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mmbros/quote/internal/quotegetter/scrapers/testingscraper"
+	"github.com/mmbros/quote/pkg/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocaleNumberFormat(t *testing.T) {
+	cases := map[string]struct {
+		want NumberFormat
+		err  bool
+	}{
+		"it":    {NumberFormatIT, false},
+		"it-IT": {NumberFormatIT, false},
+		"it_CH": {NumberFormatCH, false},
+		"en-US": {NumberFormatEN, false},
+		"FR":    {NumberFormatFR, false},
+		"xx":    {NumberFormat{}, true},
+		"":      {NumberFormat{}, true},
+	}
+	for locale, c := range cases {
+		nf, err := LocaleNumberFormat(locale)
+		if c.err {
+			assert.Error(t, err, locale)
+			continue
+		}
+		if assert.NoError(t, err, locale) {
+			assert.Equal(t, c.want, nf, locale)
+		}
+	}
+}
+
+func TestNewNumberFormat(t *testing.T) {
+	cases := map[string]struct {
+		locale, decimal, grouping string
+		want                      NumberFormat
+		errmsg                    string
+	}{
+		"default":              {want: NumberFormat{}},
+		"locale":               {locale: "en", want: NumberFormatEN},
+		"decimal only":         {decimal: ",", want: NumberFormat{Decimal: ','}},
+		"override grouping":    {locale: "it", grouping: "\u00a0", want: NumberFormat{Decimal: ',', Grouping: ' '}},
+		"override both":        {locale: "it", decimal: ".", grouping: "'", want: NumberFormatCH},
+		"unknown locale":       {locale: "xx", errmsg: "unknown locale"},
+		"long separator":       {decimal: ",,", errmsg: "invalid decimal separator"},
+		"digit separator":      {locale: "it", grouping: "0", errmsg: "invalid grouping separator"},
+		"grouping without dec": {grouping: ".", errmsg: "decimal separator not defined"},
+		"same separators":      {decimal: ",", grouping: ",", errmsg: "are the same"},
+	}
+	for title, c := range cases {
+		nf, err := NewNumberFormat(c.locale, c.decimal, c.grouping)
+		if c.errmsg != "" {
+			if assert.Error(t, err, title) {
+				assert.Contains(t, err.Error(), c.errmsg, title)
+			}
+			continue
+		}
+		if assert.NoError(t, err, title) {
+			assert.Equal(t, c.want, nf, title)
+		}
+	}
+}
+
+func TestNumberFormatParse(t *testing.T) {
+	cases := []struct {
+		nf   NumberFormat
+		s    string
+		want decimal.Decimal
+	}{
+		// default format
+		{NumberFormat{}, "126,370", "126.370"},
+		{NumberFormat{}, "12.34", "12.34"},
+		{NumberFormat{}, "123", "123"},
+		{NumberFormat{}, "1.234,56", ""},
+		{NumberFormat{}, "1 234", ""},
+
+		// grouping separator
+		{NumberFormatIT, "1.234,56", "1234.56"},
+		{NumberFormatIT, "1.234.567", "1234567"},
+		{NumberFormatIT, "1234,5", "1234.5"},
+		{NumberFormatIT, ",5", "0.5"},
+		{NumberFormatIT, "1,234.56", ""},
+		{NumberFormatIT, "12.34", ""},
+		{NumberFormatIT, "1234.567,8", ""},
+		{NumberFormatIT, ".234", ""},
+		{NumberFormatEN, "1,234.56", "1234.56"},
+		{NumberFormatEN, "1.234,56", ""},
+		{NumberFormatCH, "1'234.50", "1234.50"},
+		{NumberFormatCH, "1’234.50", "1234.50"},
+
+		// spaces
+		{NumberFormatFR, "1 234,56", "1234.56"},
+		{NumberFormatFR, "1\u00a0234,56", "1234.56"},
+		{NumberFormatFR, "1\u202f234\u202f567,8", "1234567.8"},
+		{NumberFormatIT, "\u00a0 12,5\u00a0", "12.5"},
+		{NumberFormatIT, "1 234,56", ""},
+
+		// currencies
+		{NumberFormatIT, "EUR\u00a01.234,56", "1234.56"},
+		{NumberFormatIT, "1.234,56 €", "1234.56"},
+		{NumberFormatEN, "$1,234.56", "1234.56"},
+		{NumberFormatEN, "£ 12", "12"},
+		{NumberFormatFR, "12,50 CHF", "12.50"},
+
+		// negative numbers
+		{NumberFormatIT, "-12,5", "-12.5"},
+		{NumberFormatIT, "+12,5", "12.5"},
+		{NumberFormatIT, "12,5-", "-12.5"},
+		{NumberFormatIT, "−12,5", "-12.5"},
+		{NumberFormatIT, "(12,5)", "-12.5"},
+		{NumberFormatEN, "($1,234.56)", "-1234.56"},
+		{NumberFormatEN, "-$12.50", "-12.50"},
+		{NumberFormatEN, "$ -12.50", "-12.50"},
+		{NumberFormatEN, "(-0.46%)", "-0.0046"},
+		{NumberFormatEN, "(+0.46%)", "0.0046"},
+		{NumberFormatIT, "--12,5", ""},
+		{NumberFormatIT, "-12,5-", ""},
+		{NumberFormatIT, "(12,5", ""},
+
+		// percentages
+		{NumberFormatIT, "12,5%", "0.125"},
+		{NumberFormatIT, "-0,18 %", "-0.0018"},
+		{NumberFormatFR, "1 250 %", "12.50"},
+		{NumberFormatIT, "%", ""},
+
+		// invalid numbers
+		{NumberFormatIT, "", ""},
+		{NumberFormatIT, "-", ""},
+		{NumberFormatIT, "EUR", ""},
+		{NumberFormatIT, ",", ""},
+		{NumberFormatIT, "12,5,6", ""},
+		{NumberFormatIT, "1e5", ""},
+		{NumberFormatIT, "12 5", ""},
+	}
+
+	for _, c := range cases {
+		d, err := c.nf.Parse(c.s)
+		if c.want == "" {
+			if assert.Error(t, err, c.s) {
+				assert.True(t, errors.Is(err, ErrInvalidNumber), c.s)
+			}
+			continue
+		}
+		if assert.NoError(t, err, c.s) {
+			assert.Equal(t, c.want, d, c.s)
+		}
+	}
+}
+
+// TestNumberFormatParseFixtures parses the numbers of the saved pages
+// of the sources, as shown by each site.
+func TestNumberFormatParseFixtures(t *testing.T) {
+	cases := []struct {
+		relpath  string
+		selector string
+		index    int // index of the element matching the selector
+		field    int // 1-based field of the text of the element, 0 for the whole text
+		nf       NumberFormat
+		text     string
+		want     decimal.Decimal
+	}{
+		{"morningstar.it/info|IT0005247157|ok.html", "table.overviewKeyStatsTable td.line.text", 0, 0, NumberFormatIT, "EUR\u00a0126,370", "126.370"},
+		{"morningstar.it/info|IT0005247157|ok.html", "table.overviewKeyStatsTable td.line.text", 1, 0, NumberFormatIT, "0,24%", "0.0024"},
+		{"morningstar.it/info|IT0005247157|ok.html", "table.overviewKeyStatsTable td.value.number", 0, 0, NumberFormatIT, "0,00", "0.00"},
+		{"fondidoc.it/info|IE00B4TG9K96|ok.html", "div.dett-cont dd", 3, 0, NumberFormatIT, "11,400", "11.400"},
+		{"fondidoc.it/info|IE00B4TG9K96|ok.html", "div.dett-cont dd span.value-neg", 0, 0, NumberFormatIT, "-0,18%", "-0.0018"},
+		{"fundsquare.net/info|IE00B4TG9K96|ok.html", "div#content span.surligneorange", 0, 0, NumberFormatEN, "11.49\u00a0EUR", "11.49"},
+		{"fundsquare.net/info|IE00B4TG9K96|ok.html", `div#content span[style*="color:#000000"]`, 0, 0, NumberFormatEN, "0.00 \u00a0%", "0.0000"},
+		{"fundsquare.net/info|IE00B4TG9K96|unavailable.html", "div#content span.surligneorange", 0, 0, NumberFormatEN, "11.47\u00a0EUR", "11.47"},
+		{"finance.yahoo.com/info|IE00B4TG9K96|ok.html", `span[data-reactid="18"]`, 0, 0, NumberFormatEN, "3,292.88", "3292.88"},
+		{"finance.yahoo.com/info|IE00B4TG9K96|ok.html", `span[data-reactid="23"]`, 0, 0, NumberFormatEN, "-0.68%", "-0.0068"},
+		{"finance.yahoo.com/info|IE00B4TG9K96|ok.html", `span[data-reactid="32"]`, 0, 0, NumberFormatEN, "10.81", "10.81"},
+		{"finance.yahoo.com/info|IE00B4TG9K96|ok.html", `span[class*="Fz(24px)"]`, 0, 1, NumberFormatEN, "-0.05", "-0.05"},
+		{"finance.yahoo.com/info|IE00B4TG9K96|ok.html", `span[class*="Fz(24px)"]`, 0, 2, NumberFormatEN, "(-0.46%)", "-0.0046"},
+	}
+
+	for _, c := range cases {
+		title := c.relpath + " " + c.selector
+
+		doc, err := testingscraper.GetDoc(c.relpath)
+		require.NoError(t, err, title)
+
+		text := strings.TrimSpace(doc.Find(c.selector).Eq(c.index).Text())
+		if c.field > 0 {
+			fields := strings.Fields(text)
+			require.True(t, c.field <= len(fields), title)
+			text = fields[c.field-1]
+		}
+		assert.Equal(t, c.text, text, title)
+
+		d, err := c.nf.Parse(text)
+		if assert.NoError(t, err, title) {
+			assert.Equal(t, c.want, d, title)
+		}
+	}
+}
@@ -108,13 +108,24 @@ func NewDocumentFromZipFile(file *zip.File) (*goquery.Document, error) {
 	return goquery.NewDocumentFromReader(fc)
 }
 
+// getBaseDir returns the root folder of the module, containing the go.mod file,
+// whatever the name of the folder.
 func getBaseDir() string {
 	currentWorkingDirectory, err := os.Getwd()
 	if err != nil {
 		panic(err)
 	}
-	base := strings.SplitAfter(currentWorkingDirectory, "quote")[0]
-	return base
+	for dir := currentWorkingDirectory; ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return strings.SplitAfter(currentWorkingDirectory, "quote")[0]
 }
 
 // getFullPath returns the full path to the file "test/internal/quotescaper/<relpath>".
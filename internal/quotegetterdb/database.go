@@ -4,11 +4,23 @@ import (
 	"bytes"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3" // Import go-sqlite3 library
+	"github.com/mmbros/quote/pkg/decimal"
 )
 
+// schemaVersion is the version of the schema of the quote database,
+// saved in the user_version pragma:
+//
+//	0  price column of type DOUBLE
+//	1  price column of type TEXT, with the exact decimal price
+//	2  date column normalized: NAV dates at midnight UTC, other dates in UTC
+//	3  timestamp and datestamp columns in UTC
+const schemaVersion = 3
+
 // QuoteDatabase handles the database that store and retrieve quote informations.
 type QuoteDatabase struct {
 	dns string
@@ -22,7 +34,7 @@ type QuoteRecord struct {
 	Source    string
 	Timestamp time.Time
 	Date      time.Time
-	Price     float32
+	Price     decimal.Decimal
 	Currency  string
 	URL       string
 	ErrMsg    string
@@ -38,7 +50,7 @@ func (qr *QuoteRecord) String() string {
 		buf.WriteString(fmt.Sprintf(", date=%s", qr.Date.Format("2006-01-02")))
 	}
 	if len(qr.Currency) > 0 {
-		buf.WriteString(fmt.Sprintf(", price=%.3f %s", qr.Price, qr.Currency))
+		buf.WriteString(fmt.Sprintf(", price=%s %s", qr.Price, qr.Currency))
 	}
 	if len(qr.URL) > 0 {
 		buf.WriteString(fmt.Sprintf(", url=%q", qr.URL))
@@ -156,16 +168,309 @@ func (qdb *QuoteDatabase) Close() error {
 }
 
 func (qdb *QuoteDatabase) initDatabase() error {
-	if e := qdb.createTableQuotes(); e != nil {
+	var version int
+	if err := qdb.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return newError("Read schema version", err)
+	}
+	exists, err := qdb.tableExists("quotes")
+	if err != nil {
+		return err
+	}
+	if exists && version < 1 {
+		if e := qdb.migrateDecimalPrices(); e != nil {
+			return e
+		}
+	}
+	if e := createTableQuotes(qdb.db); e != nil {
 		return e
 	}
+	if e := qdb.createTableInfoURLs(); e != nil {
+		return e
+	}
+	if exists && version < 2 {
+		if e := qdb.migrateDates(); e != nil {
+			return e
+		}
+	}
+	if exists && version < 3 {
+		if e := qdb.migrateTimestamps(); e != nil {
+			return e
+		}
+	}
+	if version < schemaVersion {
+		if _, err := qdb.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+			return newError("Write schema version", err)
+		}
+	}
 	// if e := qdb.createViewQuotes(); e != nil {
 	// 	return e
 	// }
 	return nil
 }
 
-func (qdb *QuoteDatabase) createTableQuotes() error {
+// execer executes a statement: it is a *sql.DB or a *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// tableExists returns true if the table exists in the database.
+func (qdb *QuoteDatabase) tableExists(name string) (bool, error) {
+	var n int
+	err := qdb.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	if err != nil {
+		return false, newError(fmt.Sprintf("Check table '%s'", name), err)
+	}
+	return n > 0, nil
+}
+
+// migrateDecimalPrices migrates the quotes table of schema version 0,
+// with the price column of type DOUBLE, to the price column of type TEXT.
+// The prices were float32 values: each one is converted
+// to the shortest decimal that represents the float32 value.
+func (qdb *QuoteDatabase) migrateDecimalPrices() error {
+	const msg = "Migrate prices to decimal"
+
+	tx, err := qdb.db.Begin()
+	if err != nil {
+		return newError(msg, err)
+	}
+	defer tx.Rollback()
+
+	// sqlite cannot change the type of a column: the table is recreated
+	stmts := []string{
+		"ALTER TABLE quotes RENAME TO quotes_v0",
+		"DROP INDEX IF EXISTS idx_quotes_isin_source_dates",
+	}
+	for _, s := range stmts {
+		if _, err = tx.Exec(s); err != nil {
+			return newError(msg, err)
+		}
+	}
+	if err = createTableQuotes(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO quotes(id, isin, source, datestamp, timestamp, date, currency, url, errmsg)
+SELECT id, isin, source, datestamp, timestamp, date, currency, url, errmsg
+FROM quotes_v0`)
+	if err != nil {
+		return newError(msg, err)
+	}
+
+	// read all the prices before updating them
+	type idPrice struct {
+		id    int
+		price float64
+	}
+	var prices []idPrice
+	rows, err := tx.Query("SELECT id, price FROM quotes_v0 WHERE price IS NOT NULL")
+	if err != nil {
+		return newError(msg, err)
+	}
+	for rows.Next() {
+		var p idPrice
+		if err = rows.Scan(&p.id, &p.price); err != nil {
+			rows.Close()
+			return newError(msg, err)
+		}
+		prices = append(prices, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return newError(msg, err)
+	}
+
+	for _, p := range prices {
+		price := strconv.FormatFloat(float64(float32(p.price)), 'f', -1, 32)
+		if _, err = tx.Exec("UPDATE quotes SET price = ? WHERE id = ?", price, p.id); err != nil {
+			return newError(msg, err)
+		}
+	}
+
+	if _, err = tx.Exec("DROP TABLE quotes_v0"); err != nil {
+		return newError(msg, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return newError(msg, err)
+	}
+	return nil
+}
+
+// normalizeDate returns the normalized date of a record of schema version 1,
+// whose kind is unknown: a date at midnight in its location is guessed
+// to be a NAV date, stored at midnight UTC; any other date is stored in UTC.
+// It is a heuristic used only by the migration: the dates of the new records
+// are normalized by the quotegetter.DateSpec of their source.
+func normalizeDate(date time.Time) time.Time {
+	if date.IsZero() {
+		return time.Time{}
+	}
+	year, month, day := date.Date()
+	if date.Equal(time.Date(year, month, day, 0, 0, 0, 0, date.Location())) {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	return date.UTC()
+}
+
+// migrateDates migrates the dates of schema version 1,
+// stored in the time zone of the source or of the system, to the normalized dates.
+// The records are updated from the most recent one: if two records
+// become duplicates, the older one is deleted.
+func (qdb *QuoteDatabase) migrateDates() error {
+	const msg = "Migrate dates"
+
+	tx, err := qdb.db.Begin()
+	if err != nil {
+		return newError(msg, err)
+	}
+	defer tx.Rollback()
+
+	// read all the dates before updating them
+	type idDate struct {
+		id   int
+		date time.Time
+	}
+	var dates []idDate
+	rows, err := tx.Query("SELECT id, date FROM quotes ORDER BY id DESC")
+	if err != nil {
+		return newError(msg, err)
+	}
+	for rows.Next() {
+		var d idDate
+		if err = rows.Scan(&d.id, &d.date); err != nil {
+			rows.Close()
+			return newError(msg, err)
+		}
+		dates = append(dates, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return newError(msg, err)
+	}
+
+	for _, d := range dates {
+		date := normalizeDate(d.date)
+		if date.Equal(d.date) && date.Location() == d.date.Location() {
+			continue
+		}
+		res, err := tx.Exec("UPDATE OR IGNORE quotes SET date = ? WHERE id = ?", date, d.id)
+		if err != nil {
+			return newError(msg, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return newError(msg, err)
+		} else if n == 0 {
+			// duplicate of a more recent record
+			if _, err = tx.Exec("DELETE FROM quotes WHERE id = ?", d.id); err != nil {
+				return newError(msg, err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return newError(msg, err)
+	}
+	return nil
+}
+
+// datestamp returns the datestamp of the timestamp:
+// the midnight UTC of its day in UTC.
+func datestamp(timestamp time.Time) time.Time {
+	year, month, day := timestamp.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// migrateTimestamps migrates the timestamps of schema version 2,
+// stored in the time zone of the system, to UTC:
+// the datestamps are computed again from the timestamps in UTC.
+// The unique index is dropped during the update: if two records
+// become duplicates, the older one is deleted.
+func (qdb *QuoteDatabase) migrateTimestamps() error {
+	const msg = "Migrate timestamps"
+
+	tx, err := qdb.db.Begin()
+	if err != nil {
+		return newError(msg, err)
+	}
+	defer tx.Rollback()
+
+	// read all the timestamps before updating them
+	type idTimestamp struct {
+		id        int
+		timestamp time.Time
+	}
+	var stamps []idTimestamp
+	rows, err := tx.Query("SELECT id, timestamp FROM quotes")
+	if err != nil {
+		return newError(msg, err)
+	}
+	for rows.Next() {
+		var r idTimestamp
+		if err = rows.Scan(&r.id, &r.timestamp); err != nil {
+			rows.Close()
+			return newError(msg, err)
+		}
+		stamps = append(stamps, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return newError(msg, err)
+	}
+
+	if _, err = tx.Exec("DROP INDEX IF EXISTS idx_quotes_isin_source_dates"); err != nil {
+		return newError(msg, err)
+	}
+	for _, r := range stamps {
+		timestamp := r.timestamp.UTC()
+		_, err = tx.Exec("UPDATE quotes SET timestamp = ?, datestamp = ? WHERE id = ?", timestamp, datestamp(timestamp), r.id)
+		if err != nil {
+			return newError(msg, err)
+		}
+	}
+	_, err = tx.Exec(`DELETE FROM quotes WHERE id NOT IN (
+SELECT MAX(id) FROM quotes GROUP BY isin, source, datestamp, date)`)
+	if err != nil {
+		return newError(msg, err)
+	}
+	if err = createTableQuotes(tx); err != nil {
+		return err
+	}
+
+	// the info urls are unique by isin and source: no duplicates
+	type infoTimestamp struct {
+		isin, source string
+		timestamp    time.Time
+	}
+	var infos []infoTimestamp
+	rows, err = tx.Query("SELECT isin, source, timestamp FROM info_urls")
+	if err != nil {
+		return newError(msg, err)
+	}
+	for rows.Next() {
+		var r infoTimestamp
+		if err = rows.Scan(&r.isin, &r.source, &r.timestamp); err != nil {
+			rows.Close()
+			return newError(msg, err)
+		}
+		infos = append(infos, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return newError(msg, err)
+	}
+	for _, r := range infos {
+		_, err = tx.Exec("UPDATE info_urls SET timestamp = ? WHERE isin = ? AND source = ?", r.timestamp.UTC(), r.isin, r.source)
+		if err != nil {
+			return newError(msg, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return newError(msg, err)
+	}
+	return nil
+}
+
+func createTableQuotes(db execer) error {
 	/*
 		crea un unique index sui campi (isin, source, datestamp, date)
 		- datestamp e' il timestamp con la sola data, senza orario
@@ -200,14 +505,14 @@ source TEXT NOT NULL,
 datestamp DATETIME NOT NULL,
 timestamp DATETIME NOT NULL,
 date DATE NOT NULL,
-price DOUBLE,
+price TEXT,
 currency TEXT,
 url TEXT,
 errmsg TEXT
 );
 `
 
-	_, err := qdb.db.Exec(sql)
+	_, err := db.Exec(sql)
 	if err != nil {
 		return newError("Create table 'quotes'", err)
 	}
@@ -215,7 +520,7 @@ errmsg TEXT
 	// create index if not exists
 	sql = `CREATE UNIQUE INDEX IF NOT EXISTS idx_quotes_isin_source_dates 
 ON quotes (isin, source, datestamp, date);`
-	_, err = qdb.db.Exec(sql)
+	_, err = db.Exec(sql)
 	if err != nil {
 		return newError("Create index 'idx_quotes_isin_source_dates'", err)
 	}
@@ -223,6 +528,24 @@ ON quotes (isin, source, datestamp, date);`
 	return nil
 }
 
+func (qdb *QuoteDatabase) createTableInfoURLs() error {
+	// the info url of each (isin, source) is unique:
+	// it is replaced by INSERT OR REPLACE INTO info_urls
+	sql := `CREATE TABLE IF NOT EXISTS info_urls(
+isin TEXT NOT NULL,
+source TEXT NOT NULL,
+timestamp DATETIME NOT NULL,
+url TEXT NOT NULL,
+PRIMARY KEY (isin, source)
+);
+`
+	_, err := qdb.db.Exec(sql)
+	if err != nil {
+		return newError("Create table 'info_urls'", err)
+	}
+	return nil
+}
+
 // func (qdb *QuoteDatabase) createViewQuotes() error {
 
 // 	// create table if not exists
@@ -243,6 +566,9 @@ ON quotes (isin, source, datestamp, date);`
 // }
 
 // InsertQuotes insert the quotes in the quotes database.
+// The timestamps are stored in UTC, and the datestamps are their day in UTC.
+// The dates must be normalized by the quotegetter.DateSpec of the source:
+// they are stored in UTC.
 func (qdb *QuoteDatabase) InsertQuotes(items ...*QuoteRecord) error {
 	sql := `INSERT OR REPLACE INTO quotes(
 datestamp,
@@ -267,13 +593,11 @@ errmsg
 		if timestamp.IsZero() {
 			timestamp = time.Now()
 		}
-		// set datestamp
-		year, month, day := timestamp.Date()
-		datestamp := time.Date(year, month, day, 0, 0, 0, 0, timestamp.Location())
+		timestamp = timestamp.UTC()
 
-		_, err = stmt.Exec(datestamp, timestamp, i.Isin, i.Source,
-			i.Date, // ToNullTime(i.date),
-			ToNullFloat64(float64(i.Price)),
+		_, err = stmt.Exec(datestamp(timestamp), timestamp, i.Isin, i.Source,
+			i.Date.UTC(), // ToNullTime(i.date),
+			ToNullString(i.Price.String()),
 			ToNullString(i.Currency),
 			ToNullString(i.URL),
 			ToNullString(i.ErrMsg))
@@ -285,79 +609,142 @@ errmsg
 	return nil
 }
 
-/*
-// SelectAllQuotes select all the quotes of the database.
-func (qdb *QuoteDatabase) SelectAllQuotes() ([]*QuoteRecord, error) {
+// Status is used to select the quote records by their outcome.
+type Status int
+
+// Status values.
+const (
+	// AnyStatus selects both success and error records.
+	AnyStatus Status = iota
+	// SuccessStatus selects the success records only.
+	SuccessStatus
+	// ErrorStatus selects the error records only.
+	ErrorStatus
+)
 
-	sql := `SELECT id, timestamp, isin, source,
-date, price, currency, url, errmsg
-FROM quotes
-ORDER BY isin, source, date desc
-`
-	rows, err := qdb.db.Query(sql)
-	if err != nil {
-		return nil, newError("Select quotes", err)
+// ParseStatus returns the Status corresponding to the string, ignoring case:
+// "all" or "" for AnyStatus, "ok" for SuccessStatus and "err" for ErrorStatus.
+func ParseStatus(s string) (Status, error) {
+	switch strings.ToLower(s) {
+	case "", "all":
+		return AnyStatus, nil
+	case "ok":
+		return SuccessStatus, nil
+	case "err":
+		return ErrorStatus, nil
 	}
-	defer rows.Close()
+	return AnyStatus, fmt.Errorf("invalid status %q", s)
+}
 
-	var result []*QuoteRecord
-	for rows.Next() {
+// QuoteFilter contains the criteria used to select the quote records.
+// Zero value fields are not used to filter the records.
+type QuoteFilter struct {
+	Isins    []string
+	Sources  []string
+	DateFrom time.Time // inclusive
+	DateTo   time.Time // inclusive
+	Status   Status
+
+	// Last selects only the most recent record, by timestamp,
+	// of each isin and source matching the other criteria.
+	Last bool
+}
 
-		r := &QuoteRecord{}
-		err = rows.Scan(&r.id, &r.timestamp, &r.isin, &r.source,
-			&r.date, &r.price, &r.currency, &r.url, &r.errmsg)
-		if err != nil {
-			return nil, newError("Select quotes", err)
-		}
-		result = append(result, r)
+// appendIn appends to where the "field IN (?, ?, ...)" condition
+// and to args the corresponding values.
+func appendIn(where []string, args []interface{}, field string, values []string) ([]string, []interface{}) {
+	if len(values) == 0 {
+		return where, args
 	}
-	return result, nil
+	marks := make([]string, 0, len(values))
+	for _, v := range values {
+		marks = append(marks, "?")
+		args = append(args, v)
+	}
+	where = append(where, fmt.Sprintf("%s IN (%s)", field, strings.Join(marks, ", ")))
+	return where, args
 }
-*/
 
-/*
+// whereClause returns the WHERE clause of the filter and its arguments.
+// It returns an empty string if no criteria is defined.
+func (flt *QuoteFilter) whereClause() (string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if flt == nil {
+		return "", nil
+	}
 
-// SelectLastQuotes is ...
-func (qdb *QuoteDatabase) SelectLastQuotes() ([]*QuoteRecord, error) {
-
-	sqlSelect := `select q.id, q.timestamp, q.isin, q.source,
-q.date, q.price, q.currency, q.url, q.errmsg
-from quotes q
-where q.id in
-(
-select id
-from quotes
-where isin = q.isin
-and source = q.source
-order by timestamp DESC
-limit 2
-)
-order by q.isin, q.timestamp desc, q.source
+	where, args = appendIn(where, args, "isin", flt.Isins)
+	where, args = appendIn(where, args, "source", flt.Sources)
+
+	// the date is compared using only its "YYYY-MM-DD" prefix,
+	// in order to ignore the time zone of the stored value.
+	if !flt.DateFrom.IsZero() {
+		where = append(where, "substr(date, 1, 10) >= ?")
+		args = append(args, flt.DateFrom.Format("2006-01-02"))
+	}
+	if !flt.DateTo.IsZero() {
+		where = append(where, "substr(date, 1, 10) <= ?")
+		args = append(args, flt.DateTo.Format("2006-01-02"))
+	}
+
+	switch flt.Status {
+	case SuccessStatus:
+		where = append(where, "errmsg IS NULL")
+	case ErrorStatus:
+		where = append(where, "errmsg IS NOT NULL")
+	}
+
+	if len(where) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(where, "\nAND "), args
+}
+
+// SelectQuotes returns the quote records matching the filter.
+// A nil filter selects all the records.
+// The records are ordered by isin, date (desc), source and timestamp (desc).
+func (qdb *QuoteDatabase) SelectQuotes(flt *QuoteFilter) ([]*QuoteRecord, error) {
+
+	where, args := flt.whereClause()
+	if flt != nil && flt.Last {
+		// the bare column id is taken from the row with the max timestamp:
+		// the timestamps are compared as instants, not as text
+		// with possibly different zone offsets.
+		where = `WHERE id IN (SELECT id FROM (SELECT id, MAX(julianday(timestamp)) FROM quotes
+` + where + `
+GROUP BY isin, source))`
+	}
+
+	sqlSelect := `SELECT id, timestamp, isin, source,
+date, price, currency, url, errmsg
+FROM quotes
+` + where + `
+ORDER BY isin, date DESC, source, timestamp DESC
 `
-	rows, err := qdb.db.Query(sqlSelect)
+	rows, err := qdb.db.Query(sqlSelect, args...)
 	if err != nil {
-		return nil, newError("Select last quotes", err)
+		return nil, newError("Select quotes", err)
 	}
 	defer rows.Close()
 
-	var result []*QuoteRecord
+	result := []*QuoteRecord{}
 	for rows.Next() {
 		var (
-			currency, url, errmsg sql.NullString
-			price                 sql.NullFloat64
-			// date                  sql.NullTime
+			price, currency, url, errmsg sql.NullString
 		)
 		r := &QuoteRecord{}
 		err = rows.Scan(&r.ID, &r.Timestamp, &r.Isin, &r.Source,
 			&r.Date, &price, &currency, &url, &errmsg)
 		if err != nil {
-			return nil, newError("Select last quotes", err)
+			return nil, newError("Select quotes", err)
 		}
-		// if date.Valid {
-		// 	r.date = date.Time
-		// }
 		if price.Valid {
-			r.Price = float32(price.Float64)
+			if r.Price, err = decimal.Parse(price.String); err != nil {
+				return nil, newError("Select quotes", err)
+			}
 		}
 		if currency.Valid {
 			r.Currency = currency.String
@@ -371,6 +758,104 @@ order by q.isin, q.timestamp desc, q.source
 
 		result = append(result, r)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, newError("Select quotes", err)
+	}
+	return result, nil
+}
+
+// InfoURLRecord is the info url of an isin for a source,
+// stored in the quote database. The scrapers use the info url
+// to get the quote without searching the isin.
+type InfoURLRecord struct {
+	Isin      string
+	Source    string
+	Timestamp time.Time
+	URL       string
+}
+
+// InsertInfoURLs insert the info urls in the quote database,
+// replacing the previous info url of the same isin and source.
+// The timestamps are stored in UTC.
+func (qdb *QuoteDatabase) InsertInfoURLs(items ...*InfoURLRecord) error {
+	sql := `INSERT OR REPLACE INTO info_urls(
+isin,
+source,
+timestamp,
+url
+) values(?, ?, ?, ?)
+`
+	stmt, err := qdb.db.Prepare(sql)
+	if err != nil {
+		return newError("Insert info url", err)
+	}
+	defer stmt.Close()
+
+	for _, i := range items {
+		timestamp := i.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		_, err = stmt.Exec(i.Isin, i.Source, timestamp.UTC(), i.URL)
+		if err != nil {
+			return newError("Insert info url", err)
+		}
+	}
+	return nil
+}
+
+// DeleteInfoURLs deletes the info urls of the same isin and source
+// of the items, e.g. because they are no longer valid.
+func (qdb *QuoteDatabase) DeleteInfoURLs(items ...*InfoURLRecord) error {
+	stmt, err := qdb.db.Prepare("DELETE FROM info_urls WHERE isin = ? AND source = ?")
+	if err != nil {
+		return newError("Delete info url", err)
+	}
+	defer stmt.Close()
+
+	for _, i := range items {
+		if _, err = stmt.Exec(i.Isin, i.Source); err != nil {
+			return newError("Delete info url", err)
+		}
+	}
+	return nil
+}
+
+// SelectInfoURLs returns the info urls of the given isins and sources.
+// Empty isins or sources are not used to filter the records.
+// The records are ordered by isin and source.
+func (qdb *QuoteDatabase) SelectInfoURLs(isins, sources []string) ([]*InfoURLRecord, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	where, args = appendIn(where, args, "isin", isins)
+	where, args = appendIn(where, args, "source", sources)
+
+	sqlSelect := `SELECT isin, source, timestamp, url
+FROM info_urls
+`
+	if len(where) > 0 {
+		sqlSelect += "WHERE " + strings.Join(where, "\nAND ") + "\n"
+	}
+	sqlSelect += "ORDER BY isin, source\n"
+
+	rows, err := qdb.db.Query(sqlSelect, args...)
+	if err != nil {
+		return nil, newError("Select info urls", err)
+	}
+	defer rows.Close()
+
+	result := []*InfoURLRecord{}
+	for rows.Next() {
+		r := &InfoURLRecord{}
+		if err = rows.Scan(&r.Isin, &r.Source, &r.Timestamp, &r.URL); err != nil {
+			return nil, newError("Select info urls", err)
+		}
+		result = append(result, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, newError("Select info urls", err)
+	}
 	return result, nil
 }
-*/
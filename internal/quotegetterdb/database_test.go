@@ -1,7 +1,9 @@
 package quotegetterdb
 
 import (
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -24,9 +26,9 @@ var records = []*QuoteRecord{
 	{
 		Isin:      isin1,
 		Source:    source1,
-		Price:     10.1,
+		Price:     "10.1",
 		Currency:  "USD",
-		Date:      time.Date(2020, 01, 01, 0, 0, 0, 0, loc),
+		Date:      time.Date(2020, 01, 01, 0, 0, 0, 0, time.UTC),
 		Timestamp: time.Date(2020, 01, 01, 10, 11, 0, 0, loc),
 		URL:       testURL(source1, isin1),
 	},
@@ -39,18 +41,18 @@ var records = []*QuoteRecord{
 	{
 		Isin:      isin1,
 		Source:    source1,
-		Price:     10.3,
+		Price:     "10.30",
 		Currency:  "USD",
-		Date:      time.Date(2020, 01, 03, 0, 0, 0, 0, loc),
+		Date:      time.Date(2020, 01, 03, 0, 0, 0, 0, time.UTC),
 		Timestamp: time.Date(2020, 01, 03, 10, 33, 0, 0, loc),
 		URL:       testURL(source1, isin1),
 	},
 	{
 		Isin:      isin1,
 		Source:    source2,
-		Price:     10.22,
+		Price:     "10.22",
 		Currency:  "EUR",
-		Date:      time.Date(2020, 02, 01, 0, 0, 0, 0, loc),
+		Date:      time.Date(2020, 02, 01, 0, 0, 0, 0, time.UTC),
 		Timestamp: time.Date(2020, 02, 01, 0, 0, 0, 0, loc),
 		URL:       testURL(source2, isin1),
 	},
@@ -111,27 +113,427 @@ func TestInsertQuotes(t *testing.T) {
 	}
 }
 
-/*
-func TestSelectLastQuotes(t *testing.T) {
-	qdb := mustOpenDB()
+func TestSelectQuotes(t *testing.T) {
+	qdb, err := Open(filepath.Join(t.TempDir(), "quote.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	defer qdb.Close()
 
-	err := qdb.InsertQuotes(records...)
+	err = qdb.InsertQuotes(records...)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	res, err := qdb.SelectLastQuotes()
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2020, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := map[string]struct {
+		filter *QuoteFilter
+		want   int
+	}{
+		"nil filter":         {nil, 8},
+		"empty filter":       {&QuoteFilter{}, 8},
+		"isin":               {&QuoteFilter{Isins: []string{isin2}}, 1},
+		"isins":              {&QuoteFilter{Isins: []string{isin1, isin2}}, 8},
+		"source":             {&QuoteFilter{Sources: []string{source2}}, 4},
+		"isin and source":    {&QuoteFilter{Isins: []string{isin1}, Sources: []string{source1}}, 3},
+		"success":            {&QuoteFilter{Status: SuccessStatus}, 3},
+		"error":              {&QuoteFilter{Status: ErrorStatus}, 5},
+		"date from":          {&QuoteFilter{DateFrom: day(1, 2)}, 2},
+		"date to":            {&QuoteFilter{DateTo: day(1, 2)}, 6}, // 1 quote and the 5 errors without date
+		"date from-to":       {&QuoteFilter{DateFrom: day(1, 1), DateTo: day(1, 31)}, 2},
+		"date from-to equal": {&QuoteFilter{DateFrom: day(1, 3), DateTo: day(1, 3)}, 1},
+		"not found":          {&QuoteFilter{Isins: []string{"ISIN-NOT-FOUND"}}, 0},
+		"last":               {&QuoteFilter{Last: true}, 3},
+		"last success":       {&QuoteFilter{Status: SuccessStatus, Last: true}, 2},
+		"last of source":     {&QuoteFilter{Sources: []string{source2}, Last: true}, 1},
+	}
+
+	for title, tc := range testCases {
+		res, err := qdb.SelectQuotes(tc.filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error %q", title, err)
+			continue
+		}
+		if len(res) != tc.want {
+			t.Errorf("%s: want %d records, got %d", title, tc.want, len(res))
+			for j, r := range res {
+				t.Logf("[%d] %v\n", j, r)
+			}
+		}
+	}
+
+	// check order and values of the first record
+	res, err := qdb.SelectQuotes(&QuoteFilter{Isins: []string{isin1}, Status: SuccessStatus})
 	if err != nil {
 		t.Fatal(err)
 	}
-	for j, r := range res {
+	first := res[0]
+	if first.Source != source2 || first.Currency != "EUR" || first.Price != "10.22" || first.URL != testURL(source2, isin1) {
+		t.Errorf("unexpected first record %v", first)
+	}
+	if got := first.Date.Format("2006-01-02"); got != "2020-02-01" {
+		t.Errorf("first record date: want %q, got %q", "2020-02-01", got)
+	}
 
-		t.Logf("[%d] %v\n", j, r)
+	// the last success of a source is not the last record
+	res, err = qdb.SelectQuotes(&QuoteFilter{Isins: []string{isin1}, Sources: []string{source1}, Status: SuccessStatus, Last: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Price != "10.30" {
+		t.Errorf("last success: unexpected records %v", res)
+	}
+}
+
+func TestSelectQuotesLast(t *testing.T) {
+	qdb, err := Open(filepath.Join(t.TempDir(), "quote.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qdb.Close()
+
+	// the timestamps with different zone offsets, whose text order
+	// is not the order of the instants
+	insert := `INSERT INTO quotes(isin, source, datestamp, timestamp, date, price, currency) values(?, ?, ?, ?, ?, ?, ?)`
+	rows := []struct {
+		timestamp string
+		price     string
+	}{
+		{"2020-03-01 10:00:00+05:00", "10.1"},           // 05:00 UTC
+		{"2020-03-01 08:00:00.123456789+00:00", "10.2"}, // 08:00 UTC
+		{"2020-03-01 07:00:00-00:30", "10.3"},           // 07:30 UTC
+	}
+	for j, r := range rows {
+		datestamp := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+		date := time.Date(2020, 2, 1+j, 0, 0, 0, 0, time.UTC)
+		if _, err = qdb.db.Exec(insert, isin1, source1, datestamp, r.timestamp, date, r.price, "EUR"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := qdb.SelectQuotes(&QuoteFilter{Last: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Price != "10.2" {
+		t.Errorf("last: unexpected records %v", res)
+	}
+}
+
+func TestInfoURLs(t *testing.T) {
+	qdb, err := Open(filepath.Join(t.TempDir(), "quote.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qdb.Close()
+
+	err = qdb.InsertInfoURLs(
+		&InfoURLRecord{Isin: isin1, Source: source1, URL: "http://old/"},
+		&InfoURLRecord{Isin: isin1, Source: source2, URL: testURL(source2, isin1)},
+		&InfoURLRecord{Isin: isin2, Source: source1, URL: testURL(source1, isin2)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the info url of the same isin and source is replaced
+	err = qdb.InsertInfoURLs(&InfoURLRecord{Isin: isin1, Source: source1, URL: testURL(source1, isin1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		isins, sources []string
+		want           int
+	}{
+		"all":             {nil, nil, 3},
+		"isin":            {[]string{isin1}, nil, 2},
+		"source":          {nil, []string{source1}, 2},
+		"isin and source": {[]string{isin2}, []string{source2}, 0},
+	}
+	for title, tc := range testCases {
+		res, err := qdb.SelectInfoURLs(tc.isins, tc.sources)
+		if err != nil {
+			t.Errorf("%s: unexpected error %q", title, err)
+			continue
+		}
+		if len(res) != tc.want {
+			t.Errorf("%s: want %d records, got %d", title, tc.want, len(res))
+		}
+		for _, r := range res {
+			if r.URL != testURL(r.Source, r.Isin) {
+				t.Errorf("%s: %s %s: want url %q, got %q", title, r.Isin, r.Source, testURL(r.Source, r.Isin), r.URL)
+			}
+		}
+	}
+
+	// only the info url of the same isin and source is deleted
+	err = qdb.DeleteInfoURLs(&InfoURLRecord{Isin: isin1, Source: source1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := qdb.SelectInfoURLs(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Source != source2 || res[1].Isin != isin2 {
+		t.Errorf("delete: unexpected records %v", res)
+	}
+}
+
+func TestMigrateDecimalPrices(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+
+	// database of schema version 0, with float32 prices stored as DOUBLE
+	db, err := sql.Open("sqlite3", dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := []string{
+		`CREATE TABLE quotes(
+id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+isin TEXT NOT NULL,
+source TEXT NOT NULL,
+datestamp DATETIME NOT NULL,
+timestamp DATETIME NOT NULL,
+date DATE NOT NULL,
+price DOUBLE,
+currency TEXT,
+url TEXT,
+errmsg TEXT
+)`,
+		`CREATE UNIQUE INDEX idx_quotes_isin_source_dates ON quotes (isin, source, datestamp, date)`,
+	}
+	for _, s := range stmts {
+		if _, err = db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := time.Date(2020, 01, 01, 10, 11, 0, 0, time.UTC)
+	insert := `INSERT INTO quotes(isin, source, datestamp, timestamp, date, price, currency, errmsg) values(?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.Exec(insert, isin1, source1, ts, ts, ts, float64(float32(126.37)), "EUR", nil)
+	if err == nil {
+		_, err = db.Exec(insert, isin2, source1, ts, ts, time.Time{}, nil, nil, "Isin not found")
+	}
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the database is migrated when opened, only once
+	for j := 0; j < 2; j++ {
+		qdb, err := Open(dbpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var version int
+		if err = qdb.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		if version != schemaVersion {
+			t.Errorf("schema version: want %d, got %d", schemaVersion, version)
+		}
+
+		res, err := qdb.SelectQuotes(nil)
+		qdb.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 {
+			t.Fatalf("want 2 records, got %d", len(res))
+		}
+		if res[0].Price != "126.37" || res[0].Currency != "EUR" {
+			t.Errorf("unexpected migrated record %v", res[0])
+		}
+		if res[1].Price != "" || res[1].ErrMsg != "Isin not found" {
+			t.Errorf("unexpected migrated record %v", res[1])
+		}
+	}
+
+	// the new prices are exact
+	qdb, err := Open(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qdb.Close()
+	err = qdb.InsertQuotes(&QuoteRecord{Isin: isin1, Source: source2, Price: "16777217.125", Currency: "USD", Date: ts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := qdb.SelectQuotes(&QuoteFilter{Sources: []string{source2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Price != "16777217.125" {
+		t.Errorf("unexpected records %v", res)
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		title string
+		date  time.Time
+		want  time.Time
+	}{
+		{"zero", time.Time{}, time.Time{}},
+		{"utc date", time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC), time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"rome date", time.Date(2020, 9, 22, 0, 0, 0, 0, rome), time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)},
+		{"rome timestamp", time.Date(2020, 9, 22, 1, 30, 0, 0, rome), time.Date(2020, 9, 21, 23, 30, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if got := normalizeDate(c.date); got != c.want {
+			t.Errorf("%s: expected %v, found %v", c.title, c.want, got)
+		}
+	}
+}
+
+func TestMigrateDates(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// database of schema version 1, with the dates in the time zone of the source
+	qdb, err := Open(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2020, 9, 22, 10, 0, 0, 0, time.UTC)
+	insert := `INSERT INTO quotes(isin, source, datestamp, timestamp, date, price, currency) values(?, ?, ?, ?, ?, ?, ?)`
+	rows := []struct {
+		isin, source string
+		date         time.Time
+		price        string
+	}{
+		{isin1, source1, time.Date(2020, 9, 22, 0, 0, 0, 0, rome), "10.1"},
+		{isin1, source1, time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC), "10.2"}, // duplicate of the previous one
+		{isin1, source2, time.Date(2020, 9, 22, 1, 30, 0, 0, rome), "10.3"},
+		{isin2, source1, time.Time{}, ""},
+	}
+	for _, r := range rows {
+		if _, err = qdb.db.Exec(insert, r.isin, r.source, ts, ts, r.date, ToNullString(r.price), "EUR"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = qdb.db.Exec("PRAGMA user_version = 1"); err != nil {
+		t.Fatal(err)
+	}
+	qdb.Close()
+
+	qdb, err = Open(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qdb.Close()
+
+	res, err := qdb.SelectQuotes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{
+		"10.2": time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC),
+		"10.3": time.Date(2020, 9, 21, 23, 30, 0, 0, time.UTC),
+		"":     {},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("want %d records, got %d: %v", len(want), len(res), res)
+	}
+	for _, r := range res {
+		date, ok := want[r.Price.String()]
+		if !ok || !r.Date.Equal(date) {
+			t.Errorf("unexpected migrated record %v", r)
+		}
+	}
+}
+
+func TestMigrateTimestamps(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// database of schema version 2, with the timestamps in the time zone of the system
+	qdb, err := Open(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(ts time.Time) time.Time {
+		year, month, day := ts.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, ts.Location())
+	}
+	date := time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)
+	insert := `INSERT INTO quotes(isin, source, datestamp, timestamp, date, price, currency) values(?, ?, ?, ?, ?, ?, ?)`
+	rows := []struct {
+		isin, source string
+		timestamp    time.Time
+		price        string
+	}{
+		{isin1, source1, time.Date(2020, 9, 21, 23, 30, 0, 0, time.UTC), "10.1"},
+		{isin1, source1, time.Date(2020, 9, 22, 1, 0, 0, 0, rome), "10.2"}, // duplicate of the previous one in UTC
+		{isin1, source2, time.Date(2020, 9, 22, 10, 0, 0, 0, rome), "10.3"},
+	}
+	for _, r := range rows {
+		if _, err = qdb.db.Exec(insert, r.isin, r.source, day(r.timestamp), r.timestamp, date, r.price, "EUR"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	infoTimestamp := time.Date(2020, 9, 22, 1, 0, 0, 0, rome)
+	if _, err = qdb.db.Exec("INSERT INTO info_urls(isin, source, timestamp, url) values(?, ?, ?, ?)", isin1, source1, infoTimestamp, testURL(source1, isin1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = qdb.db.Exec("PRAGMA user_version = 2"); err != nil {
+		t.Fatal(err)
+	}
+	qdb.Close()
+
+	qdb, err = Open(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qdb.Close()
+
+	want := map[string]time.Time{
+		"10.2": time.Date(2020, 9, 21, 23, 0, 0, 0, time.UTC),
+		"10.3": time.Date(2020, 9, 22, 8, 0, 0, 0, time.UTC),
+	}
+	var n int
+	err = qdb.db.QueryRow("SELECT count(*) FROM quotes WHERE datestamp = ?", time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 record of datestamp 2020-09-21 in UTC, got %d", n)
+	}
+	res, err := qdb.SelectQuotes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(want) {
+		t.Fatalf("want %d records, got %d: %v", len(want), len(res), res)
+	}
+	for _, r := range res {
+		ts, ok := want[r.Price.String()]
+		if !ok || !r.Timestamp.Equal(ts) || r.Timestamp.Location() != time.UTC {
+			t.Errorf("unexpected migrated record %v", r)
+		}
+	}
+
+	infos, err := qdb.SelectInfoURLs(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || !infos[0].Timestamp.Equal(infoTimestamp) || infos[0].Timestamp.Location() != time.UTC {
+		t.Errorf("unexpected migrated info urls %v", infos)
 	}
-	t.Fail()
 }
-*/
 
 /*
 func TestExtractPath(t *testing.T) {
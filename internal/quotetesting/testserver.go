@@ -13,8 +13,9 @@ import (
 // The response in an html page with a table that prints
 // kew/value pairs of query parameters.
 // Special parameters:
-//   delay: number of msec to sleep before returning the response
-//   code: returned http status
+//
+//	delay: number of msec to sleep before returning the response
+//	code: returned http status
 func NewTestServer() *httptest.Server {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -11,6 +11,7 @@ Usage:
 Available sub-commands are:
 
     get      Get the quotes of the specified isins
+    history  Show the quotes saved in the database
     sources  Show available sources
     tor      Checks if Tor network will be used
 
@@ -0,0 +1,182 @@
+// Package decimal implements exact decimal numbers, used for prices.
+//
+// A Decimal keeps the scale of the number it was parsed from:
+// "126.370" has scale 3 and is not the same Decimal as "126.37",
+// even if the two numbers are equal (see Cmp).
+//
+// The Decimal is stored as its canonical text: an optional minus sign,
+// the integer digits without leading zeros and, if the scale is
+// greater than zero, the decimal point followed by the fraction digits.
+// The zero value "" is not a number: it means that the value is undefined.
+package decimal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number. Use Parse to create a Decimal.
+type Decimal string
+
+// ErrSyntax indicates that a value does not have the right syntax.
+var ErrSyntax = errors.New("invalid syntax")
+
+// maxExponent is the maximum absolute value of the exponent of a number.
+const maxExponent = 1000
+
+// Parse returns the Decimal represented by the string s,
+// with an optional sign, digits with an optional decimal point,
+// and an optional exponent: e.g. "-126.370", ".5", "1.5e3".
+// The scale of the result is the number of digits after the decimal point,
+// less the exponent: "1.5e3" is "1500" and "15e-3" is "0.015".
+func Parse(s string) (Decimal, error) {
+	errSyntax := func() (Decimal, error) {
+		return "", fmt.Errorf("decimal: parsing %q: %w", s, ErrSyntax)
+	}
+
+	str := s
+	var neg bool
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+
+	exp := 0
+	if j := strings.IndexAny(str, "eE"); j >= 0 {
+		e, err := strconv.Atoi(str[j+1:])
+		if err != nil || e > maxExponent || e < -maxExponent {
+			return errSyntax()
+		}
+		exp = e
+		str = str[:j]
+	}
+
+	intPart, fracPart := str, ""
+	if j := strings.IndexByte(str, '.'); j >= 0 {
+		intPart, fracPart = str[:j], str[j+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return errSyntax()
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return errSyntax()
+	}
+
+	// apply the exponent moving the decimal point
+	digits := intPart + fracPart
+	point := len(intPart) + exp
+	if point < 0 {
+		digits = strings.Repeat("0", -point) + digits
+		point = 0
+	}
+	if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	return format(neg, digits[:point], digits[point:]), nil
+}
+
+// MustParse is like Parse but panics if the string cannot be parsed.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// isDigits returns true if s contains only decimal digits.
+func isDigits(s string) bool {
+	for j := 0; j < len(s); j++ {
+		if s[j] < '0' || s[j] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// format returns the canonical text of the number.
+func format(neg bool, intPart, fracPart string) Decimal {
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	if neg && (intPart != "0" || strings.Trim(fracPart, "0") != "") {
+		intPart = "-" + intPart
+	}
+	if fracPart == "" {
+		return Decimal(intPart)
+	}
+	return Decimal(intPart + "." + fracPart)
+}
+
+// String returns the text of the number, with its scale.
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+	if j := strings.IndexByte(string(d), '.'); j >= 0 {
+		return len(d) - j - 1
+	}
+	return 0
+}
+
+// rat returns the value of the number. The zero value is 0.
+func (d Decimal) rat() *big.Rat {
+	r := new(big.Rat)
+	if d != "" {
+		r.SetString(string(d))
+	}
+	return r
+}
+
+// Cmp compares the values of d and e, regardless of their scale,
+// and returns -1 if d < e, 0 if d == e and +1 if d > e.
+// The zero value is compared as 0.
+func (d Decimal) Cmp(e Decimal) int {
+	return d.rat().Cmp(e.rat())
+}
+
+// Float64 returns the nearest float64 value of the number.
+// The zero value returns 0.
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// MarshalJSON implements the json.Marshaler interface:
+// the number is encoded as a json number, with its scale.
+// The zero value is encoded as null.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The number can be encoded as a json number or string.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = ""
+		return nil
+	}
+	if len(data) > 1 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return fmt.Errorf("decimal: %w", err)
+		}
+		data = []byte(s)
+	}
+	v, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,103 @@
+package decimal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		want  Decimal
+		scale int
+	}{
+		"126.370":                            {"126.370", 3},
+		"126":                                {"126", 0},
+		"+1.5":                               {"1.5", 1},
+		"-0.25":                              {"-0.25", 2},
+		"007.10":                             {"7.10", 2},
+		".5":                                 {"0.5", 1},
+		"5.":                                 {"5", 0},
+		"-0.00":                              {"0.00", 2},
+		"1.5e3":                              {"1500", 0},
+		"15E-3":                              {"0.015", 3},
+		"1.25e1":                             {"12.5", 1},
+		"16777217.125":                       {"16777217.125", 3},
+		"123456789012345678901234.000000001": {"123456789012345678901234.000000001", 9},
+	}
+	for s, c := range cases {
+		d, err := Parse(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, c.want, d, s)
+			assert.Equal(t, c.scale, d.Scale(), s)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{"", "-", ".", "1,5", "1.2.3", "abc", "1e", "1e5000", "1 000", "0x10"} {
+		_, err := Parse(s)
+		if assert.Error(t, err, s) {
+			assert.True(t, errors.Is(err, ErrSyntax), s)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	cases := []struct {
+		d, e string
+		want int
+	}{
+		{"126.370", "126.37", 0},
+		{"1.5", "1.25", 1},
+		{"-2", "1", -1},
+		{"16777217", "16777216", 1},
+		{"0", "", 0},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, Decimal(c.d).Cmp(Decimal(c.e)), "%s cmp %s", c.d, c.e)
+	}
+}
+
+func TestFloat64(t *testing.T) {
+	assert.Equal(t, 126.37, MustParse("126.370").Float64())
+	assert.Equal(t, 16777217.0, MustParse("16777217").Float64())
+	assert.Equal(t, 0.0, Decimal("").Float64())
+}
+
+func TestJSON(t *testing.T) {
+	type item struct {
+		Price Decimal `json:"price,omitempty"`
+	}
+
+	cases := map[string]struct {
+		item item
+		json string
+	}{
+		"scale": {item{"126.370"}, `{"price":126.370}`},
+		"big":   {item{"16777217.125"}, `{"price":16777217.125}`},
+		"empty": {item{}, `{}`},
+	}
+	for title, c := range cases {
+		data, err := json.Marshal(c.item)
+		if assert.NoError(t, err, title) {
+			assert.Equal(t, c.json, string(data), title)
+		}
+		var got item
+		if assert.NoError(t, json.Unmarshal([]byte(c.json), &got), title) {
+			assert.Equal(t, c.item, got, title)
+		}
+	}
+
+	// strings and null are accepted
+	var got item
+	if assert.NoError(t, json.Unmarshal([]byte(`{"price":"1.50"}`), &got)) {
+		assert.Equal(t, Decimal("1.50"), got.Price)
+	}
+	if assert.NoError(t, json.Unmarshal([]byte(`{"price":null}`), &got)) {
+		assert.Equal(t, Decimal(""), got.Price)
+	}
+	assert.Error(t, json.Unmarshal([]byte(`{"price":"x"}`), &got))
+}
@@ -0,0 +1,239 @@
+// Package quote can be used to retrieve the stock/fund quotes from various sources.
+//
+// The quotes of a list of isins are concurrently retrieved from the selected sources,
+// each one with its own number of workers, proxy, timeout and retries.
+// The results are returned as a slice of Result objects.
+//
+// Besides the built-in sources, new sources can be added with the Register function,
+// giving a SourceFactory that creates the QuoteGetter of the source,
+// or to a new Registry passed in the Options.
+//
+// Example:
+//
+//	results, err := quote.Get(ctx, []string{"IE00B4TG9K96"}, &quote.Options{
+//	    Sources: []*quote.SourceOptions{
+//	        {Name: "fondidocit", Workers: 2},
+//	        {Name: "morningstarit", Timeout: 5 * time.Second, Retries: 1},
+//	    },
+//	})
+package quote
+
+import (
+	"context"
+	"time"
+
+	iquote "github.com/mmbros/quote/internal/quote"
+	"github.com/mmbros/quote/internal/quotegetter"
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// QuoteGetter is the interface that must be implemented by a source.
+// GetQuote returns the quote of the isin. If url is not empty,
+// it can be used to get the quote directly, without searching the isin.
+type QuoteGetter = quotegetter.QuoteGetter
+
+// Quote is the information returned by the GetQuote method of a QuoteGetter.
+type Quote = quotegetter.Result
+
+// Result is the outcome of the retrieval of the quote of an isin from a source.
+// In case of error, the Err field is not nil, and the Status field
+// tells a real error from a canceled or skipped retrieval.
+type Result = iquote.Result
+
+// Status is the status of a Result.
+type Status = taskengine.StatusType
+
+// NewQuoteGetterFunc is the function that creates the QuoteGetter of a source,
+// given the name of the source and the http client to use.
+type NewQuoteGetterFunc = iquote.NewQuoteGetterFunc
+
+// SourceFactory creates the QuoteGetter of a source
+// and contains the metadata of the source:
+// the supported asset kinds, the default number of workers and the base url.
+type SourceFactory = iquote.SourceFactory
+
+// Stats contains the statistics of a retrieval:
+// the Workers are the sources and the Tasks are the isins.
+type Stats = taskengine.Stats
+
+// SourceInfo contains the metadata of a registered source.
+type SourceInfo = iquote.SourceInfo
+
+// Registry contains a set of available sources.
+// It is safe for concurrent use.
+type Registry = iquote.Registry
+
+// Asset kinds supported by the sources.
+const (
+	KindFund   = iquote.KindFund
+	KindETF    = iquote.KindETF
+	KindStock  = iquote.KindStock
+	KindCrypto = iquote.KindCrypto
+)
+
+// Consensus of the sources about the quote of an isin in Quorum mode.
+const (
+	ConsensusAgreed   = iquote.ConsensusAgreed
+	ConsensusDisputed = iquote.ConsensusDisputed
+	ConsensusSingle   = iquote.ConsensusSingle
+)
+
+// Status of a Result.
+const (
+	// StatusSuccess means that the quote has been retrieved.
+	StatusSuccess = taskengine.Success
+
+	// StatusError means that the source has failed to retrieve the quote.
+	StatusError = taskengine.Error
+
+	// StatusCanceled means that the retrieval has been canceled,
+	// e.g. because another source has retrieved the quote.
+	StatusCanceled = taskengine.Canceled
+
+	// StatusSkipped means that the source has not been used,
+	// because its circuit is open.
+	StatusSkipped = taskengine.Skipped
+)
+
+// SourceOptions contains the options of a single source.
+type SourceOptions struct {
+	// Name is the name of the source.
+	Name string
+
+	// Workers is the number of concurrent requests to the source.
+	// If zero, the default workers of the source are used.
+	Workers int
+
+	// Proxy is the url of the proxy. If empty, the proxy is taken
+	// from the HTTP_PROXY, HTTPS_PROXY and NOPROXY environment variables.
+	Proxy string
+
+	// Timeout is the timeout of each request to the source.
+	// If zero, a default timeout is used.
+	Timeout time.Duration
+
+	// Retries is the number of retries of the transient failures.
+	Retries int
+
+	// Priority is the priority of the source: given equal opportunity,
+	// the sources with higher priority get the isins first,
+	// and their results are preferred.
+	Priority int
+
+	// Rate is the rate limit of the requests to the source.
+	// If nil, the requests are not limited.
+	Rate *taskengine.RateLimit
+}
+
+// EngineOptions contains the options of the retrieval engine:
+// the mode, the hedge delay, the priority window, the circuit breaker,
+// the quorum with its price tolerance and the registry of the sources.
+// If Registry is nil, the built-in sources and the ones added
+// with Register are used.
+type EngineOptions = iquote.EngineOptions
+
+// Options contains the options of the Get function.
+type Options struct {
+	EngineOptions
+
+	// Sources is the list of sources used to get the quotes.
+	// If empty, all the available sources are used with default options.
+	Sources []*SourceOptions
+
+	// Database is the sqlite3 database where the quotes are saved.
+	// If empty, the quotes are not saved.
+	Database string
+}
+
+// NewError returns an error of the source about the isin.
+// It should be used by the QuoteGetter implementations.
+func NewError(source, isin, url string, err error) error {
+	return quotegetter.NewError(source, isin, url, err)
+}
+
+// NewRegistry returns a new registry without sources,
+// not even the built-in ones.
+func NewRegistry() *Registry {
+	return iquote.NewRegistry()
+}
+
+// Register adds a new source to the available sources.
+// It returns an error if a source with the same name already exists.
+func Register(name string, factory *SourceFactory) error {
+	return iquote.Register(name, factory)
+}
+
+// Sources returns the sorted list of the names of the available sources.
+func Sources() []string {
+	return iquote.Sources()
+}
+
+// SourcesInfo returns the metadata of the available sources, sorted by name.
+func SourcesInfo() []*SourceInfo {
+	return iquote.SourcesInfo()
+}
+
+// sourceIsins returns the list of the isins to get from each source.
+func sourceIsins(reg *Registry, isins []string, sources []*SourceOptions) []*iquote.SourceIsins {
+	if len(sources) == 0 {
+		for _, name := range reg.Names() {
+			sources = append(sources, &SourceOptions{Name: name})
+		}
+	}
+
+	items := make([]*iquote.SourceIsins, 0, len(sources))
+	for _, src := range sources {
+		items = append(items, &iquote.SourceIsins{
+			Source:   src.Name,
+			Workers:  src.Workers,
+			Proxy:    src.Proxy,
+			Timeout:  src.Timeout,
+			Retries:  src.Retries,
+			Priority: src.Priority,
+			Rate:     src.Rate,
+			Isins:    isins,
+		})
+	}
+	return items
+}
+
+// Get retrieves the quotes of the isins from the sources specified in the options.
+// It returns the results when all of them are available,
+// or when the context is done.
+// The results are also saved to the database, if defined.
+func Get(ctx context.Context, isins []string, opts *Options) ([]*Result, error) {
+	results, _, err := GetStats(ctx, isins, opts)
+	return results, err
+}
+
+// GetStats is like Get, but it also returns the statistics of the retrieval:
+// the works done by each source with their latency,
+// and the attempts and the winning source of each isin.
+func GetStats(ctx context.Context, isins []string, opts *Options) ([]*Result, *Stats, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	stats := &Stats{}
+	if len(isins) == 0 {
+		return []*Result{}, stats, nil
+	}
+
+	reg := opts.SourceRegistry()
+	items := sourceIsins(reg, isins, opts.Sources)
+	engopts, err := opts.TaskEngineOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	engopts.Stats = stats
+	results, err := reg.Retrieve(ctx, items, engopts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.Database != "" {
+		if err = iquote.SaveResults(opts.Database, results); err != nil {
+			return results, stats, err
+		}
+	}
+	return results, stats, nil
+}
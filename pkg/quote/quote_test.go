@@ -0,0 +1,147 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mmbros/quote/pkg/decimal"
+	"github.com/mmbros/quote/pkg/taskengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bankGetter is a custom QuoteGetter that returns a fixed price
+// for known isins, and an error otherwise.
+type bankGetter struct {
+	name   string
+	client *http.Client
+	prices map[string]decimal.Decimal
+}
+
+func (g *bankGetter) Source() string       { return g.name }
+func (g *bankGetter) Client() *http.Client { return g.client }
+
+func (g *bankGetter) GetQuote(ctx context.Context, isin, url string) (*Quote, error) {
+	price, ok := g.prices[isin]
+	if !ok {
+		return nil, NewError(g.name, isin, url, errors.New("isin not found"))
+	}
+	return &Quote{
+		Source:   g.name,
+		Isin:     isin,
+		Price:    price,
+		Currency: "EUR",
+		Date:     time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func newBankGetter(name string, client *http.Client) QuoteGetter {
+	return &bankGetter{name, client, map[string]decimal.Decimal{"isin1": "10.5", "isin2": "20.25"}}
+}
+
+var bankFactory = &SourceFactory{
+	New:     newBankGetter,
+	Kinds:   []string{KindFund},
+	Workers: 2,
+	BaseURL: "https://bank.example.com",
+}
+
+func TestRegister(t *testing.T) {
+	reg := NewRegistry()
+	assert.Empty(t, reg.Names())
+
+	require.NoError(t, reg.Register("test-register", bankFactory))
+	assert.Equal(t, []string{"test-register"}, reg.Names())
+
+	err := reg.Register("test-register", bankFactory)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "already registered")
+	}
+
+	err = reg.Register("test-register-nil", nil)
+	assert.Error(t, err)
+
+	// the default registry is not changed
+	assert.NotContains(t, Sources(), "test-register")
+	err = Register("test-register-nil", nil)
+	assert.Error(t, err)
+
+	want := []*SourceInfo{{
+		Name:    "test-register",
+		Kinds:   []string{KindFund},
+		Workers: 2,
+		BaseURL: "https://bank.example.com",
+	}}
+	assert.Equal(t, want, reg.Infos())
+}
+
+func TestGet(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register("test-get", bankFactory))
+
+	dbpath := filepath.Join(t.TempDir(), "quote.sqlite3")
+	opts := &Options{
+		EngineOptions: EngineOptions{Mode: taskengine.All, Registry: reg},
+		Sources:       []*SourceOptions{{Name: "test-get", Workers: 2}},
+		Database:      dbpath,
+	}
+
+	results, err := Get(context.Background(), []string{"isin1", "isin2", "isin3"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(results))
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Isin < results[j].Isin })
+
+	for _, r := range results[:2] {
+		assert.True(t, r.Success(), r.Isin)
+		assert.Equal(t, "test-get", r.Source)
+		assert.Equal(t, "EUR", r.Currency)
+	}
+	assert.Equal(t, decimal.Decimal("10.5"), results[0].Price)
+	assert.Equal(t, decimal.Decimal("20.25"), results[1].Price)
+
+	assert.False(t, results[2].Success())
+	assert.EqualError(t, results[2].Err, "isin not found")
+}
+
+func TestGetStats(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register("test-get-stats", bankFactory))
+
+	// all the sources of the registry are used
+	opts := &Options{EngineOptions: EngineOptions{Registry: reg}}
+	results, stats, err := GetStats(context.Background(), []string{"isin1", "isin2", "isin3"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(results))
+
+	ws := stats.Workers["test-get-stats"]
+	if assert.NotNil(t, ws) {
+		assert.Equal(t, 3, ws.Done)
+		assert.Equal(t, 2, ws.Success)
+		assert.Equal(t, 1, ws.Errors)
+	}
+	assert.Equal(t, taskengine.WorkerID("test-get-stats"), stats.Tasks["isin1"].Winner)
+	assert.Equal(t, taskengine.WorkerID(""), stats.Tasks["isin3"].Winner)
+}
+
+func TestGetErrors(t *testing.T) {
+	// no isins
+	results, err := Get(context.Background(), nil, nil)
+	if assert.NoError(t, err) {
+		assert.Empty(t, results)
+	}
+
+	// unknown source
+	opts := &Options{
+		Sources: []*SourceOptions{{Name: "test-unknown"}},
+	}
+	_, err = Get(context.Background(), []string{"isin1"}, opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not available")
+	}
+}
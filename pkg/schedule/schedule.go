@@ -0,0 +1,278 @@
+// Package schedule parses the specification of a recurring schedule
+// and computes its activation times.
+//
+// A specification can be:
+//
+//	a duration           "30m", "1h30m": every interval
+//	"@every <duration>"  same as above
+//	a descriptor         "@hourly", "@daily" (or "@midnight"), "@weekly",
+//	                     "@monthly", "@yearly" (or "@annually")
+//	a cron expression    "minute hour day-of-month month day-of-week"
+//
+// Each field of a cron expression is a comma separated list of
+// "*", a value "v", a range "a-b", optionally followed by a step "/n".
+// Months and days of the week can also be specified by their
+// three letter english names (jan-dec, sun-sat); Sunday is 0 or 7.
+// As in cron, if both day-of-month and day-of-week are restricted,
+// a day matches if either field matches.
+//
+// For example "0 18 * * mon-fri" activates at 18:00 from Monday to Friday.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes a recurring activation time.
+type Schedule interface {
+	// Next returns the first activation time after t,
+	// or the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// Every is a schedule that activates at fixed intervals.
+type Every time.Duration
+
+// Next returns t plus the interval.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// String returns the interval as a duration.
+func (e Every) String() string {
+	return time.Duration(e).String()
+}
+
+var descriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// Parse returns the schedule of the specification.
+func Parse(spec string) (Schedule, error) {
+	s := strings.TrimSpace(spec)
+
+	errInvalid := func(msg string) (Schedule, error) {
+		return nil, fmt.Errorf("invalid schedule %q: %s", spec, msg)
+	}
+
+	if s == "" {
+		return errInvalid("empty specification")
+	}
+
+	if strings.HasPrefix(s, "@every ") {
+		s = strings.TrimSpace(strings.TrimPrefix(s, "@every "))
+	} else if d, ok := descriptors[s]; ok {
+		s = d
+	} else if strings.HasPrefix(s, "@") {
+		return errInvalid("unknown descriptor")
+	}
+
+	// duration
+	if len(strings.Fields(s)) == 1 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errInvalid("invalid duration")
+		}
+		if d <= 0 {
+			return errInvalid("duration must be greater than zero")
+		}
+		return Every(d), nil
+	}
+
+	c, err := parseCron(s)
+	if err != nil {
+		return errInvalid(err.Error())
+	}
+	return c, nil
+}
+
+// field is the definition of a field of a cron expression.
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string // names of the values, starting from min
+}
+
+var (
+	fieldMinute = field{name: "minute", min: 0, max: 59}
+	fieldHour   = field{name: "hour", min: 0, max: 23}
+	fieldDom    = field{name: "day of month", min: 1, max: 31}
+	fieldMonth  = field{name: "month", min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	fieldDow = field{name: "day of week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// cron is a schedule defined by a cron expression.
+// Each field is a bit set of the matching values.
+type cron struct {
+	spec    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+func parseCron(spec string) (*cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, found %d", len(fields))
+	}
+
+	c := &cron{spec: spec}
+	dst := []struct {
+		bits *uint64
+		f    field
+	}{
+		{&c.minute, fieldMinute},
+		{&c.hour, fieldHour},
+		{&c.dom, fieldDom},
+		{&c.month, fieldMonth},
+		{&c.dow, fieldDow},
+	}
+	for j, d := range dst {
+		bits, err := d.f.parse(fields[j])
+		if err != nil {
+			return nil, err
+		}
+		*d.bits = bits
+	}
+
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	// as vixie cron, a field starting with "*" (e.g. "*/2") is a star field
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// value parses a single value of the field, as a number or a name.
+func (f *field) value(s string) (int, error) {
+	for j, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + j, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s %d out of range [%d, %d]", f.name, n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// parse returns the bit set of the values of the field.
+func (f *field) parse(s string) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(s, ",") {
+		var (
+			lo, hi int
+			step   = 1
+			err    error
+		)
+
+		rng := item
+		if idx := strings.IndexByte(item, '/'); idx >= 0 {
+			rng = item[:idx]
+			step, err = strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, item[idx+1:])
+			}
+		}
+
+		switch idx := strings.IndexByte(rng, '-'); {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case idx > 0:
+			if lo, err = f.value(rng[:idx]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rng[idx+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rng)
+			}
+		default:
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if step > 1 {
+				// "v/n" means from v to max, every n
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// dayMatches returns if the day of t matches the day-of-month
+// and the day-of-week fields.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first activation time after t, in the location of t.
+// It returns the zero time if no activation time is found
+// in the next five years (e.g. "0 0 30 2 *").
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// NOTE: minutes are added as absolute time, to move forward
+	// when the clock is set back at the end of the daylight saving time.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	yearLimit := t.Year() + 5
+	for t.Year() <= yearLimit {
+		y, m, d := t.Date()
+		switch {
+		case !has(c.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the cron expression.
+func (c *cron) String() string {
+	return c.spec
+}
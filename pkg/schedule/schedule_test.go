@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                "empty specification",
+		"@sometimes":      "unknown descriptor",
+		"xyz":             "invalid duration",
+		"-5m":             "greater than zero",
+		"@every 0s":       "greater than zero",
+		"* * * *":         "expected 5 fields",
+		"60 * * * *":      "minute 60 out of range",
+		"* 24 * * *":      "hour 24 out of range",
+		"* * 0 * *":       "day of month 0 out of range",
+		"* * * foo *":     "invalid month \"foo\"",
+		"* * * * 8":       "day of week 8 out of range",
+		"*/0 * * * *":     "invalid minute step",
+		"30-10 * * * *":   "invalid minute range",
+		"* * * * mon-xyz": "invalid day of week",
+	}
+	for spec, errmsg := range cases {
+		_, err := Parse(spec)
+		if assert.Error(t, err, spec) {
+			assert.Contains(t, err.Error(), errmsg, spec)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	t0 := time.Date(2020, 10, 31, 16, 59, 2, 0, time.UTC)
+	for _, spec := range []string{"90m", "@every 1h30m"} {
+		s, err := Parse(spec)
+		if assert.NoError(t, err, spec) {
+			assert.Equal(t, Every(90*time.Minute), s, spec)
+			assert.Equal(t, t0.Add(90*time.Minute), s.Next(t0), spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Saturday
+	t0 := time.Date(2020, 10, 31, 16, 59, 2, 0, time.UTC)
+	date := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2020, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", date(10, 31, 17, 0)},
+		{"@hourly", date(10, 31, 17, 0)},
+		{"@daily", date(11, 1, 0, 0)},
+		{"@weekly", date(11, 1, 0, 0)},
+		{"@monthly", date(11, 1, 0, 0)},
+		{"@yearly", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", date(10, 31, 17, 0)},
+		{"59 16 * * *", date(11, 1, 16, 59)},
+		{"5/20 17 * * *", date(10, 31, 17, 5)},
+		{"0 18 * * mon-fri", date(11, 2, 18, 0)},
+		{"0 18 * * 1-5", date(11, 2, 18, 0)},
+		{"0 9,18 * * 7", date(11, 1, 9, 0)},
+		{"0 0 15 * *", date(11, 15, 0, 0)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week
+		{"0 0 15 * mon", date(11, 2, 0, 0)},
+		{"0 0 30 2 *", time.Time{}},
+		// a step of the star is still a star: odd day of month and Monday
+		{"0 9 */2 * 1", date(11, 9, 9, 0)},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if assert.NoError(t, err, c.spec) {
+			assert.Equal(t, c.want, s.Next(t0), c.spec)
+		}
+	}
+}
+
+func TestCronDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	s, err := Parse("30 * * * *")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// end of daylight saving time: the clock is set back from 3:00 to 2:00
+	t0 := time.Date(2020, 10, 25, 2, 40, 0, 0, loc) // first 2:40 (CEST)
+	next := s.Next(t0)
+	assert.True(t, next.After(t0))
+	next2 := s.Next(next)
+	assert.True(t, next2.After(next))
+	assert.Equal(t, time.Hour, next2.Sub(next))
+}
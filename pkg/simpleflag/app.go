@@ -1,12 +1,11 @@
-//
 // Package simpleflag is useful for creating command line Go applications.
 //
-// Limitations
+// # Limitations
 //
 // No arguments are managed, only flags.
 // The App must have subcommands.
 //
-// Configuration
+// # Configuration
 //
 // App is the main structure of the cli application.
 // The App has a list of Commands.
@@ -17,38 +16,38 @@
 // Bool, Int, String have the Passed field, indicating if the flag was
 // setted in the command line.
 //
-// Example
+// # Example
 //
 // Example of a configuration of simple "myapp" cli application,
 // with a single "get" command.
 //
-//    type myappArgs struct {
-//        config   simpleflag.String
-//        workers  simpleflag.Int
-//        dryrun   simpleflag.Bool
-//        items    simpleflag.Strings
-//    }
+//	type myappArgs struct {
+//	    config   simpleflag.String
+//	    workers  simpleflag.Int
+//	    dryrun   simpleflag.Bool
+//	    items    simpleflag.Strings
+//	}
 //
-//    args := myappArgs{}
+//	args := myappArgs{}
 //
-//    app := &simpleflag.App{
-//        Name:     "myapp",
-//        Usage:    "myapp <command>",
-//        Commands: []*simpleflag.Command{
-//            &simpleflag.Command{
-//                Names: "get,g",
-//                Usage: "myapp get [options]",
-//                Flags: []*simpleflag.Flag{
-//                    {Value: &args.config, Names: "c,config"},
-//                    {Value: &args.workers, Names: "w,workers"},
-//                    {Value: &args.dryrun, Names: "n,dryrun,dry-run"},
-//                    {Value: &args.items, Names: "i,items"},
-//                },
-//            },
-//        },
-//    }
+//	app := &simpleflag.App{
+//	    Name:     "myapp",
+//	    Usage:    "myapp <command>",
+//	    Commands: []*simpleflag.Command{
+//	        &simpleflag.Command{
+//	            Names: "get,g",
+//	            Usage: "myapp get [options]",
+//	            Flags: []*simpleflag.Flag{
+//	                {Value: &args.config, Names: "c,config"},
+//	                {Value: &args.workers, Names: "w,workers"},
+//	                {Value: &args.dryrun, Names: "n,dryrun,dry-run"},
+//	                {Value: &args.items, Names: "i,items"},
+//	            },
+//	        },
+//	    },
+//	}
 //
-// Usage
+// # Usage
 //
 // First App.Parse function parses the arguments list.
 //
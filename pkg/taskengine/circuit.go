@@ -0,0 +1,119 @@
+package taskengine
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error of the tasks skipped
+// because the circuit of the worker is open.
+var ErrCircuitOpen = errors.New("skipped: circuit open")
+
+// SkippedResult is the Result of a task not handed to the worker.
+type SkippedResult struct {
+	WorkerID WorkerID
+	Task     Task
+	Err      error
+}
+
+// Success returns false.
+func (r *SkippedResult) Success() bool { return false }
+
+// Status returns Skipped.
+func (r *SkippedResult) Status() StatusType { return Skipped }
+
+// CircuitBreaker stops handing tasks to a worker after
+// a number of consecutive failures: the circuit of the worker is open.
+// The remaining tasks of the worker are skipped,
+// and a SkippedResult with ErrCircuitOpen error is returned for each of them.
+//
+// If CoolOff is greater than zero, the circuit is closed again after
+// the cool-off period: the worker gets new tasks, and the circuit is
+// opened again at the first failure.
+// Otherwise the circuit stays open for the lifetime of the CircuitBreaker.
+//
+// A CircuitBreaker is safe for concurrent use, and it can be shared by
+// different executions, e.g. by the consecutive runs of a daemon.
+// The failures of the tasks canceled by the engine are not counted.
+type CircuitBreaker struct {
+	threshold int
+	coolOff   time.Duration
+
+	mu       sync.Mutex
+	circuits map[WorkerID]*circuit
+}
+
+// circuit is the state of the circuit of a worker.
+type circuit struct {
+	failures int       // consecutive failures
+	openedAt time.Time // zero if the circuit is closed
+}
+
+// NewCircuitBreaker returns a new CircuitBreaker that opens the circuit
+// of a worker after threshold consecutive failures.
+func NewCircuitBreaker(threshold int, coolOff time.Duration) (*CircuitBreaker, error) {
+	if threshold <= 0 {
+		return nil, errorf("invalid circuit breaker threshold %d", threshold)
+	}
+	if coolOff < 0 {
+		return nil, errorf("invalid circuit breaker cool-off %v", coolOff)
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		coolOff:   coolOff,
+		circuits:  map[WorkerID]*circuit{},
+	}, nil
+}
+
+func (cb *CircuitBreaker) circuit(wid WorkerID) *circuit {
+	c := cb.circuits[wid]
+	if c == nil {
+		c = &circuit{}
+		cb.circuits[wid] = c
+	}
+	return c
+}
+
+// record updates the consecutive failures of the worker
+// and opens its circuit when the threshold is reached.
+func (cb *CircuitBreaker) record(wid WorkerID, success bool, now time.Time) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(wid)
+	if success {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= cb.threshold && c.openedAt.IsZero() {
+		c.openedAt = now
+	}
+}
+
+// Open reports if the circuit of the worker is open.
+// After the cool-off period, the circuit is closed
+// and a single failure opens it again.
+func (cb *CircuitBreaker) Open(wid WorkerID, now time.Time) bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(wid)
+	if c.openedAt.IsZero() {
+		return false
+	}
+	if cb.coolOff > 0 && !now.Before(c.openedAt.Add(cb.coolOff)) {
+		// half-open: the next failure opens the circuit again
+		c.openedAt = time.Time{}
+		c.failures = cb.threshold - 1
+		return false
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package taskengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewCircuitBreakerErrors(t *testing.T) {
+	testCases := map[string]struct {
+		threshold int
+		coolOff   time.Duration
+		errmsg    string
+	}{
+		"zero threshold": {
+			threshold: 0,
+			errmsg:    "invalid circuit breaker threshold 0",
+		},
+		"negative cool-off": {
+			threshold: 1,
+			coolOff:   -time.Second,
+			errmsg:    "invalid circuit breaker cool-off -1s",
+		},
+	}
+
+	for title, tc := range testCases {
+		_, err := NewCircuitBreaker(tc.threshold, tc.coolOff)
+		if err == nil {
+			t.Errorf("%s: expected error %q, found no error", title, tc.errmsg)
+		} else if err.Error() != tc.errmsg {
+			t.Errorf("%s: expected error %q, found error %q", title, tc.errmsg, err)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	coolOff := time.Minute
+	cb, err := NewCircuitBreaker(2, coolOff)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a success resets the consecutive failures
+	cb.record("w1", false, t0)
+	cb.record("w1", true, t0)
+	cb.record("w1", false, t0)
+	if cb.Open("w1", t0) {
+		t.Errorf("expected closed circuit after a success")
+	}
+
+	// the threshold opens the circuit
+	cb.record("w1", false, t0)
+	if !cb.Open("w1", t0) {
+		t.Errorf("expected open circuit after 2 consecutive failures")
+	}
+	if cb.Open("w2", t0) {
+		t.Errorf("expected closed circuit of the other worker")
+	}
+	if !cb.Open("w1", t0.Add(coolOff/2)) {
+		t.Errorf("expected open circuit before the cool-off")
+	}
+
+	// the cool-off closes the circuit, and a single failure opens it again
+	t1 := t0.Add(coolOff)
+	if cb.Open("w1", t1) {
+		t.Errorf("expected closed circuit after the cool-off")
+	}
+	cb.record("w1", false, t1)
+	if !cb.Open("w1", t1) {
+		t.Errorf("expected open circuit after a failure in half-open state")
+	}
+
+	// nil circuit breaker is always closed
+	var cbnil *CircuitBreaker
+	cbnil.record("w1", false, t0)
+	if cbnil.Open("w1", t0) {
+		t.Errorf("nil circuit breaker: expected closed circuit")
+	}
+}
+
+func TestExecuteCircuit(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, false}, {"t2", 10, false}, {"t3", 10, true}, {"t4", 10, true}},
+		"w2": {{"t3", 50, true}},
+	})
+
+	cb, err := NewCircuitBreaker(2, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	out, err := ExecuteWithOptions(context.Background(), workers, tasks, &Options{Mode: All, Circuit: cb})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	results := testCaseResults{}
+	skipped := []TaskID{}
+	for res := range out {
+		switch r := res.(type) {
+		case *testResult:
+			results = append(results, r.ToTestCaseResult())
+		case *SkippedResult:
+			if r.Err != ErrCircuitOpen {
+				t.Errorf("skipped %s: expected error %v, found %v", r.Task.TaskID(), ErrCircuitOpen, r.Err)
+			}
+			skipped = append(skipped, r.Task.TaskID())
+		}
+	}
+
+	expected := testCaseResults{
+		{"t1", "w1", false},
+		{"t2", "w1", false},
+		{"t3", "w2", true},
+	}
+	copts := cmp.Options{
+		cmpopts.SortSlices(testCaseResultLess),
+	}
+	if diff := cmp.Diff(expected, results, copts); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+	copts = cmp.Options{
+		cmpopts.SortSlices(func(a, b TaskID) bool { return a < b }),
+	}
+	if diff := cmp.Diff([]TaskID{"t3", "t4"}, skipped, copts); diff != "" {
+		t.Errorf("skipped mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,41 @@
+package taskengine
+
+import "time"
+
+// Clock is the source of time of an execution:
+// the elapsed time of the works, the hedge delay, the priority window,
+// the cool-off of the circuit breaker and the statistics.
+// It can be replaced by a simulated clock in the tests,
+// see the taskenginetest package.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer returns a new Timer that sends the current time
+	// on its channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing.
+	// It returns false if the timer has already expired or been stopped.
+	Stop() bool
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+// systemTimer is the Timer of the system clock.
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+
+func (t systemTimer) Stop() bool { return t.t.Stop() }
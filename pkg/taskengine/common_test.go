@@ -60,6 +60,7 @@ func (t *testCaseTask) Equal(other *testCaseTask) bool { return t.taskid == othe
 func (t *testCaseTask) String() string                 { return string(t.taskid) }
 
 func (res *testResult) Success() bool { return res.err == nil }
+func (res *testResult) Err() error    { return res.err }
 func (res *testResult) Status() string {
 	if res.err == nil {
 		return "SUCCESS"
@@ -18,10 +18,17 @@
 // - UntilFirstSuccess: For each task returns the (not successfull) result of all the workers: after the first success the other requests are cancelled.
 //
 // - All: For each task returns the result of all the workers. Multiple success results can be returned.
+//
+// - Hedged: Each task is sent to the preferred worker first, and to the next worker only if no success arrives within the hedge delay or the previous workers fail. For each task it returns only one result, as FirstSuccessOrLastError.
+//
+// - Quorum: For each task it returns only one result, reconciled from the results of the workers collected until a quorum of successes is reached.
 package taskengine
 
 import (
 	"context"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine/internal/hook"
 )
 
 // Mode of execution for each task.
@@ -42,14 +49,77 @@ const (
 	// Multiple success results can be returned.
 	// After the first success, the remaining requests are cancelled.
 	All
+
+	// For each task returns only one result:
+	// the first success or the last error.
+	// The task is sent to the preferred worker first,
+	// and to the next worker only if no success arrives within
+	// the hedge delay or the task fails with all the previous workers.
+	// The order of preference is given by the priority of the tasks,
+	// then by the order of the workers list.
+	Hedged
+
+	// For each task returns only one result, built from the results of the
+	// workers collected until the quorum of successes is reached, or all
+	// the workers have done the task: see Options.Quorum and Options.Reconcile.
+	// After the quorum is reached, the remaining requests are cancelled.
+	Quorum
 )
 
+// Options contains the options of the execution.
+type Options struct {
+	// Mode of execution for each task.
+	Mode Mode
+
+	// HedgeDelay is the time after that a task is sent to the next worker
+	// in Hedged mode, if no success has arrived.
+	// If zero, the task is sent to the next worker only after the failure
+	// of the previous ones.
+	HedgeDelay time.Duration
+
+	// PriorityWindow is the maximum time a success is held
+	// in FirstSuccessOrLastError and Hedged mode, while a worker with higher
+	// priority is doing the same task: if the latter succeeds within the window,
+	// its result is returned instead.
+	// If zero, the first success is returned immediately.
+	PriorityWindow time.Duration
+
+	// Circuit stops handing tasks to the workers after
+	// consecutive failures. If nil, no circuit breaker is used.
+	Circuit *CircuitBreaker
+
+	// Quorum is the number of successes of a task needed in Quorum mode.
+	// If zero, the results of all the workers are collected.
+	Quorum int
+
+	// Reconcile returns the result of a task in Quorum mode,
+	// given the results of the workers in order of arrival.
+	// If nil, a QuorumResult containing them is returned.
+	Reconcile func(tid TaskID, results []Result) Result
+
+	// Observer, if not nil, receives the events of the execution.
+	Observer Observer
+
+	// Clock is the source of time of the execution.
+	// If nil, the system clock is used.
+	Clock Clock
+
+	// Stats, if not nil, receives the statistics of the execution.
+	// It is complete when the results channel is closed,
+	// and it must not be shared by concurrent executions.
+	Stats *Stats
+}
+
 // engine contains the workers and the tasks of each worker.
 type engine struct {
 	workers     map[WorkerID]*Worker
 	widtasks    WorkerTasks // map[WorkerID]*Tasks
 	ctx         context.Context
 	workersList []*Worker // original workers list
+
+	// in a Pool, the free instances of each worker,
+	// shared by all the tasks. Nil otherwise.
+	slots map[WorkerID]chan int
 }
 
 // jobInput is the internal struct passed to a worker to execute a task.
@@ -73,8 +143,9 @@ type jobInput struct {
 type jobOutput struct {
 	wid      WorkerID
 	instance int
-	res      Result // can be nil
-	task     Task   // not used if res is nil
+	res      Result        // can be nil
+	task     Task          // not used if res is nil
+	elapsed  time.Duration // duration of the work
 }
 
 // newEngine initialize a new engine object from the list of workers and the tasks of each worker.
@@ -147,14 +218,34 @@ func newEngine(ctx context.Context, ws []*Worker, wts WorkerTasks) (*engine, err
 
 // Execute returns a chan that receives the Results of the workers for the input Requests.
 func (eng *engine) Execute(mode Mode) (chan Result, error) {
+	return eng.execute(&Options{Mode: mode})
+}
+
+// execute returns a chan that receives the Results of the workers for the input Requests,
+// executed with the given options.
+func (eng *engine) execute(opts *Options) (chan Result, error) {
 
 	if eng == nil {
 		return nil, errorf("nil engine")
 	}
+	mode := opts.Mode
 
 	// creates the Result channel
 	resultc := make(chan Result)
 
+	clock := opts.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	opts.Stats.reset(eng.workersList, eng.widtasks, clock.Now())
+
+	obs := opts.Observer
+	if obs == nil {
+		obs = nopObserver{}
+	}
+	wobs, _ := obs.(hook.Waiter)
+
 	// creates the *jobOutput channel
 	outputc := make(chan *jobOutput)
 
@@ -187,21 +278,64 @@ func (eng *engine) Execute(mode Mode) (chan Result, error) {
 		// for each worker instances
 		for i := 0; i < worker.Instances; i++ {
 
-			go func(w *Worker, inst int, inputc <-chan *jobInput) {
+			go func(w *Worker, inst int, inputc <-chan *jobInput, slots chan int) {
 				for req := range inputc {
+					// canceled sends the result of the task
+					// canceled before calling the work function.
+					canceled := func(winst int, err error) {
+						req.outc <- &jobOutput{
+							wid:      w.WorkerID,
+							instance: winst,
+							res:      &CanceledResult{WorkerID: w.WorkerID, Task: req.task, Err: err},
+							task:     req.task,
+						}
+					}
+
+					// in a Pool, wait a free instance of the worker.
+					slot := -1
+					if slots != nil {
+						select {
+						case slot = <-slots:
+						case <-req.ctx.Done():
+							canceled(inst, req.ctx.Err())
+							continue
+						}
+					}
+					winst := inst
+					if slot >= 0 {
+						winst = slot
+					}
+
+					// wait the turn of the work, shared by all the instances.
+					if err := w.Rate.wait(req.ctx, clock); err != nil {
+						if slot >= 0 {
+							slots <- slot
+						}
+						canceled(winst, err)
+						continue
+					}
+
 					// get the worker result of the task
-					res := w.Work(req.ctx, inst, req.task)
+					obs.TaskStarted(w.WorkerID, winst, req.task.TaskID())
+					start := clock.Now()
+					res := w.Work(req.ctx, winst, req.task)
+					elapsed := clock.Now().Sub(start)
+
+					if slot >= 0 {
+						slots <- slot
+					}
 
 					// send the result to the output chan
 					jout := jobOutput{
 						wid:      w.WorkerID,
-						instance: inst,
+						instance: winst,
 						res:      res,
 						task:     req.task,
+						elapsed:  elapsed,
 					}
 					req.outc <- &jout
 				}
-			}(worker, i, inputc[worker.WorkerID])
+			}(worker, i, inputc[worker.WorkerID], eng.slots[worker.WorkerID])
 		}
 	}
 
@@ -228,100 +362,330 @@ func (eng *engine) Execute(mode Mode) (chan Result, error) {
 		// iter := 0
 		statusMap := newTaskStatusMap(eng.widtasks)
 
-		// for iter := 0; iter < totTasks; iter++ {
-		for !statusMap.completed() {
+		// priority of each task for each worker
+		prio := newPriorityMap(eng.widtasks)
 
-			// iter++
-			// if iter > 100 {
-			// log.Println("MAX ITER !!!!")
-			// break
-			// }
-			// log.Printf("iter: %02d\n", iter)
-			// log.Println(tim)
+		// workers doing each task
+		running := runningMap{}
 
-			// get the next output
-			o := <-outputc
+		// in FirstSuccessOrLastError and Hedged mode,
+		// the success results waiting for the workers with higher priority
+		held := map[TaskID]*heldResult{}
 
-			// log.Println(o)
+		// in Hedged mode, the tasks released to each worker
+		var hedge hedgeMap
+		if mode == Hedged {
+			hedge = newHedgeMap(eng.workersList, eng.widtasks, prio, clock.Now())
+		}
 
-			// handle result
-			if o.res != nil {
-				success := o.res.Success()
-				tid := o.task.TaskID()
+		// in Quorum mode, the results collected for each task
+		quorum := quorumMap{}
+
+		// number of ready instances of each worker
+		// waiting for a task to be released
+		idle := map[WorkerID]int{}
+
+		// timer of the next release of a task in Hedged mode,
+		// or of the next held result to return
+		var timer Timer
+		var timerc <-chan time.Time
+
+		// reason of the cancellation of each task by the engine
+		cancelReason := map[TaskID]error{}
+
+		// cancelTask cancels the context of the task for the given reason.
+		cancelTask := func(tid TaskID, reason error) {
+			if taskctx[tid].Err() == nil {
+				cancelReason[tid] = reason
+				obs.TaskCanceled(tid)
+			}
+			taskcancel[tid]()
+		}
+
+		// release returns the held result of the task, if any,
+		// unless it has to wait for a worker with higher priority.
+		release := func(tid TaskID, now time.Time) {
+			h := held[tid]
+			if h == nil {
+				return
+			}
+			if opts.PriorityWindow > 0 && now.Before(h.deadline) && running.higher(prio, tid, h.priority) {
+				return
+			}
+			delete(held, tid)
+			// call cancel func for the task context
+			cancelTask(tid, ErrTaskSucceeded)
+			opts.Stats.win(tid, h.wid)
+			resultc <- h.res
+		}
+
+		// handle handles the result of the task done by the worker.
+		handle := func(o *jobOutput) {
+			success := o.res.Success()
+			tid := o.task.TaskID()
+
+			// the status of the result:
+			// a failure of a canceled task is canceled
+			var rstatus StatusType
+			var reason error
+			if sr, skipped := o.res.(*SkippedResult); skipped {
+				rstatus, reason = Skipped, sr.Err
+			} else if success {
+				rstatus = Success
+			} else if err := taskctx[tid].Err(); err != nil {
+				rstatus, reason = Canceled, cancelReason[tid]
+				if reason == nil {
+					// the context of the execution is done
+					reason = err
+				}
+			} else {
+				rstatus = Error
+			}
+			if s, ok := o.res.(StatusSetter); ok {
+				s.SetStatus(rstatus, reason)
+			}
+
+			// updates the consecutive failures of the worker
+			// and the statistics.
+			// The failures of the canceled tasks are not counted.
+			if rstatus == Skipped {
+				opts.Stats.skip(o.wid)
+			} else {
+				if rstatus != Canceled {
+					opts.Circuit.record(o.wid, success, clock.Now())
+				}
+				opts.Stats.work(o.wid, tid, o.elapsed, success, rstatus == Canceled)
+			}
+
+			// updates task info map
+			statusMap.done(tid, success)
+			running.done(tid, o.wid)
+			status := statusMap[tid]
+			obs.TaskFinished(o.wid, o.instance, tid, o.res)
 
-				// updates task info map
-				statusMap.done(tid, success)
-				status := statusMap[tid]
+			if success && mode != FirstSuccessOrLastError && mode != Hedged && mode != Quorum {
+				// call cancel func for the task context
+				cancelTask(tid, ErrTaskSucceeded)
+			}
 
+			if hedge != nil {
 				if success {
-					// call cancel func for the task context
-					taskcancel[tid]()
+					// the workers the task was not released to
+					// don't have to do the task anymore
+					for _, wid := range hedge.succeeded(tid) {
+						ts := widtasks[wid]
+						for j, t := range ts {
+							if t.TaskID() == tid {
+								ts.Remove(j)
+								status.todo--
+								break
+							}
+						}
+						widtasks[wid] = ts
+					}
+				} else {
+					hedge.failed(tid, status, clock.Now())
 				}
+			}
 
-				switch mode {
-				case FirstSuccessOrLastError:
-					if (success && status.success == 1) || (status.completed() && status.success == 0) {
-						// return the result if:
-						// - it is the first success, or
-						// - it is completed and no success was found
-						resultc <- o.res
+			if status.completed() {
+				obs.TaskCompleted(tid, status.success > 0)
+			}
+
+			switch mode {
+			case FirstSuccessOrLastError, Hedged:
+				// hold the success if:
+				// - it is the first success, or
+				// - the held success has lower priority
+				now := clock.Now()
+				if success {
+					p := prio[tid][o.wid]
+					if h := held[tid]; h != nil {
+						if p > h.priority {
+							h.res, h.wid, h.priority = o.res, o.wid, p
+						}
+					} else if status.success == 1 {
+						held[tid] = &heldResult{
+							res:      o.res,
+							wid:      o.wid,
+							priority: p,
+							deadline: now.Add(opts.PriorityWindow),
+						}
+					}
+				}
+				release(tid, now)
+				if status.completed() && status.success == 0 {
+					// return the result if
+					// it is completed and no success was found
+					resultc <- o.res
+				}
+			case Quorum:
+				if quorum.add(tid, o, status, opts.Quorum) {
+					// call cancel func for the task context
+					cancelTask(tid, ErrQuorumReached)
+					outs := quorum.take(tid)
+					for _, out := range outs {
+						if out.res.Success() {
+							opts.Stats.win(tid, out.wid)
+							break
+						}
 					}
-				case UntilFirstSuccess:
-					if (success && status.success == 1) || (!success && status.success == 0) {
-						// return the result if:
-						// - it is the first success, or
-						// - it is a error and no success was found
-						resultc <- o.res
+					resultc <- reconcile(tid, outs, opts.Reconcile)
+				}
+			case UntilFirstSuccess:
+				if (success && status.success == 1) || (!success && status.success == 0) {
+					// return the result if:
+					// - it is the first success, or
+					// - it is a error and no success was found
+					if success {
+						opts.Stats.win(tid, o.wid)
 					}
-				default:
 					resultc <- o.res
 				}
+			default:
+				if success {
+					opts.Stats.win(tid, o.wid)
+				}
+				resultc <- o.res
+			}
+		}
+
+		// dispatch sends the next task to a ready instance of the worker.
+		// If the worker has no more tasks, its input chan is closed.
+		// It returns false if no task has been sent.
+		dispatch := func(wid WorkerID) bool {
+			if opts.Circuit.Open(wid, clock.Now()) {
+				// skip all the remaining tasks of the worker
+				ts := widtasks[wid]
+				widtasks[wid] = nil
+				for _, t := range ts {
+					tid := t.TaskID()
+					statusMap.doing(tid)
+					running.doing(tid, wid)
+					handle(&jobOutput{
+						wid:      wid,
+						instance: -1,
+						res:      &SkippedResult{WorkerID: wid, Task: t, Err: ErrCircuitOpen},
+						task:     t,
+					})
+				}
 			}
 
 			// select the next task of the worker
-			var nexttask Task
-			{
-				ts := widtasks[o.wid]
-				n := statusMap.pick(ts)
-				if n >= 0 {
-					nexttask = ts.Remove(n)
-					widtasks[o.wid] = ts
+			ts := widtasks[wid]
+			n := statusMap.pickFunc(ts, hedge.allowed(wid), prio.rank(wid))
+			if n < 0 {
+				if len(ts) == 0 {
+					// close the worker chan
+					// NOTE: in case of a worker with two or more instances,
+					// the close of the channel must be called only once. Else
+					//    panic: close of closed channel
+					if ch, ok := inputc[wid]; ok {
+						close(ch)
+						delete(inputc, wid)
+						obs.WorkerClosed(wid)
+					}
 				}
+				return false
 			}
+			nexttask := ts.Remove(n)
+			widtasks[wid] = ts
+
+			tid := nexttask.TaskID()
 
-			if nexttask == nil {
-				// log.Println("nexttask = <nil>")
+			// updates task info map
+			statusMap.doing(tid)
+			running.doing(tid, wid)
 
-				// close the worker chan
-				// NOTE: in case of a worker with two or more instances,
-				// the close of the channel must be called only once. Else
-				//    panic: close of closed channel
-				if ch, ok := inputc[o.wid]; ok {
-					close(ch)
-					delete(inputc, o.wid)
+			i := &jobInput{
+				ctx:    taskctx[tid],
+				cancel: taskcancel[tid],
+				task:   nexttask,
+				outc:   outputc,
+			}
+			obs.TaskAssigned(wid, tid)
+			inputc[wid] <- i
+			return true
+		}
+
+		// for iter := 0; iter < totTasks; iter++ {
+		for !statusMap.completed() {
+
+			// get the next output, or the timer
+			if wobs != nil {
+				wobs.Waiting()
+			}
+			var o *jobOutput
+			select {
+			case o = <-outputc:
+			case now := <-timerc:
+				hedge.expire(now, opts.HedgeDelay)
+				for tid := range held {
+					release(tid, now)
 				}
+			}
 
-			} else {
-				tid := nexttask.TaskID()
+			// handle result
+			if o != nil && o.res != nil {
+				handle(o)
+			}
 
-				// updates task info map
-				statusMap.doing(tid)
+			// the instance that sent the output is ready for the next task
+			if o != nil {
+				if !dispatch(o.wid) && len(widtasks[o.wid]) > 0 {
+					idle[o.wid]++
+					obs.WorkerIdle(o.wid, o.instance)
+				}
+			}
 
-				// log.Printf("nexttask = %s\n", tid)
+			if hedge == nil && len(held) == 0 {
+				continue
+			}
 
-				i := &jobInput{
-					ctx:    taskctx[tid],
-					cancel: taskcancel[tid],
-					task:   nexttask,
-					outc:   outputc,
+			// send the released tasks to the idle instances
+			for wid, n := range idle {
+				for n > 0 && dispatch(wid) {
+					n--
+				}
+				if n == 0 || len(widtasks[wid]) == 0 {
+					delete(idle, wid)
+				} else {
+					idle[wid] = n
 				}
-				inputc[o.wid] <- i
+			}
+
+			// reset the timer of the next release
+			if timer != nil {
+				timer.Stop()
+				timer = nil
+			}
+			timerc = nil
+			next := hedge.next(opts.HedgeDelay)
+			if opts.PriorityWindow > 0 {
+				for _, h := range held {
+					if next.IsZero() || h.deadline.Before(next) {
+						next = h.deadline
+					}
+				}
+			}
+			if !next.IsZero() {
+				timer = clock.NewTimer(next.Sub(clock.Now()))
+				timerc = timer.C()
 			}
 		}
 
-		// log.Println("END LOOP")
+		if timer != nil {
+			timer.Stop()
+		}
+
+		// close the chan of the workers with idle instances
+		for wid, ch := range inputc {
+			close(ch)
+			delete(inputc, wid)
+			obs.WorkerClosed(wid)
+		}
 
-		// log.Println(tim)
+		opts.Stats.finish(clock.Now())
 
 		close(outputc)
 		close(resultc)
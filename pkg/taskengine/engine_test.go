@@ -43,45 +43,45 @@ func Test1NewEngine(t *testing.T) {
 	testCases := map[string]testCase{
 		"duplicate worker": {
 			workers: []*Worker{
-				{"w1", 1, workFn},
-				{"w2", 2, workFn},
-				{"w1", 3, workFn},
+				{WorkerID: "w1", Instances: 1, Work: workFn},
+				{WorkerID: "w2", Instances: 2, Work: workFn},
+				{WorkerID: "w1", Instances: 3, Work: workFn},
 			},
 			input: map[string]testCaseTasks{},
 			err:   errors.New("duplicate worker: WorkerID=\"w1\""),
 		},
 		"instances < 1": {
 			workers: []*Worker{
-				{"w1", 1, workFn},
-				{"w2", 2, workFn},
-				{"w3", 0, workFn},
+				{WorkerID: "w1", Instances: 1, Work: workFn},
+				{WorkerID: "w2", Instances: 2, Work: workFn},
+				{WorkerID: "w3", Instances: 0, Work: workFn},
 			},
 			input: map[string]testCaseTasks{},
 			err:   errors.New("instances must be in 1..100 range: WorkerID=\"w3\""),
 		},
 		"instances > 100": {
 			workers: []*Worker{
-				{"w1", 1, workFn},
-				{"w2", 2, workFn},
-				{"w3", 101, workFn},
+				{WorkerID: "w1", Instances: 1, Work: workFn},
+				{WorkerID: "w2", Instances: 2, Work: workFn},
+				{WorkerID: "w3", Instances: 101, Work: workFn},
 			},
 			input: map[string]testCaseTasks{},
 			err:   errors.New("instances must be in 1..100 range: WorkerID=\"w3\""),
 		},
 		"ko work function": {
 			workers: []*Worker{
-				{"w1", 1, workFn},
-				{"w2", 2, nil},
-				{"w3", 3, workFn},
+				{WorkerID: "w1", Instances: 1, Work: workFn},
+				{WorkerID: "w2", Instances: 2, Work: nil},
+				{WorkerID: "w3", Instances: 3, Work: workFn},
 			},
 			input: map[string]testCaseTasks{},
 			err:   errors.New("work function cannot be nil: WorkerID=\"w2\""),
 		},
 		"undefined worker": {
 			workers: []*Worker{
-				{"w1", 1, workFn},
-				{"w2", 2, workFn},
-				{"w3", 3, workFn},
+				{WorkerID: "w1", Instances: 1, Work: workFn},
+				{WorkerID: "w2", Instances: 2, Work: workFn},
+				{WorkerID: "w3", Instances: 3, Work: workFn},
 			},
 			input: map[string]testCaseTasks{
 				"w1":   {{"t3", 30, true}, {"t2", 20, true}, {"t1", 10, true}},
@@ -114,9 +114,9 @@ func Test1NewEngine(t *testing.T) {
 
 func TestExecute3FirstSuccessOrLastError(t *testing.T) {
 	workers := []*Worker{
-		{"w1", 1, workFn},
-		{"w2", 1, workFn},
-		{"w3", 1, workFn},
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+		{WorkerID: "w3", Instances: 1, Work: workFn},
 	}
 
 	type testCase struct {
@@ -265,9 +265,9 @@ func TestExecute3FirstSuccessOrLastError(t *testing.T) {
 
 func Test3ExecuteUntilFirstSuccess(t *testing.T) {
 	workers := []*Worker{
-		{"w1", 1, workFn},
-		{"w2", 1, workFn},
-		{"w3", 1, workFn},
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+		{WorkerID: "w3", Instances: 1, Work: workFn},
 	}
 
 	type testCase struct {
@@ -355,10 +355,10 @@ func Test3ExecuteUntilFirstSuccess(t *testing.T) {
 
 func TestExecute3All(t *testing.T) {
 	workers := []*Worker{
-		{"w1", 1, workFn},
-		{"w2", 1, workFn},
-		{"w3", 1, workFn},
-		{"w4", 1, workFn},
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+		{WorkerID: "w3", Instances: 1, Work: workFn},
+		{WorkerID: "w4", Instances: 1, Work: workFn},
 	}
 
 	type testCase struct {
@@ -400,6 +400,14 @@ func TestExecute3All(t *testing.T) {
 		for res := range out {
 			tres := res.(*testResult)
 			results = append(results, tres.ToTestCaseResult())
+
+			// the failures are canceled by the success:
+			// testResult is not a StatusSetter, but an ErrorResult
+			if !res.Success() {
+				if StatusOf(res) != Canceled {
+					t.Errorf("%s: expected canceled result, found %v %v", title, StatusOf(res), res)
+				}
+			}
 		}
 
 		if diff := cmp.Diff(tc.expected, results, copts); diff != "" {
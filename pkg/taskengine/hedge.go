@@ -0,0 +1,114 @@
+package taskengine
+
+import "time"
+
+// hedgeStat contains the hedging status of a task.
+type hedgeStat struct {
+	workers  []WorkerID // workers of the task, in order of preference
+	released int        // number of preferred workers that can do the task
+	at       time.Time  // time of the last release
+}
+
+// hedgeMap maps TaskID -> hedgeStat.
+// It is used in Hedged mode to release each task to the next worker
+// only after the hedge delay or the failure of the previous workers.
+type hedgeMap map[TaskID]*hedgeStat
+
+// newHedgeMap init a new hedgeMap from the workers list and the WorkerTasks.
+// The order of preference of the workers of each task is given by
+// the priority of the task, then by the order of the list.
+// Each task is released to the first worker only.
+func newHedgeMap(ws []*Worker, widtasks WorkerTasks, pm priorityMap, now time.Time) hedgeMap {
+	hm := hedgeMap{}
+	for _, w := range ws {
+		for _, t := range widtasks[w.WorkerID] {
+			tid := t.TaskID()
+			st := hm[tid]
+			if st == nil {
+				st = &hedgeStat{released: 1, at: now}
+				hm[tid] = st
+			}
+			st.workers = append(st.workers, w.WorkerID)
+		}
+	}
+	for tid, st := range hm {
+		pm.sortWorkers(tid, st.workers)
+	}
+	return hm
+}
+
+// allowed returns a function that reports if the task is released to the worker.
+// A nil hedgeMap allows any task.
+func (hm hedgeMap) allowed(wid WorkerID) func(Task) bool {
+	if hm == nil {
+		return nil
+	}
+	return func(t Task) bool {
+		st := hm[t.TaskID()]
+		for j := 0; j < st.released; j++ {
+			if st.workers[j] == wid {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// release releases the task to the next worker, if any.
+func (hm hedgeMap) release(tid TaskID, now time.Time) {
+	st := hm[tid]
+	if st.released < len(st.workers) {
+		st.released++
+		st.at = now
+	}
+}
+
+// failed must be called after a failure of the task.
+// If all the released workers have done the task,
+// the task is released to the next worker.
+func (hm hedgeMap) failed(tid TaskID, stat *taskStat, now time.Time) {
+	if stat.success == 0 && stat.doing == 0 && stat.done >= hm[tid].released {
+		hm.release(tid, now)
+	}
+}
+
+// succeeded must be called after a success of the task.
+// It returns the workers the task was not released to:
+// they don't have to do the task anymore.
+func (hm hedgeMap) succeeded(tid TaskID) []WorkerID {
+	st := hm[tid]
+	skipped := st.workers[st.released:]
+	st.released = len(st.workers)
+	return skipped
+}
+
+// expire releases to the next worker the tasks
+// released more than delay ago.
+func (hm hedgeMap) expire(now time.Time, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	for tid, st := range hm {
+		if st.released < len(st.workers) && !now.Before(st.at.Add(delay)) {
+			hm.release(tid, now)
+		}
+	}
+}
+
+// next returns the time of the next release due to the delay,
+// or the zero time if none.
+func (hm hedgeMap) next(delay time.Duration) time.Time {
+	var next time.Time
+	if delay <= 0 {
+		return next
+	}
+	for _, st := range hm {
+		if st.released < len(st.workers) {
+			t := st.at.Add(delay)
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next
+}
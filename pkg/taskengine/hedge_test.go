@@ -0,0 +1,228 @@
+package taskengine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHedgeMap(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+		{WorkerID: "w3", Instances: 1, Work: workFn},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}},
+		"w2": {{"t1", 10, true}, {"t2", 10, true}},
+		"w3": {{"t1", 10, true}, {"t2", 10, true}},
+	})
+
+	now := time.Now()
+	delay := 100 * time.Millisecond
+	hm := newHedgeMap(workers, tasks, newPriorityMap(tasks), now)
+	statusMap := newTaskStatusMap(tasks)
+
+	released := func(wid, tid string) bool {
+		return hm.allowed(WorkerID(wid))(&testCaseTask{taskid: tid})
+	}
+
+	// each task is released to its first worker only
+	if !released("w1", "t1") || released("w2", "t1") || released("w3", "t1") {
+		t.Errorf("t1: expected released to w1 only")
+	}
+	if !released("w2", "t2") || released("w3", "t2") {
+		t.Errorf("t2: expected released to w2 only")
+	}
+	if next := hm.next(delay); !next.Equal(now.Add(delay)) {
+		t.Errorf("next: expected %v, found %v", now.Add(delay), next)
+	}
+
+	// the delay releases the task to the next worker
+	hm.expire(now.Add(delay/2), delay)
+	if released("w2", "t1") {
+		t.Errorf("t1: expected not released to w2 before the delay")
+	}
+	hm.expire(now.Add(delay), delay)
+	if !released("w2", "t1") || released("w3", "t1") {
+		t.Errorf("t1: expected released to w1 and w2 after the delay")
+	}
+
+	// the failure of all the released workers releases the task to the next worker
+	statusMap.doing("t2")
+	statusMap.done("t2", false)
+	hm.failed("t2", statusMap["t2"], now)
+	if !released("w3", "t2") {
+		t.Errorf("t2: expected released to w3 after the failure of w2")
+	}
+
+	// the success returns the workers the task was not released to
+	skipped := hm.succeeded("t1")
+	if diff := cmp.Diff([]WorkerID{"w3"}, skipped); diff != "" {
+		t.Errorf("succeeded: mismatch (-want +got):\n%s", diff)
+	}
+	if next := hm.next(delay); !next.IsZero() {
+		t.Errorf("next: expected zero time, found %v", next)
+	}
+
+	// nil hedgeMap allows any task
+	var hmnil hedgeMap
+	if hmnil.allowed("w1") != nil {
+		t.Errorf("nil hedgeMap: expected nil allowed function")
+	}
+}
+
+func TestPickFunc(t *testing.T) {
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}, {"t2", 10, true}, {"t3", 10, true}},
+	})
+	statusMap := newTaskStatusMap(tasks)
+	ts := tasks["w1"]
+
+	only := func(tids ...string) func(Task) bool {
+		return func(t Task) bool {
+			for _, tid := range tids {
+				if t.TaskID() == TaskID(tid) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	testCases := map[string]struct {
+		allowed  func(Task) bool
+		expected int
+	}{
+		"nil":     {nil, 0},
+		"t2 t3":   {only("t2", "t3"), 1},
+		"t3":      {only("t3"), 2},
+		"nothing": {only(), -1},
+	}
+
+	for title, tc := range testCases {
+		if n := statusMap.pickFunc(ts, tc.allowed, nil); n != tc.expected {
+			t.Errorf("%s: expected %d, found %d", title, tc.expected, n)
+		}
+	}
+}
+
+func TestExecuteHedged(t *testing.T) {
+
+	type testCase struct {
+		delay    time.Duration
+		input    map[string]testCaseTasks
+		expected testCaseResults
+		works    map[string]int // number of works done by each worker
+	}
+
+	testCases := map[string]testCase{
+		"preferred ok": {
+			delay: 100 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}, {"t2", 10, true}},
+				"w2": {{"t1", 10, true}, {"t2", 10, true}},
+				"w3": {{"t1", 10, true}},
+			},
+			expected: testCaseResults{
+				{"t1", "w1", true},
+				{"t2", "w1", true},
+			},
+			works: map[string]int{"w1": 2},
+		},
+		"preferred slow": {
+			delay: 20 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 300, true}},
+				"w2": {{"t1", 10, true}},
+				"w3": {{"t1", 10, true}},
+			},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+			},
+			works: map[string]int{"w1": 1, "w2": 1},
+		},
+		"preferred ko": {
+			delay: 0,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, false}},
+				"w2": {{"t1", 10, true}},
+				"w3": {{"t1", 10, true}},
+			},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+			},
+			works: map[string]int{"w1": 1, "w2": 1},
+		},
+		"all ko": {
+			delay: 0,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, false}},
+				"w2": {{"t1", 10, false}},
+				"w3": {{"t1", 10, false}},
+			},
+			expected: testCaseResults{
+				{"t1", "w3", false},
+			},
+			works: map[string]int{"w1": 1, "w2": 1, "w3": 1},
+		},
+		"task not of the preferred": {
+			delay: 100 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t2", 10, true}},
+				"w3": {{"t2", 10, true}, {"t3", 10, true}},
+			},
+			expected: testCaseResults{
+				{"t1", "w1", true},
+				{"t2", "w2", true},
+				{"t3", "w3", true},
+			},
+			works: map[string]int{"w1": 1, "w2": 1, "w3": 1},
+		},
+	}
+
+	ctx := context.Background()
+	copts := cmp.Options{
+		cmpopts.SortSlices(testCaseResultLess),
+	}
+
+	for title, tc := range testCases {
+		var mu sync.Mutex
+		works := map[string]int{}
+		countFn := func(ctx context.Context, workerInst int, task Task) Result {
+			mu.Lock()
+			works[task.(*testTask).workerid]++
+			mu.Unlock()
+			return workFn(ctx, workerInst, task)
+		}
+		workers := []*Worker{
+			{WorkerID: "w1", Instances: 1, Work: countFn},
+			{WorkerID: "w2", Instances: 1, Work: countFn},
+			{WorkerID: "w3", Instances: 1, Work: countFn},
+		}
+
+		tasks := newTestWorkeridTasks(t, tc.input)
+		out, err := ExecuteWithOptions(ctx, workers, tasks, &Options{Mode: Hedged, HedgeDelay: tc.delay})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		results := testCaseResults{}
+		for res := range out {
+			tres := res.(*testResult)
+			results = append(results, tres.ToTestCaseResult())
+		}
+
+		if diff := cmp.Diff(tc.expected, results, copts); diff != "" {
+			t.Errorf("%s: results mismatch (-want +got):\n%s", title, diff)
+		}
+		if diff := cmp.Diff(tc.works, works); diff != "" {
+			t.Errorf("%s: works mismatch (-want +got):\n%s", title, diff)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package hook defines the hooks of the engine reserved to the
+// taskenginetest package.
+package hook
+
+// Waiter is implemented by an Observer that is also notified when the engine
+// waits for the next output of the workers, or the expiry of its timer.
+// Between two calls, the engine handles exactly one of them.
+// It is used by the simulated clock of the taskenginetest package
+// to detect that the execution is blocked.
+type Waiter interface {
+	Waiting()
+}
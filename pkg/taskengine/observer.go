@@ -0,0 +1,45 @@
+package taskengine
+
+// Observer receives the events of an execution.
+// The methods are called while the engine is running: they must return
+// quickly, and they can be called concurrently by different goroutines.
+type Observer interface {
+	// TaskAssigned is called when the task is sent to the worker.
+	TaskAssigned(wid WorkerID, tid TaskID)
+
+	// TaskStarted is called when the instance of the worker starts the work,
+	// after the wait of the rate limit, if any.
+	TaskStarted(wid WorkerID, inst int, tid TaskID)
+
+	// TaskFinished is called when the result of the work is received.
+	// For the tasks skipped by the circuit breaker, inst is -1 and
+	// res is a *SkippedResult. For the tasks canceled before the work,
+	// res is a *CanceledResult.
+	TaskFinished(wid WorkerID, inst int, tid TaskID, res Result)
+
+	// TaskCanceled is called when the engine cancels the context
+	// of the task, after a success.
+	TaskCanceled(tid TaskID)
+
+	// TaskCompleted is called when no worker has to do or is doing the task.
+	// Success is true if at least one worker has done the task with success.
+	TaskCompleted(tid TaskID, success bool)
+
+	// WorkerIdle is called when the instance of the worker is ready,
+	// but no task can be sent to it now.
+	WorkerIdle(wid WorkerID, inst int)
+
+	// WorkerClosed is called when the worker has no more tasks to do.
+	WorkerClosed(wid WorkerID)
+}
+
+// nopObserver is the Observer that ignores all the events.
+type nopObserver struct{}
+
+func (nopObserver) TaskAssigned(WorkerID, TaskID)              {}
+func (nopObserver) TaskStarted(WorkerID, int, TaskID)          {}
+func (nopObserver) TaskFinished(WorkerID, int, TaskID, Result) {}
+func (nopObserver) TaskCanceled(TaskID)                        {}
+func (nopObserver) TaskCompleted(TaskID, bool)                 {}
+func (nopObserver) WorkerIdle(WorkerID, int)                   {}
+func (nopObserver) WorkerClosed(WorkerID)                      {}
@@ -0,0 +1,118 @@
+package taskengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testObserver records the events of an execution.
+type testObserver struct {
+	mu     sync.Mutex
+	events map[string]int // event -> count
+}
+
+func (o *testObserver) add(format string, a ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.events == nil {
+		o.events = map[string]int{}
+	}
+	o.events[fmt.Sprintf(format, a...)]++
+}
+
+func (o *testObserver) TaskAssigned(wid WorkerID, tid TaskID) {
+	o.add("assigned %s %s", wid, tid)
+}
+func (o *testObserver) TaskStarted(wid WorkerID, inst int, tid TaskID) {
+	o.add("started %s %s", wid, tid)
+}
+func (o *testObserver) TaskFinished(wid WorkerID, inst int, tid TaskID, res Result) {
+	o.add("finished %s %s %v", wid, tid, res.Success())
+}
+func (o *testObserver) TaskCanceled(tid TaskID) {
+	o.add("canceled %s", tid)
+}
+func (o *testObserver) TaskCompleted(tid TaskID, success bool) {
+	o.add("completed %s %v", tid, success)
+}
+func (o *testObserver) WorkerIdle(wid WorkerID, inst int) {
+	o.add("idle %s", wid)
+}
+func (o *testObserver) WorkerClosed(wid WorkerID) {
+	o.add("closed %s", wid)
+}
+
+func TestExecuteObserver(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+
+	testCases := map[string]struct {
+		opts     *Options
+		input    map[string]testCaseTasks
+		expected map[string]int
+	}{
+		"first success": {
+			opts: &Options{},
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}, {"t2", 10, false}},
+				"w2": {{"t1", 100, true}},
+			},
+			expected: map[string]int{
+				"assigned w1 t1":       1,
+				"assigned w1 t2":       1,
+				"assigned w2 t1":       1,
+				"started w1 t1":        1,
+				"started w1 t2":        1,
+				"started w2 t1":        1,
+				"finished w1 t1 true":  1,
+				"finished w1 t2 false": 1,
+				"finished w2 t1 false": 1, // canceled
+				"canceled t1":          1,
+				"completed t1 true":    1,
+				"completed t2 false":   1,
+				"closed w1":            1,
+				"closed w2":            1,
+			},
+		},
+		"hedged": {
+			opts: &Options{Mode: Hedged, HedgeDelay: 100 * time.Millisecond},
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t1", 10, true}},
+			},
+			expected: map[string]int{
+				"assigned w1 t1":      1,
+				"started w1 t1":       1,
+				"finished w1 t1 true": 1,
+				"canceled t1":         1,
+				"completed t1 true":   1,
+				"idle w2":             1,
+				"closed w1":           1,
+				"closed w2":           1,
+			},
+		},
+	}
+
+	for title, tc := range testCases {
+		obs := &testObserver{}
+		tc.opts.Observer = obs
+
+		out, err := ExecuteWithOptions(context.Background(), workers, newTestWorkeridTasks(t, tc.input), tc.opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for range out {
+		}
+
+		if diff := cmp.Diff(tc.expected, obs.events); diff != "" {
+			t.Errorf("%s: events mismatch (-want +got):\n%s", title, diff)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned by the Submit method of a Pool after Shutdown.
+var ErrPoolClosed = errors.New("pool closed")
+
+// Pool runs the tasks submitted one at a time on a shared set of workers:
+// each Submit is an independent execution of a single task by one or more
+// workers, in the mode of the options, with its own context and results.
+// The instances of the workers are shared by all the tasks,
+// so that each worker never runs more than Instances works at the same time.
+// The circuit breaker of the options, if any, is shared too.
+type Pool struct {
+	workers []*Worker
+	opts    Options
+	slots   map[WorkerID]chan int
+
+	// ctx is canceled when the shutdown stops the in-flight works
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup // in-flight tasks
+}
+
+// Future is the pending outcome of a task submitted to a Pool.
+type Future struct {
+	done    chan struct{}
+	results []Result
+}
+
+// Done returns a chan that is closed when the task is completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Results waits for the completion of the task and returns its results
+// in order of arrival: only one result, unless in UntilFirstSuccess or All mode.
+func (f *Future) Results() []Result {
+	<-f.done
+	return f.results
+}
+
+// NewPool returns a new Pool with the given workers and options.
+// The Stats option is not used: the tasks are executed concurrently.
+func NewPool(workers []*Worker, opts *Options) (*Pool, error) {
+	// check the workers
+	if _, err := newEngine(context.Background(), workers, nil); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	slots := map[WorkerID]chan int{}
+	for _, w := range workers {
+		ch := make(chan int, w.Instances)
+		for i := 0; i < w.Instances; i++ {
+			ch <- i
+		}
+		slots[w.WorkerID] = ch
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		workers: workers,
+		opts:    *opts,
+		slots:   slots,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	p.opts.Stats = nil
+	return p, nil
+}
+
+// Submit starts the execution of a task by the given workers,
+// and returns the Future of its results. Each worker can receive
+// a different Task object, but all of them must have the same TaskID.
+// The task is canceled when the context is done.
+// It returns ErrPoolClosed after Shutdown.
+func (p *Pool) Submit(ctx context.Context, tasks map[WorkerID]Task) (*Future, error) {
+	if ctx == nil {
+		return nil, errorf("nil context")
+	}
+	if len(tasks) == 0 {
+		return nil, errorf("no tasks")
+	}
+
+	// the workers of the task, in the order of the pool.
+	// Each of them does the task once: one instance is enough,
+	// the instances of the pool are the slots.
+	var tid TaskID
+	ws := make([]*Worker, 0, len(tasks))
+	wts := WorkerTasks{}
+	for _, w := range p.workers {
+		t, ok := tasks[w.WorkerID]
+		if !ok {
+			continue
+		}
+		if len(ws) == 0 {
+			tid = t.TaskID()
+		} else if t.TaskID() != tid {
+			return nil, errorf("tasks with different TaskID: %q and %q", tid, t.TaskID())
+		}
+		w1 := *w
+		w1.Instances = 1
+		ws = append(ws, &w1)
+		wts[w.WorkerID] = Tasks{t}
+	}
+	if len(ws) < len(tasks) {
+		for wid := range tasks {
+			if _, ok := wts[wid]; !ok {
+				return nil, errorf("tasks for undefined worker: WorkerID=%q", wid)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	tctx, cancel := context.WithCancel(ctx)
+	eng, err := newEngine(tctx, ws, wts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	eng.slots = p.slots
+
+	opts := p.opts
+	resultc, err := eng.execute(&opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	f := &Future{done: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer cancel()
+
+		// the task is also canceled by the shutdown
+		stop := p.ctx.Done()
+		for {
+			select {
+			case res, ok := <-resultc:
+				if !ok {
+					close(f.done)
+					return
+				}
+				f.results = append(f.results, res)
+			case <-stop:
+				cancel()
+				stop = nil
+			}
+		}
+	}()
+	return f, nil
+}
+
+// Shutdown stops accepting new tasks and waits for the in-flight ones
+// to complete. If the context is done before, the in-flight works
+// are canceled and the context error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,268 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// newTestPoolTasks creates the tasks of a submission from a map workerId -> testCaseTask
+func newTestPoolTasks(t *testing.T, tcts map[string]testCaseTask) map[WorkerID]Task {
+	tasks := map[WorkerID]Task{}
+	for wid, tct := range tcts {
+		tt := &testTask{tct, wid, t, 0}
+		if tct.msec <= 0 {
+			tt.msec = 10
+		}
+		tasks[WorkerID(wid)] = tt
+	}
+	return tasks
+}
+
+func TestNewPoolErrors(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 0, Work: workFn},
+	}
+	errmsg := "instances must be in 1..100 range: WorkerID=\"w1\""
+	_, err := NewPool(workers, nil)
+	if err == nil {
+		t.Errorf("expected error %q, found no error", errmsg)
+	} else if err.Error() != errmsg {
+		t.Errorf("expected error %q, found error %q", errmsg, err)
+	}
+}
+
+func TestPoolSubmitErrors(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+	pool, err := NewPool(workers, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer pool.Shutdown(context.Background())
+
+	cases := map[string]struct {
+		tasks  map[string]testCaseTask
+		errmsg string
+	}{
+		"no tasks": {
+			tasks:  map[string]testCaseTask{},
+			errmsg: "no tasks",
+		},
+		"undefined worker": {
+			tasks:  map[string]testCaseTask{"w1": {"t1", 10, true}, "w3": {"t1", 10, true}},
+			errmsg: "tasks for undefined worker: WorkerID=\"w3\"",
+		},
+		"different tasks": {
+			tasks:  map[string]testCaseTask{"w1": {"t1", 10, true}, "w2": {"t2", 10, true}},
+			errmsg: "tasks with different TaskID: \"t1\" and \"t2\"",
+		},
+	}
+	for title, c := range cases {
+		_, err := pool.Submit(context.Background(), newTestPoolTasks(t, c.tasks))
+		if err == nil {
+			t.Errorf("%s: expected error %q, found no error", title, c.errmsg)
+		} else if err.Error() != c.errmsg {
+			t.Errorf("%s: expected error %q, found error %q", title, c.errmsg, err)
+		}
+	}
+}
+
+func TestPoolSubmit(t *testing.T) {
+	// count the works running at the same time
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	insts := map[int]bool{}
+	countFn := func(ctx context.Context, workerInst int, task Task) Result {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		insts[workerInst] = true
+		mu.Unlock()
+
+		res := workFn(ctx, workerInst, task)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return res
+	}
+
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 2, Work: countFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+	pool, err := NewPool(workers, &Options{Mode: All})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	inputs := []map[string]testCaseTask{
+		{"w1": {"t1", 20, true}},
+		{"w1": {"t2", 20, false}, "w2": {"t2", 20, false}},
+		{"w1": {"t3", 20, true}},
+		{"w1": {"t4", 20, true}},
+	}
+	expected := []testCaseResults{
+		{{"t1", "w1", true}},
+		{{"t2", "w1", false}, {"t2", "w2", false}},
+		{{"t3", "w1", true}},
+		{{"t4", "w1", true}},
+	}
+
+	// the tasks are executed concurrently
+	futures := []*Future{}
+	for _, input := range inputs {
+		f, err := pool.Submit(context.Background(), newTestPoolTasks(t, input))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		futures = append(futures, f)
+	}
+
+	copts := cmp.Options{
+		cmpopts.SortSlices(testCaseResultLess),
+	}
+	for j, f := range futures {
+		results := testCaseResults{}
+		for _, res := range f.Results() {
+			results = append(results, res.(*testResult).ToTestCaseResult())
+		}
+		if diff := cmp.Diff(expected[j], results, copts); diff != "" {
+			t.Errorf("task %d: mismatch (-want +got):\n%s", j, diff)
+		}
+	}
+
+	// the instances are shared by the tasks
+	if maxRunning != 2 {
+		t.Errorf("expected at most 2 works at the same time, found %d", maxRunning)
+	}
+	if diff := cmp.Diff(map[int]bool{0: true, 1: true}, insts); diff != "" {
+		t.Errorf("instances mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: unexpected error %v", err)
+	}
+}
+
+func TestPoolSubmitContext(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+	}
+	pool, err := NewPool(workers, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer pool.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// the first task takes the only instance,
+	// the second one is canceled while waiting for it
+	f1, err := pool.Submit(ctx, newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t1", 500, true},
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+	f2, err := pool.Submit(ctx, newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t2", 10, true},
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, res := range f1.Results() {
+		if err := res.(*testResult).err; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error %v, found %v", context.DeadlineExceeded, err)
+		}
+	}
+	for _, res := range f2.Results() {
+		cr, ok := res.(*CanceledResult)
+		if !ok {
+			t.Errorf("expected a canceled result, found %v", res)
+		} else if cr.Err != context.DeadlineExceeded {
+			t.Errorf("expected error %v, found %v", context.DeadlineExceeded, cr.Err)
+		}
+	}
+
+	// the other tasks are not affected
+	f, err := pool.Submit(context.Background(), newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t1", 10, true},
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, res := range f.Results() {
+		if !res.Success() {
+			t.Errorf("expected success, found %v", res.(*testResult).err)
+		}
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+	}
+
+	// drain the in-flight works
+	pool, err := NewPool(workers, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	f, err := pool.Submit(context.Background(), newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t1", 50, true},
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: unexpected error %v", err)
+	}
+	select {
+	case <-f.Done():
+	default:
+		t.Errorf("expected the completion of the in-flight task")
+	}
+	if results := f.Results(); len(results) != 1 || !results[0].Success() {
+		t.Errorf("expected the success of the in-flight task, found %v", results)
+	}
+	_, err = pool.Submit(context.Background(), newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t2", 10, true},
+	}))
+	if err != ErrPoolClosed {
+		t.Errorf("submit after shutdown: expected error %v, found %v", ErrPoolClosed, err)
+	}
+
+	// cancel the in-flight works
+	pool, err = NewPool(workers, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	f, err = pool.Submit(context.Background(), newTestPoolTasks(t, map[string]testCaseTask{
+		"w1": {"t1", 500, true},
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("shutdown: expected error %v, found %v", context.DeadlineExceeded, err)
+	}
+	if results := f.Results(); len(results) != 1 || !errors.Is(results[0].(*testResult).err, context.Canceled) {
+		t.Errorf("expected the cancellation of the in-flight task, found %v", results)
+	}
+}
@@ -0,0 +1,110 @@
+package taskengine
+
+import (
+	"sort"
+	"time"
+)
+
+// PriorityTask is a Task with a priority.
+// Since the task object assigned to a worker can be specific to that worker,
+// the priority is the preference of the worker for the task.
+// Given equal opportunity, a task is assigned first to the workers
+// with higher priority.
+// The priority of a Task not implementing PriorityTask is zero.
+type PriorityTask interface {
+	Task
+	Priority() int
+}
+
+// taskPriority returns the priority of the task, or zero.
+func taskPriority(t Task) int {
+	if pt, ok := t.(PriorityTask); ok {
+		return pt.Priority()
+	}
+	return 0
+}
+
+// priorityMap maps TaskID -> WorkerID -> priority of the task for the worker.
+type priorityMap map[TaskID]map[WorkerID]int
+
+// newPriorityMap init a new priorityMap from WorkerTasks.
+func newPriorityMap(widtasks WorkerTasks) priorityMap {
+	pm := priorityMap{}
+	for wid, ts := range widtasks {
+		for _, t := range ts {
+			tid := t.TaskID()
+			m := pm[tid]
+			if m == nil {
+				m = map[WorkerID]int{}
+				pm[tid] = m
+			}
+			m[wid] = taskPriority(t)
+		}
+	}
+	return pm
+}
+
+// rank returns a function that returns, for each task,
+// the number of workers with higher priority than the given worker.
+func (pm priorityMap) rank(wid WorkerID) func(Task) int {
+	return func(t Task) int {
+		m := pm[t.TaskID()]
+		p := m[wid]
+		n := 0
+		for _, q := range m {
+			if q > p {
+				n++
+			}
+		}
+		return n
+	}
+}
+
+// sortWorkers sorts the workers of the task by decreasing priority.
+// Workers with the same priority keep their order.
+func (pm priorityMap) sortWorkers(tid TaskID, wids []WorkerID) {
+	m := pm[tid]
+	sort.SliceStable(wids, func(i, j int) bool {
+		return m[wids[i]] > m[wids[j]]
+	})
+}
+
+// runningMap maps TaskID -> WorkerID -> number of instances
+// of the worker that are doing the task.
+type runningMap map[TaskID]map[WorkerID]int
+
+func (rm runningMap) doing(tid TaskID, wid WorkerID) {
+	m := rm[tid]
+	if m == nil {
+		m = map[WorkerID]int{}
+		rm[tid] = m
+	}
+	m[wid]++
+}
+
+func (rm runningMap) done(tid TaskID, wid WorkerID) {
+	m := rm[tid]
+	m[wid]--
+	if m[wid] == 0 {
+		delete(m, wid)
+	}
+}
+
+// higher reports if a worker with priority higher than p is doing the task.
+func (rm runningMap) higher(pm priorityMap, tid TaskID, p int) bool {
+	for wid := range rm[tid] {
+		if pm[tid][wid] > p {
+			return true
+		}
+	}
+	return false
+}
+
+// heldResult is a success result not yet returned,
+// waiting for the result of a worker with higher priority.
+type heldResult struct {
+	res      Result
+	wid      WorkerID
+	priority int
+	deadline time.Time
+}
@@ -0,0 +1,199 @@
+package taskengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// testPriorityTask is a testTask with a priority.
+type testPriorityTask struct {
+	*testTask
+	priority int
+}
+
+func (t *testPriorityTask) Priority() int { return t.priority }
+
+// withPriorities returns the tasks with the priorities of the map
+// workerId -> taskId -> priority. Missing tasks have zero priority.
+func withPriorities(wts WorkerTasks, prios map[string]map[string]int) WorkerTasks {
+	wts2 := WorkerTasks{}
+	for wid, ts := range wts {
+		ts2 := Tasks{}
+		for _, t := range ts {
+			tt := t.(*testTask)
+			ts2 = append(ts2, &testPriorityTask{tt, prios[string(wid)][tt.taskid]})
+		}
+		wts2[wid] = ts2
+	}
+	return wts2
+}
+
+func workPriorityFn(ctx context.Context, workerInst int, task Task) Result {
+	return workFn(ctx, workerInst, task.(*testPriorityTask).testTask)
+}
+
+func TestPriorityMap(t *testing.T) {
+	tasks := withPriorities(newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}, {"t2", 10, true}},
+		"w2": {{"t1", 10, true}, {"t2", 10, true}},
+		"w3": {{"t1", 10, true}},
+	}), map[string]map[string]int{
+		"w1": {"t2": 1},
+		"w2": {"t1": 2, "t2": 1},
+		"w3": {"t1": 1},
+	})
+
+	pm := newPriorityMap(tasks)
+
+	rank := map[string]map[string]int{
+		"w1": {"t1": 2, "t2": 0},
+		"w2": {"t1": 0, "t2": 0},
+		"w3": {"t1": 1},
+	}
+	for wid, m := range rank {
+		fn := pm.rank(WorkerID(wid))
+		for tid, want := range m {
+			if got := fn(&testCaseTask{taskid: tid}); got != want {
+				t.Errorf("rank(%s, %s): expected %d, found %d", wid, tid, want, got)
+			}
+		}
+	}
+
+	wids := []WorkerID{"w1", "w2", "w3"}
+	pm.sortWorkers("t1", wids)
+	if diff := cmp.Diff([]WorkerID{"w2", "w3", "w1"}, wids); diff != "" {
+		t.Errorf("sortWorkers: mismatch (-want +got):\n%s", diff)
+	}
+
+	// the worker w1 prefers the task t2, even if t1 has lower TaskID
+	statusMap := newTaskStatusMap(tasks)
+	if n := statusMap.pickFunc(tasks["w1"], nil, pm.rank("w1")); n != 1 {
+		t.Errorf("pickFunc: expected 1, found %d", n)
+	}
+}
+
+func TestExecutePriority(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workPriorityFn},
+		{WorkerID: "w2", Instances: 1, Work: workPriorityFn},
+		{WorkerID: "w3", Instances: 1, Work: workPriorityFn},
+	}
+
+	type testCase struct {
+		mode     Mode
+		window   time.Duration
+		input    map[string]testCaseTasks
+		prios    map[string]map[string]int
+		expected testCaseResults
+	}
+
+	testCases := map[string]testCase{
+		"no window": {
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 50, true}},
+				"w2": {{"t1", 10, true}},
+			},
+			prios: map[string]map[string]int{"w1": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+			},
+		},
+		"preferred within window": {
+			window: 200 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 50, true}},
+				"w2": {{"t1", 10, true}},
+			},
+			prios: map[string]map[string]int{"w1": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w1", true},
+			},
+		},
+		"preferred after window": {
+			window: 30 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 300, true}},
+				"w2": {{"t1", 10, true}},
+			},
+			prios: map[string]map[string]int{"w1": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+			},
+		},
+		"preferred ko": {
+			window: 200 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 50, false}},
+				"w2": {{"t1", 10, true}},
+			},
+			prios: map[string]map[string]int{"w1": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+			},
+		},
+		"best of three": {
+			window: 200 * time.Millisecond,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t1", 30, true}},
+				"w3": {{"t1", 50, true}},
+			},
+			prios: map[string]map[string]int{"w2": {"t1": 1}, "w3": {"t1": 2}},
+			expected: testCaseResults{
+				{"t1", "w3", true},
+			},
+		},
+		"pick preferred task": {
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}, {"t2", 10, true}},
+				"w2": {{"t1", 10, true}, {"t2", 10, true}},
+			},
+			prios: map[string]map[string]int{"w1": {"t2": 1}, "w2": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w2", true},
+				{"t2", "w1", true},
+			},
+		},
+		"hedged": {
+			mode: Hedged,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t1", 10, true}},
+				"w3": {{"t1", 10, true}},
+			},
+			prios: map[string]map[string]int{"w3": {"t1": 1}},
+			expected: testCaseResults{
+				{"t1", "w3", true},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	copts := cmp.Options{
+		cmpopts.SortSlices(testCaseResultLess),
+	}
+
+	for title, tc := range testCases {
+		tasks := withPriorities(newTestWorkeridTasks(t, tc.input), tc.prios)
+		opts := &Options{Mode: tc.mode, PriorityWindow: tc.window}
+
+		out, err := ExecuteWithOptions(ctx, workers, tasks, opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		results := testCaseResults{}
+		for res := range out {
+			tres := res.(*testResult)
+			results = append(results, tres.ToTestCaseResult())
+		}
+
+		if diff := cmp.Diff(tc.expected, results, copts); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", title, diff)
+		}
+	}
+}
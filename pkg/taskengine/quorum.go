@@ -0,0 +1,68 @@
+package taskengine
+
+// QuorumResult is the Result of a task in Quorum mode
+// when the Reconcile option is not defined.
+// It contains the results of the workers in order of arrival.
+type QuorumResult struct {
+	TaskID  TaskID
+	Results []Result
+}
+
+// Success returns true if at least one of the results is a success.
+func (r *QuorumResult) Success() bool {
+	for _, res := range r.Results {
+		if res.Success() {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns Success if at least one of the results is a success,
+// else the status of the last result, or Skipped if there are no results.
+func (r *QuorumResult) Status() StatusType {
+	if len(r.Results) == 0 {
+		return Skipped
+	}
+	if r.Success() {
+		return Success
+	}
+	return StatusOf(r.Results[len(r.Results)-1])
+}
+
+// quorumMap contains the outputs collected for each task in Quorum mode.
+// A nil list means that the result of the task has already been returned.
+type quorumMap map[TaskID][]*jobOutput
+
+// add collects the output of the task, unless the result of the task
+// has already been returned. It returns true if the result of the task
+// has to be returned: the quorum of successes is reached,
+// or the task is completed.
+func (qm quorumMap) add(tid TaskID, o *jobOutput, status *taskStat, quorum int) bool {
+	outs, ok := qm[tid]
+	if ok && outs == nil {
+		return false
+	}
+	qm[tid] = append(outs, o)
+	return (quorum > 0 && status.success >= quorum) || status.completed()
+}
+
+// take returns the outputs collected for the task,
+// marking its result as returned.
+func (qm quorumMap) take(tid TaskID) []*jobOutput {
+	outs := qm[tid]
+	qm[tid] = nil
+	return outs
+}
+
+// reconcile returns the result of the task from the collected outputs.
+func reconcile(tid TaskID, outs []*jobOutput, fn func(TaskID, []Result) Result) Result {
+	results := make([]Result, 0, len(outs))
+	for _, o := range outs {
+		results = append(results, o.res)
+	}
+	if fn == nil {
+		return &QuorumResult{TaskID: tid, Results: results}
+	}
+	return fn(tid, results)
+}
@@ -0,0 +1,127 @@
+package taskengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestExecuteQuorum(t *testing.T) {
+
+	type testCase struct {
+		quorum   int
+		input    map[string]testCaseTasks
+		expected map[string]testCaseResults // results of each task, in order of arrival
+	}
+
+	testCases := map[string]testCase{
+		"all workers": {
+			quorum: 0,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}, {"t2", 10, false}},
+				"w2": {{"t1", 30, true}},
+				"w3": {{"t1", 50, false}, {"t2", 50, true}},
+			},
+			expected: map[string]testCaseResults{
+				"t1": {{"t1", "w1", true}, {"t1", "w2", true}, {"t1", "w3", false}},
+				"t2": {{"t2", "w1", false}, {"t2", "w3", true}},
+			},
+		},
+		"quorum reached": {
+			quorum: 2,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t1", 30, false}},
+				"w3": {{"t1", 50, true}},
+				"w4": {{"t1", 300, true}},
+			},
+			expected: map[string]testCaseResults{
+				"t1": {{"t1", "w1", true}, {"t1", "w2", false}, {"t1", "w3", true}},
+			},
+		},
+		"quorum not reached": {
+			quorum: 3,
+			input: map[string]testCaseTasks{
+				"w1": {{"t1", 10, true}},
+				"w2": {{"t1", 30, false}},
+			},
+			expected: map[string]testCaseResults{
+				"t1": {{"t1", "w1", true}, {"t1", "w2", false}},
+			},
+		},
+	}
+
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+		{WorkerID: "w3", Instances: 1, Work: workFn},
+		{WorkerID: "w4", Instances: 1, Work: workFn},
+	}
+	ctx := context.Background()
+
+	for title, tc := range testCases {
+		tasks := newTestWorkeridTasks(t, tc.input)
+		out, err := ExecuteWithOptions(ctx, workers, tasks, &Options{Mode: Quorum, Quorum: tc.quorum})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		got := map[string]testCaseResults{}
+		for res := range out {
+			qr, ok := res.(*QuorumResult)
+			if !ok {
+				t.Fatalf("%s: expected *QuorumResult, found %T", title, res)
+			}
+			results := testCaseResults{}
+			for _, r := range qr.Results {
+				results = append(results, r.(*testResult).ToTestCaseResult())
+			}
+			if _, ok := got[string(qr.TaskID)]; ok {
+				t.Errorf("%s: task %s returned twice", title, qr.TaskID)
+			}
+			got[string(qr.TaskID)] = results
+		}
+
+		if diff := cmp.Diff(tc.expected, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", title, diff)
+		}
+	}
+}
+
+func TestExecuteQuorumReconcile(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}, {"t2", 10, false}},
+		"w2": {{"t1", 50, true}, {"t2", 50, false}},
+	})
+
+	// returns the last result
+	reconcile := func(tid TaskID, results []Result) Result {
+		return results[len(results)-1]
+	}
+
+	out, err := ExecuteWithOptions(context.Background(), workers, tasks, &Options{Mode: Quorum, Reconcile: reconcile})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	results := testCaseResults{}
+	for res := range out {
+		results = append(results, res.(*testResult).ToTestCaseResult())
+	}
+
+	expected := testCaseResults{
+		{"t1", "w2", true},
+		{"t2", "w2", false},
+	}
+	copts := cmp.Options{
+		cmpopts.SortSlices(testCaseResultLess),
+	}
+	if diff := cmp.Diff(expected, results, copts); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
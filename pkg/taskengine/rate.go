@@ -0,0 +1,138 @@
+package taskengine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate limit of the works of a worker.
+//
+// The works are limited to Requests in each Interval, with bursts of at most
+// Burst works, and each work starts at least Spacing after the previous one.
+// Requests and Spacing can be used alone or together.
+type RateLimit struct {
+	// Requests is the number of works allowed in each Interval.
+	// If zero, only the spacing is enforced.
+	Requests int
+
+	// Interval is the period of time of Requests works.
+	Interval time.Duration
+
+	// Burst is the maximum number of works that can start together.
+	// If zero, a single work is allowed.
+	Burst int
+
+	// Spacing is the minimum time between the start of two works.
+	Spacing time.Duration
+}
+
+// check returns an error if the rate limit is not valid.
+func (rl *RateLimit) check() error {
+	if rl.Requests < 0 || rl.Burst < 0 || rl.Interval < 0 || rl.Spacing < 0 {
+		return errorf("invalid rate limit: negative value")
+	}
+	if rl.Requests > 0 && rl.Interval == 0 {
+		return errorf("invalid rate limit: interval not defined")
+	}
+	if rl.Requests == 0 && rl.Spacing == 0 {
+		return errorf("invalid rate limit: neither requests nor spacing defined")
+	}
+	return nil
+}
+
+// RateLimiter enforces a RateLimit.
+// Each work waits its turn before starting: the waiting instance
+// does not take another task in the meantime.
+// If the context of the task is done while waiting,
+// the task is canceled without starting the work.
+// A RateLimiter is safe for concurrent use, and it can be shared by
+// the workers of different executions, so that they share the same budget.
+type RateLimiter struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64   // available works at time last
+	last   time.Time // start time of the last reserved work
+}
+
+// NewRateLimiter returns a new RateLimiter enforcing the given rate limit.
+func NewRateLimiter(rl RateLimit) (*RateLimiter, error) {
+	if err := rl.check(); err != nil {
+		return nil, err
+	}
+	return &RateLimiter{limit: rl}, nil
+}
+
+// Limit returns the rate limit enforced by the limiter.
+func (l *RateLimiter) Limit() RateLimit {
+	return l.limit
+}
+
+// reserve reserves the start time of the next work, not before now.
+// The works start in the order of reservation.
+func (l *RateLimiter) reserve(now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now
+	if !l.last.IsZero() {
+		if l.last.After(start) {
+			start = l.last
+		}
+		if t := l.last.Add(l.limit.Spacing); t.After(start) {
+			start = t
+		}
+	}
+
+	if l.limit.Requests > 0 {
+		// token bucket refilled with Requests tokens each Interval
+		burst := float64(l.limit.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		perSec := float64(l.limit.Requests) / l.limit.Interval.Seconds()
+
+		tokens := burst
+		if !l.last.IsZero() {
+			tokens = l.tokens + start.Sub(l.last).Seconds()*perSec
+			if tokens > burst {
+				tokens = burst
+			}
+		}
+		if tokens < 1 {
+			start = start.Add(time.Duration((1 - tokens) / perSec * float64(time.Second)))
+			tokens = 1
+		}
+		l.tokens = tokens - 1
+	}
+
+	l.last = start
+	return start
+}
+
+// Wait blocks until the next work can start, or the context is done.
+// In the latter case it returns the context error.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.wait(ctx, systemClock{})
+}
+
+// wait is the Wait method using the given clock.
+func (l *RateLimiter) wait(ctx context.Context, clock Clock) error {
+	if l == nil {
+		return nil
+	}
+	now := clock.Now()
+	d := l.reserve(now).Sub(now)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
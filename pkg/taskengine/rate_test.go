@@ -0,0 +1,181 @@
+package taskengine
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiterErrors(t *testing.T) {
+	testCases := map[string]struct {
+		rl     RateLimit
+		errmsg string
+	}{
+		"empty": {
+			rl:     RateLimit{},
+			errmsg: "invalid rate limit: neither requests nor spacing defined",
+		},
+		"no interval": {
+			rl:     RateLimit{Requests: 1},
+			errmsg: "invalid rate limit: interval not defined",
+		},
+		"negative": {
+			rl:     RateLimit{Requests: 1, Interval: time.Second, Burst: -1},
+			errmsg: "invalid rate limit: negative value",
+		},
+	}
+
+	for title, tc := range testCases {
+		_, err := NewRateLimiter(tc.rl)
+		if err == nil {
+			t.Errorf("%s: expected error %q, found no error", title, tc.errmsg)
+		} else if err.Error() != tc.errmsg {
+			t.Errorf("%s: expected error %q, found error %q", title, tc.errmsg, err)
+		}
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	ms := time.Millisecond
+
+	testCases := map[string]struct {
+		rl       RateLimit
+		at       []time.Duration // reservation times
+		expected []time.Duration // start times
+	}{
+		"requests": {
+			rl:       RateLimit{Requests: 2, Interval: time.Second},
+			at:       []time.Duration{0, 0, 0, 0},
+			expected: []time.Duration{0, 500 * ms, 1000 * ms, 1500 * ms},
+		},
+		"requests with burst": {
+			rl:       RateLimit{Requests: 2, Interval: time.Second, Burst: 2},
+			at:       []time.Duration{0, 0, 0, 0},
+			expected: []time.Duration{0, 0, 500 * ms, 1000 * ms},
+		},
+		"burst refill": {
+			rl:       RateLimit{Requests: 2, Interval: time.Second, Burst: 2},
+			at:       []time.Duration{0, 0, 2000 * ms, 2000 * ms, 2000 * ms},
+			expected: []time.Duration{0, 0, 2000 * ms, 2000 * ms, 2500 * ms},
+		},
+		"spacing": {
+			rl:       RateLimit{Spacing: 100 * ms},
+			at:       []time.Duration{0, 0, 50 * ms, 500 * ms},
+			expected: []time.Duration{0, 100 * ms, 200 * ms, 500 * ms},
+		},
+		"requests with burst and spacing": {
+			rl:       RateLimit{Requests: 2, Interval: time.Second, Burst: 2, Spacing: 100 * ms},
+			at:       []time.Duration{0, 0, 0},
+			expected: []time.Duration{0, 100 * ms, 500 * ms},
+		},
+	}
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for title, tc := range testCases {
+		l, err := NewRateLimiter(tc.rl)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for j, at := range tc.at {
+			start := l.reserve(t0.Add(at)).Sub(t0)
+			if start != tc.expected[j] {
+				t.Errorf("%s: reservation %d: expected %v, found %v", title, j, tc.expected[j], start)
+			}
+		}
+	}
+}
+
+func TestExecuteRate(t *testing.T) {
+	spacing := 30 * time.Millisecond
+
+	var mu sync.Mutex
+	starts := []time.Time{}
+	startFn := func(ctx context.Context, workerInst int, task Task) Result {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return workFn(ctx, workerInst, task)
+	}
+
+	rate, err := NewRateLimiter(RateLimit{Spacing: spacing})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 3, Work: startFn, Rate: rate},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 100, true}, {"t2", 100, true}, {"t3", 100, true}, {"t4", 100, true}},
+	})
+
+	out, err := Execute(context.Background(), workers, tasks, FirstSuccessOrLastError)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 4 {
+		t.Errorf("expected 4 results, found %d", n)
+	}
+
+	// the instances share the same spacing
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for j := 1; j < len(starts); j++ {
+		// allow a small tolerance of the timer
+		min := time.Duration(j)*spacing - 2*time.Millisecond
+		if d := starts[j].Sub(starts[0]); d < min {
+			t.Errorf("start %d: expected at least %v after the first, found %v", j, min, d)
+		}
+	}
+}
+
+func TestExecuteRateCanceled(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	countFn := func(ctx context.Context, workerInst int, task Task) Result {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return workFn(ctx, workerInst, task)
+	}
+
+	// the second work waits its turn after the deadline
+	rate, err := NewRateLimiter(RateLimit{Spacing: time.Second})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 2, Work: countFn, Rate: rate},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}, {"t2", 10, true}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out, err := Execute(ctx, workers, tasks, FirstSuccessOrLastError)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	canceled := 0
+	for res := range out {
+		if cr, ok := res.(*CanceledResult); ok {
+			canceled++
+			if cr.Err != context.DeadlineExceeded {
+				t.Errorf("expected error %v, found %v", context.DeadlineExceeded, cr.Err)
+			}
+		}
+	}
+	if canceled != 1 {
+		t.Errorf("expected 1 canceled result, found %d", canceled)
+	}
+
+	// the work function of the canceled task is not called
+	if calls != 1 {
+		t.Errorf("expected 1 call of the work function, found %d", calls)
+	}
+}
@@ -83,35 +83,54 @@ func (statmap taskStatMap) done(tid TaskID, success bool) {
 // It doesn't updates the neither the Tasks nor the taskInfoMap.
 // NOTE: it doesn't check task exists.
 func (statmap taskStatMap) pick(ts Tasks) int {
-	L := len(ts)
-	if L == 0 {
-		return -1
-	}
-
-	j0 := 0
-	s0 := statmap[ts[0].TaskID()]
-
-	for j := 1; j < L; j++ {
-		s := statmap[ts[j].TaskID()]
+	return statmap.pickFunc(ts, nil, nil)
+}
 
-		if s.success > s0.success {
-			// prefer task with fewer success
+// pickFunc is like pick, but only the tasks satisfying allowed are considered.
+// A nil allowed function allows any task.
+// If rank is not nil, among the tasks with equal success and doing numbers
+// it prefers the task with lower rank.
+// It returns -1 if no task is allowed.
+func (statmap taskStatMap) pickFunc(ts Tasks, allowed func(Task) bool, rank func(Task) int) int {
+	j0 := -1
+	var s0 *taskStat
+	var r0 int
+
+	for j, t := range ts {
+		if allowed != nil && !allowed(t) {
 			continue
-		} else if s.success == s0.success {
-			if s.doing > s0.doing {
-				// else prefer task with fewer doing
+		}
+		s := statmap[t.TaskID()]
+		r := 0
+		if rank != nil {
+			r = rank(t)
+		}
+
+		if j0 >= 0 {
+			if s.success > s0.success {
+				// prefer task with fewer success
 				continue
-			} else if s.doing == s0.doing {
-				if s.todo > s0.todo {
-					// else prefer task with fewer todo
+			} else if s.success == s0.success {
+				if s.doing > s0.doing {
+					// else prefer task with fewer doing
 					continue
-				} else if s.todo == s0.todo {
-					// else prefer task with lower TaskID
-					// needed to be deterministic
-					tid0 := ts[j0].TaskID()
-					tid := ts[j].TaskID()
-					if tid >= tid0 {
+				} else if s.doing == s0.doing {
+					if r > r0 {
+						// else prefer task with lower rank
 						continue
+					} else if r == r0 {
+						if s.todo > s0.todo {
+							// else prefer task with fewer todo
+							continue
+						} else if s.todo == s0.todo {
+							// else prefer task with lower TaskID
+							// needed to be deterministic
+							tid0 := ts[j0].TaskID()
+							tid := t.TaskID()
+							if tid >= tid0 {
+								continue
+							}
+						}
 					}
 				}
 			}
@@ -119,6 +138,7 @@ func (statmap taskStatMap) pick(ts Tasks) int {
 
 		j0 = j
 		s0 = s
+		r0 = r
 	}
 
 	return j0
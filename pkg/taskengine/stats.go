@@ -0,0 +1,168 @@
+package taskengine
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats contains the statistics of an execution.
+type Stats struct {
+	// Start is the time the execution started.
+	Start time.Time
+
+	// Wall is the total duration of the execution.
+	Wall time.Duration
+
+	// Workers contains the statistics of each worker.
+	Workers map[WorkerID]*WorkerStats
+
+	// Tasks contains the statistics of each task.
+	Tasks map[TaskID]*TaskStats
+}
+
+// WorkerStats contains the statistics of a worker in an execution.
+type WorkerStats struct {
+	Instances int // number of instances of the worker
+	Done      int // works done: Success + Errors + Canceled
+	Success   int // works done with success
+	Errors    int // works failed
+	Canceled  int // works failed after the task was canceled
+	Skipped   int // tasks skipped because the circuit was open
+
+	// Latency contains the percentiles of the duration of the works.
+	Latency Latency
+
+	// Busy is the total time spent by the instances doing the works.
+	Busy time.Duration
+
+	// Idle is the total time the instances were not doing any work:
+	// Instances * Wall - Busy.
+	Idle time.Duration
+
+	latencies []time.Duration
+}
+
+// Latency contains the percentiles of the duration of the works.
+// All the values are zero if no work was done.
+type Latency struct {
+	Min time.Duration
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// TaskStats contains the statistics of a task in an execution.
+type TaskStats struct {
+	// Attempts is the number of works done for the task,
+	// skipped tasks excluded.
+	Attempts int
+
+	// Winner is the worker of the success returned for the task,
+	// the first one in All and UntilFirstSuccess mode.
+	// It is empty if the task has no success.
+	Winner WorkerID
+}
+
+// reset initializes the statistics of a new execution.
+func (st *Stats) reset(ws []*Worker, wts WorkerTasks, start time.Time) {
+	if st == nil {
+		return
+	}
+	*st = Stats{
+		Start:   start,
+		Workers: map[WorkerID]*WorkerStats{},
+		Tasks:   map[TaskID]*TaskStats{},
+	}
+	for _, w := range ws {
+		st.Workers[w.WorkerID] = &WorkerStats{Instances: w.Instances}
+	}
+	for _, ts := range wts {
+		for _, t := range ts {
+			if _, ok := st.Tasks[t.TaskID()]; !ok {
+				st.Tasks[t.TaskID()] = &TaskStats{}
+			}
+		}
+	}
+}
+
+// work records the work done by a worker for a task.
+// A failed work is counted as canceled if the task was canceled.
+func (st *Stats) work(wid WorkerID, tid TaskID, elapsed time.Duration, success, canceled bool) {
+	if st == nil {
+		return
+	}
+	ws := st.Workers[wid]
+	ws.Done++
+	switch {
+	case success:
+		ws.Success++
+	case canceled:
+		ws.Canceled++
+	default:
+		ws.Errors++
+	}
+	ws.Busy += elapsed
+	ws.latencies = append(ws.latencies, elapsed)
+	st.Tasks[tid].Attempts++
+}
+
+// skip records a task skipped by the worker.
+func (st *Stats) skip(wid WorkerID) {
+	if st == nil {
+		return
+	}
+	st.Workers[wid].Skipped++
+}
+
+// win records the worker of the success returned for the task.
+// Only the first winner is recorded.
+func (st *Stats) win(tid TaskID, wid WorkerID) {
+	if st == nil {
+		return
+	}
+	if ts := st.Tasks[tid]; ts.Winner == "" {
+		ts.Winner = wid
+	}
+}
+
+// finish computes the totals at the end of the execution.
+func (st *Stats) finish(end time.Time) {
+	if st == nil {
+		return
+	}
+	st.Wall = end.Sub(st.Start)
+	for _, ws := range st.Workers {
+		ws.Idle = time.Duration(ws.Instances)*st.Wall - ws.Busy
+		if ws.Idle < 0 {
+			ws.Idle = 0
+		}
+		ws.Latency = newLatency(ws.latencies)
+	}
+}
+
+// newLatency returns the percentiles of the durations,
+// using the nearest-rank method.
+func newLatency(ds []time.Duration) Latency {
+	if len(ds) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p int) time.Duration {
+		// rank = ceil(p/100 * n)
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+	return Latency{
+		Min: sorted[0],
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,122 @@
+package taskengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewLatency(t *testing.T) {
+	ms := time.Millisecond
+
+	ds := []time.Duration{}
+	for j := 100; j >= 1; j-- {
+		ds = append(ds, time.Duration(j)*ms)
+	}
+
+	testCases := map[string]struct {
+		ds       []time.Duration
+		expected Latency
+	}{
+		"empty": {
+			ds:       nil,
+			expected: Latency{},
+		},
+		"one": {
+			ds:       []time.Duration{10 * ms},
+			expected: Latency{10 * ms, 10 * ms, 10 * ms, 10 * ms, 10 * ms},
+		},
+		"three": {
+			ds:       []time.Duration{30 * ms, 10 * ms, 20 * ms},
+			expected: Latency{10 * ms, 20 * ms, 30 * ms, 30 * ms, 30 * ms},
+		},
+		"hundred": {
+			ds:       ds,
+			expected: Latency{1 * ms, 50 * ms, 90 * ms, 99 * ms, 100 * ms},
+		},
+	}
+
+	for title, tc := range testCases {
+		if diff := cmp.Diff(tc.expected, newLatency(tc.ds)); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", title, diff)
+		}
+	}
+}
+
+func TestExecuteStats(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 2, Work: workFn},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}, {"t2", 10, false}},
+		"w2": {{"t2", 40, true}},
+	})
+
+	stats := &Stats{}
+	out, err := ExecuteWithOptions(context.Background(), workers, tasks, &Options{Stats: stats})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for range out {
+	}
+
+	copts := cmp.Options{
+		cmp.AllowUnexported(WorkerStats{}),
+		cmpopts.IgnoreFields(WorkerStats{}, "Latency", "Busy", "Idle", "latencies"),
+	}
+	expectedWorkers := map[WorkerID]*WorkerStats{
+		"w1": {Instances: 1, Done: 2, Success: 1, Errors: 1},
+		"w2": {Instances: 2, Done: 1, Success: 1},
+	}
+	if diff := cmp.Diff(expectedWorkers, stats.Workers, copts); diff != "" {
+		t.Errorf("workers mismatch (-want +got):\n%s", diff)
+	}
+	expectedTasks := map[TaskID]*TaskStats{
+		"t1": {Attempts: 1, Winner: "w1"},
+		"t2": {Attempts: 2, Winner: "w2"},
+	}
+	if diff := cmp.Diff(expectedTasks, stats.Tasks); diff != "" {
+		t.Errorf("tasks mismatch (-want +got):\n%s", diff)
+	}
+
+	w2 := stats.Workers["w2"]
+	if stats.Wall < 40*time.Millisecond {
+		t.Errorf("wall: expected at least 40ms, found %v", stats.Wall)
+	}
+	if w2.Latency.Max < 40*time.Millisecond || w2.Latency.Max != w2.Busy {
+		t.Errorf("w2: expected latency equal to busy time of at least 40ms, found %v and %v", w2.Latency.Max, w2.Busy)
+	}
+	if expected := 2*stats.Wall - w2.Busy; w2.Idle != expected {
+		t.Errorf("w2: expected idle %v, found %v", expected, w2.Idle)
+	}
+}
+
+func TestExecuteStatsCanceled(t *testing.T) {
+	workers := []*Worker{
+		{WorkerID: "w1", Instances: 1, Work: workFn},
+		{WorkerID: "w2", Instances: 1, Work: workFn},
+	}
+	tasks := newTestWorkeridTasks(t, map[string]testCaseTasks{
+		"w1": {{"t1", 10, true}},
+		"w2": {{"t1", 200, true}},
+	})
+
+	stats := &Stats{}
+	out, err := ExecuteWithOptions(context.Background(), workers, tasks, &Options{Mode: UntilFirstSuccess, Stats: stats})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for range out {
+	}
+
+	if w2 := stats.Workers["w2"]; w2.Done != 1 || w2.Canceled != 1 {
+		t.Errorf("w2: expected 1 canceled work, found %d done and %d canceled", w2.Done, w2.Canceled)
+	}
+	if ts := stats.Tasks["t1"]; ts.Attempts != 2 || ts.Winner != "w1" {
+		t.Errorf("t1: expected 2 attempts won by w1, found %d attempts won by %q", ts.Attempts, ts.Winner)
+	}
+}
@@ -0,0 +1,122 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+)
+
+// StatusType is the status of the result of a work.
+type StatusType int
+
+// Values of the status of a result.
+const (
+	// Success means that the worker has done the task.
+	Success StatusType = iota
+
+	// Error means that the worker has failed the task.
+	Error
+
+	// Canceled means that the worker has failed the task
+	// because the context of the task has been canceled:
+	// see ErrTaskSucceeded and ErrQuorumReached.
+	// The task can also be canceled before the worker starts it:
+	// see CanceledResult.
+	Canceled
+
+	// Skipped means that the task has not been handed to the worker:
+	// see ErrCircuitOpen.
+	Skipped
+)
+
+var statusNames = []string{"success", "error", "canceled", "skipped"}
+
+func (s StatusType) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return "unknown"
+	}
+	return statusNames[s]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s StatusType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *StatusType) UnmarshalText(text []byte) error {
+	for j, name := range statusNames {
+		if string(text) == name {
+			*s = StatusType(j)
+			return nil
+		}
+	}
+	return errorf("invalid status %q", text)
+}
+
+// Reasons of the cancellation of a task by the engine.
+// If the task is canceled because the context of the execution is done,
+// the reason is the error of the context.
+var (
+	// ErrTaskSucceeded is the reason of the cancellation
+	// of a task done with success by another worker.
+	ErrTaskSucceeded = errors.New("canceled: task succeeded")
+
+	// ErrQuorumReached is the reason of the cancellation
+	// of a task whose quorum of successes has been reached in Quorum mode.
+	ErrQuorumReached = errors.New("canceled: quorum reached")
+)
+
+// CanceledResult is the Result of a task canceled before the work
+// has started. Err is the error of the context of the task.
+type CanceledResult struct {
+	WorkerID WorkerID
+	Task     Task
+	Err      error
+}
+
+// Success returns false.
+func (r *CanceledResult) Success() bool { return false }
+
+// Status returns Canceled.
+func (r *CanceledResult) Status() StatusType { return Canceled }
+
+// StatusResult is a Result with a status.
+type StatusResult interface {
+	Result
+	Status() StatusType
+}
+
+// StatusSetter is a Result that receives its status from the engine,
+// with the reason of the cancellation or of the skip, if any.
+// The engine calls SetStatus before returning the result or passing it
+// to the Observer and to the Reconcile function.
+type StatusSetter interface {
+	Result
+	SetStatus(status StatusType, reason error)
+}
+
+// ErrorResult is a Result exposing the error of the work.
+type ErrorResult interface {
+	Result
+	Err() error
+}
+
+// StatusOf returns the status of the result.
+// If the result does not implement StatusResult, the status is Success,
+// Canceled if it is an ErrorResult failed with the error of a done context,
+// or Error. The engine returns the Result of the worker unchanged:
+// the reason of the cancellation is available only to a StatusSetter.
+func StatusOf(res Result) StatusType {
+	if sr, ok := res.(StatusResult); ok {
+		return sr.Status()
+	}
+	if res.Success() {
+		return Success
+	}
+	if er, ok := res.(ErrorResult); ok {
+		if err := er.Err(); errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return Canceled
+		}
+	}
+	return Error
+}
@@ -0,0 +1,88 @@
+package taskengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStatusType(t *testing.T) {
+	testCases := map[StatusType]string{
+		Success:  "success",
+		Error:    "error",
+		Canceled: "canceled",
+		Skipped:  "skipped",
+		-1:       "unknown",
+	}
+	for status, expected := range testCases {
+		if got := status.String(); got != expected {
+			t.Errorf("String: expected %q, got %q", expected, got)
+		}
+	}
+
+	b, err := json.Marshal(struct{ Status StatusType }{Canceled})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := `{"Status":"canceled"}`; string(b) != expected {
+		t.Errorf("json: expected %s, got %s", expected, b)
+	}
+
+	var v struct{ Status StatusType }
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v.Status != Canceled {
+		t.Errorf("json: expected %v, got %v", Canceled, v.Status)
+	}
+	if err := json.Unmarshal([]byte(`{"Status":"done"}`), &v); err == nil {
+		t.Errorf("json: expected error for invalid status")
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	testCases := map[string]struct {
+		res      Result
+		expected StatusType
+	}{
+		"success": {
+			res:      &testResult{},
+			expected: Success,
+		},
+		"error": {
+			res:      &testResult{err: errors.New("ERR")},
+			expected: Error,
+		},
+		"skipped": {
+			res:      &SkippedResult{Err: ErrCircuitOpen},
+			expected: Skipped,
+		},
+		"canceled": {
+			res:      &testResult{err: fmt.Errorf("ERR: %w", context.Canceled)},
+			expected: Canceled,
+		},
+		"quorum success": {
+			res:      &QuorumResult{Results: []Result{&testResult{err: errors.New("ERR")}, &testResult{}}},
+			expected: Success,
+		},
+		"quorum skipped": {
+			res:      &QuorumResult{Results: []Result{&testResult{err: errors.New("ERR")}, &SkippedResult{}}},
+			expected: Skipped,
+		},
+		"quorum error": {
+			res:      &QuorumResult{Results: []Result{&testResult{err: errors.New("ERR")}}},
+			expected: Error,
+		},
+		"quorum empty": {
+			res:      &QuorumResult{},
+			expected: Skipped,
+		},
+	}
+	for title, tc := range testCases {
+		if got := StatusOf(tc.res); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", title, tc.expected, got)
+		}
+	}
+}
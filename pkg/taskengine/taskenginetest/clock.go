@@ -0,0 +1,212 @@
+package taskenginetest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// Clock is a simulated taskengine.Clock.
+// The time advances only when the Advance method is called:
+// it fires the next pending timer, and sets the current time to its time.
+//
+// The clock also counts the running goroutines of an execution,
+// so that Run advances it only when all of them are blocked:
+// a goroutine sleeping in Sleep is not running, and it runs again
+// when its timer fires or its context is done.
+type Clock struct {
+	mu      sync.Mutex
+	start   time.Time
+	now     time.Time
+	seq     int
+	timers  []*timer // pending timers
+	running int      // running goroutines and in-flight sends
+}
+
+// timer is a timer of the simulated clock.
+type timer struct {
+	clock *Clock
+	when  time.Time
+	key   string // order of the timers with the same time
+	seq   int    // order of creation
+	c     chan time.Time
+
+	// context of the goroutine sleeping on the timer, if any
+	ctx context.Context
+}
+
+// NewClock returns a new simulated clock starting at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{start: start, now: start}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Elapsed returns the time elapsed from the start of the clock.
+func (c *Clock) Elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now.Sub(c.start)
+}
+
+// NewTimer returns a new timer firing after duration d.
+// The timers of the engine fire before the works ending at the same time.
+func (c *Clock) NewTimer(d time.Duration) taskengine.Timer {
+	return c.newTimer(d, "")
+}
+
+func (c *Clock) newTimer(d time.Duration, key string) *timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addTimer(d, key)
+}
+
+// addTimer adds a new pending timer. The caller must hold the lock.
+func (c *Clock) addTimer(d time.Duration, key string) *timer {
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	t := &timer{
+		clock: c,
+		when:  c.now.Add(d),
+		key:   key,
+		seq:   c.seq,
+		c:     make(chan time.Time, 1),
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Sleep waits for duration d, or until the context is done.
+// In the latter case, it returns the context error
+// after a zero duration timer with the same key.
+// The timers with the same time fire in order of key, so that
+// the wake-ups of different goroutines are in a deterministic order.
+//
+// The calling goroutine is not counted as running while it sleeps.
+func (c *Clock) Sleep(ctx context.Context, d time.Duration, key string) error {
+	c.mu.Lock()
+	t := c.addTimer(d, key)
+	t.ctx = ctx
+	c.running--
+	c.mu.Unlock()
+
+	select {
+	case <-t.c:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// fire the timer at the current time, if still pending
+	c.mu.Lock()
+	t.ctx = nil
+	if t.stop() {
+		t = c.addTimer(0, key)
+	}
+	c.mu.Unlock()
+
+	<-t.c
+	return ctx.Err()
+}
+
+// begin counts a new running goroutine, or a send to the engine
+// that is not received yet.
+func (c *Clock) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running++
+}
+
+// end counts the end of a running goroutine, or the receipt of a send.
+func (c *Clock) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+}
+
+// blocked returns true if all the counted goroutines are blocked,
+// i.e. none is running and no sleeping goroutine has its context done.
+func (c *Clock) blocked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running > 0 {
+		return false
+	}
+	for _, t := range c.timers {
+		if t.ctx != nil && t.ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Pending returns the number of pending timers.
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Advance fires the next pending timer, setting the current time to its time.
+// The next timer is the one with the earliest time, then the lowest key,
+// then the first created.
+// It returns false if there are no pending timers.
+func (c *Clock) Advance() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.timers) == 0 {
+		return false
+	}
+	sort.Slice(c.timers, func(i, j int) bool {
+		a, b := c.timers[i], c.timers[j]
+		if !a.when.Equal(b.when) {
+			return a.when.Before(b.when)
+		}
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.seq < b.seq
+	})
+	t := c.timers[0]
+	c.timers = c.timers[1:]
+	if t.when.After(c.now) {
+		c.now = t.when
+	}
+	// the receiver of the time runs again
+	c.running++
+	t.c <- c.now
+	return true
+}
+
+// C returns the channel on which the time is delivered.
+func (t *timer) C() <-chan time.Time { return t.c }
+
+// Stop prevents the timer from firing.
+// It returns false if the timer has already fired or been stopped.
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return t.stop()
+}
+
+// stop is the Stop method. The caller must hold the lock.
+func (t *timer) stop() bool {
+	c := t.clock
+	for j, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:j], c.timers[j+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package taskenginetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// stallTimeout is the maximum wall-clock time waited
+// for the goroutines to block.
+const stallTimeout = 10 * time.Second
+
+// observer forwards the events of the engine to the observer of the options,
+// and counts on the clock the running goroutines of the execution:
+// a task assigned to a worker runs until the work sleeps,
+// and a send to the engine is received when the engine waits again.
+type observer struct {
+	taskengine.Observer
+	clock *Clock
+}
+
+func (o *observer) TaskAssigned(wid taskengine.WorkerID, tid taskengine.TaskID) {
+	o.clock.begin()
+	o.Observer.TaskAssigned(wid, tid)
+}
+
+// Waiting implements the hook.Waiter interface.
+func (o *observer) Waiting() {
+	o.clock.end()
+}
+
+// nopObserver is the Observer that ignores all the events.
+type nopObserver struct{}
+
+func (nopObserver) TaskAssigned(taskengine.WorkerID, taskengine.TaskID)                         {}
+func (nopObserver) TaskStarted(taskengine.WorkerID, int, taskengine.TaskID)                     {}
+func (nopObserver) TaskFinished(taskengine.WorkerID, int, taskengine.TaskID, taskengine.Result) {}
+func (nopObserver) TaskCanceled(taskengine.TaskID)                                              {}
+func (nopObserver) TaskCompleted(taskengine.TaskID, bool)                                       {}
+func (nopObserver) WorkerIdle(taskengine.WorkerID, int)                                         {}
+func (nopObserver) WorkerClosed(taskengine.WorkerID)                                            {}
+
+// Result is the result of a work of a scripted worker.
+type Result struct {
+	WorkerID taskengine.WorkerID
+	TaskID   taskengine.TaskID
+	Err      error // nil, ErrScripted or the context error
+
+	// Reason of the cancellation, set by the engine with the status.
+	Reason error
+
+	status taskengine.StatusType
+}
+
+// Success returns true if the work succeeded.
+func (r *Result) Success() bool { return r.Err == nil }
+
+// Status implements the taskengine.StatusResult interface.
+func (r *Result) Status() taskengine.StatusType { return r.status }
+
+// SetStatus implements the taskengine.StatusSetter interface.
+func (r *Result) SetStatus(status taskengine.StatusType, reason error) {
+	r.status, r.Reason = status, reason
+}
+
+// outcome returns "ok", "err" or "canceled" given the error of a work.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "err"
+}
+
+// statusNames are the text representations of the status of a result.
+var statusNames = map[taskengine.StatusType]string{
+	taskengine.Success:  "ok",
+	taskengine.Error:    "err",
+	taskengine.Canceled: "canceled",
+	taskengine.Skipped:  "skipped",
+}
+
+// FormatResult returns the text representation of a result returned by the engine,
+// given its status: "w1/t1 ok", "w1/t1 err", "w1/t1 canceled", "w1/t1 skipped"
+// or "quorum(w1/t1 ok, w2/t1 err)".
+func FormatResult(res taskengine.Result) string {
+	switch r := res.(type) {
+	case *Result:
+		return fmt.Sprintf("%s/%s %s", r.WorkerID, r.TaskID, statusNames[r.status])
+	case *taskengine.SkippedResult:
+		return fmt.Sprintf("%s/%s skipped", r.WorkerID, r.Task.TaskID())
+	case *taskengine.CanceledResult:
+		return fmt.Sprintf("%s/%s canceled", r.WorkerID, r.Task.TaskID())
+	case *taskengine.QuorumResult:
+		parts := make([]string, 0, len(r.Results))
+		for _, rr := range r.Results {
+			parts = append(parts, FormatResult(rr))
+		}
+		return "quorum(" + strings.Join(parts, ", ") + ")"
+	}
+	return fmt.Sprintf("%v", res)
+}
+
+// Event is a result returned by the engine at the simulated time At,
+// elapsed from the start of the execution.
+type Event struct {
+	At     time.Duration
+	Result taskengine.Result
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%v %s", e.At, FormatResult(e.Result))
+}
+
+// Events is the list of the results returned by the engine, in order.
+type Events []Event
+
+// String returns the events separated by "; ", e.g. "10ms w1/t1 ok; 20ms w2/t2 err".
+func (es Events) String() string {
+	parts := make([]string, 0, len(es))
+	for _, e := range es {
+		parts = append(parts, e.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Work is a work done by a scripted worker, with its simulated start and end time.
+type Work struct {
+	WorkerID taskengine.WorkerID
+	TaskID   taskengine.TaskID
+	Start    time.Duration
+	End      time.Duration
+	Err      error
+}
+
+func (w Work) String() string {
+	return fmt.Sprintf("%s/%s %v..%v %s", w.WorkerID, w.TaskID, w.Start, w.End, outcome(w.Err))
+}
+
+// Works is the list of the works done, in order of end.
+type Works []Work
+
+// String returns the works separated by "; ", e.g. "w1/t1 0s..10ms ok; w2/t1 0s..10ms canceled".
+func (ws Works) String() string {
+	parts := make([]string, 0, len(ws))
+	for _, w := range ws {
+		parts = append(parts, w.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Trace is the trace of the execution of a scenario.
+type Trace struct {
+	Results Events
+	Works   Works
+
+	mu sync.Mutex
+}
+
+func (tr *Trace) addWork(w Work) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.Works = append(tr.Works, w)
+}
+
+// task is the Task of a scripted worker.
+type task struct {
+	ScriptedTask
+	key string // order of the end of the works at the same time
+}
+
+func (t *task) TaskID() taskengine.TaskID { return t.ScriptedTask.TaskID }
+
+// Priority implements the taskengine.PriorityTask interface.
+func (t *task) Priority() int { return t.ScriptedTask.Priority }
+
+// engineArgs returns the workers and the tasks of the scenario,
+// whose works use the clock and are recorded in the trace.
+func (sc *Scenario) engineArgs(clock *Clock, tr *Trace) ([]*taskengine.Worker, taskengine.WorkerTasks) {
+	var workers []*taskengine.Worker
+	wts := taskengine.WorkerTasks{}
+
+	for j, sw := range sc.Workers {
+		wid := sw.WorkerID
+		work := func(ctx context.Context, inst int, t taskengine.Task) taskengine.Result {
+			st := t.(*task)
+			start := clock.Elapsed()
+			err := clock.Sleep(ctx, st.Latency, st.key)
+			if err == nil && !st.Success {
+				err = ErrScripted
+			}
+			tr.addWork(Work{
+				WorkerID: wid,
+				TaskID:   st.TaskID(),
+				Start:    start,
+				End:      clock.Elapsed(),
+				Err:      err,
+			})
+			return &Result{WorkerID: wid, TaskID: st.TaskID(), Err: err}
+		}
+		workers = append(workers, &taskengine.Worker{
+			WorkerID:  wid,
+			Instances: sw.Instances,
+			Work:      work,
+		})
+
+		ts := taskengine.Tasks{}
+		for _, st := range sw.Tasks {
+			ts = append(ts, &task{
+				ScriptedTask: st,
+				key:          fmt.Sprintf("%04d/%s", j, st.TaskID),
+			})
+		}
+		wts[wid] = ts
+	}
+	return workers, wts
+}
+
+// Run executes the scenario with the given options and the simulated clock,
+// and returns the trace of the execution.
+// The Clock option is replaced by the simulated clock, and the Observer option
+// is wrapped to count the running goroutines of the execution.
+//
+// The clock advances only when all the goroutines are blocked: the workers
+// sleeping until the end of their works, and the engine waiting for their results.
+func (sc *Scenario) Run(opts *taskengine.Options) (*Trace, error) {
+	var o taskengine.Options
+	if opts != nil {
+		o = *opts
+	}
+	clock := NewClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	o.Clock = clock
+	obs := &observer{Observer: o.Observer, clock: clock}
+	if obs.Observer == nil {
+		obs.Observer = nopObserver{}
+	}
+	o.Observer = obs
+
+	// the engine starts running, and each worker instance
+	// sends a void output to signal it is ready
+	clock.begin()
+	for _, sw := range sc.Workers {
+		for i := 0; i < sw.Instances; i++ {
+			clock.begin()
+		}
+	}
+
+	tr := &Trace{}
+	workers, wts := sc.engineArgs(clock, tr)
+	results, err := taskengine.ExecuteWithOptions(context.Background(), workers, wts, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(stallTimeout)
+	for {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				return tr, nil
+			}
+			tr.Results = append(tr.Results, Event{At: clock.Elapsed(), Result: res})
+			continue
+		default:
+		}
+		if !clock.blocked() {
+			if time.Now().After(deadline) {
+				return tr, fmt.Errorf("goroutines not blocked after %v", stallTimeout)
+			}
+			runtime.Gosched()
+			continue
+		}
+		if !clock.Advance() {
+			return tr, fmt.Errorf("deadlock at %v: no pending timers", clock.Elapsed())
+		}
+		deadline = time.Now().Add(stallTimeout)
+	}
+}
@@ -0,0 +1,142 @@
+// Package taskenginetest provides utilities for deterministic tests
+// of the taskengine package, without wall-clock sleeps.
+//
+// A Scenario declares scripted workers: each task of a worker has
+// a latency and an outcome. The scenario is executed with a simulated Clock,
+// that advances only when all the goroutines are blocked, one timer at a time.
+// The Trace of the execution contains the results returned by the engine
+// and the works done, with their simulated times.
+//
+// Scenarios are described by a text DSL:
+//
+//	scenario := worker { ";" worker }
+//	worker   := workerid [ "*" instances ] ":" task { "," task }
+//	task     := taskid latency outcome [ "p=" priority ]
+//	outcome  := "ok" | "err"
+//
+// where latency is a time.Duration string, and priority is the
+// priority of the task for the worker (default 0). For example:
+//
+//	"w1: t1 10ms ok, t2 20ms err; w2*2: t1 30ms ok p=1"
+//
+// The works ending at the same time end in order of worker, then of task;
+// the timers of the engine fire before them.
+package taskenginetest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+// ErrScripted is the error of the works with the "err" outcome.
+var ErrScripted = errors.New("scripted error")
+
+// ScriptedTask is a task of a scripted worker.
+type ScriptedTask struct {
+	TaskID   taskengine.TaskID
+	Latency  time.Duration
+	Success  bool
+	Priority int
+}
+
+// ScriptedWorker is a worker whose works have a declared latency and outcome.
+type ScriptedWorker struct {
+	WorkerID  taskengine.WorkerID
+	Instances int
+	Tasks     []ScriptedTask
+}
+
+// Scenario is the list of the scripted workers of an execution.
+type Scenario struct {
+	Workers []*ScriptedWorker
+}
+
+// Parse returns the scenario described by the DSL string s.
+func Parse(s string) (*Scenario, error) {
+	sc := &Scenario{}
+	for _, ws := range strings.Split(s, ";") {
+		ws = strings.TrimSpace(ws)
+		if ws == "" {
+			continue
+		}
+		w, err := parseWorker(ws)
+		if err != nil {
+			return nil, fmt.Errorf("invalid worker %q: %w", ws, err)
+		}
+		sc.Workers = append(sc.Workers, w)
+	}
+	if len(sc.Workers) == 0 {
+		return nil, errors.New("empty scenario")
+	}
+	return sc, nil
+}
+
+// MustParse is like Parse but panics if the scenario cannot be parsed.
+func MustParse(s string) *Scenario {
+	sc, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return sc
+}
+
+func parseWorker(s string) (*ScriptedWorker, error) {
+	colon := strings.Index(s, ":")
+	if colon < 0 {
+		return nil, errors.New("missing colon")
+	}
+	head := strings.TrimSpace(s[:colon])
+	w := &ScriptedWorker{Instances: 1}
+	if star := strings.Index(head, "*"); star >= 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(head[star+1:]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid instances %q", head[star+1:])
+		}
+		w.Instances = n
+		head = strings.TrimSpace(head[:star])
+	}
+	if head == "" {
+		return nil, errors.New("missing worker id")
+	}
+	w.WorkerID = taskengine.WorkerID(head)
+
+	for _, ts := range strings.Split(s[colon+1:], ",") {
+		fields := strings.Fields(ts)
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("invalid task %q: want \"taskid latency outcome [p=priority]\"", strings.TrimSpace(ts))
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid latency %q", fields[1])
+		}
+		var success bool
+		switch fields[2] {
+		case "ok":
+			success = true
+		case "err":
+		default:
+			return nil, fmt.Errorf("invalid outcome %q: want \"ok\" or \"err\"", fields[2])
+		}
+		var priority int
+		if len(fields) == 4 {
+			if !strings.HasPrefix(fields[3], "p=") {
+				return nil, fmt.Errorf("invalid priority %q: want \"p=priority\"", fields[3])
+			}
+			if priority, err = strconv.Atoi(fields[3][2:]); err != nil {
+				return nil, fmt.Errorf("invalid priority %q", fields[3])
+			}
+		}
+		w.Tasks = append(w.Tasks, ScriptedTask{
+			TaskID:   taskengine.TaskID(fields[0]),
+			Latency:  d,
+			Success:  success,
+			Priority: priority,
+		})
+	}
+	return w, nil
+}
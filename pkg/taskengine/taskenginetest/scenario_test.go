@@ -0,0 +1,390 @@
+package taskenginetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mmbros/quote/pkg/taskengine"
+)
+
+func TestParse(t *testing.T) {
+	sc, err := Parse("w1: t1 10ms ok, t2 1s err p=2; w2*3: t1 0s ok;")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := &Scenario{
+		Workers: []*ScriptedWorker{
+			{WorkerID: "w1", Instances: 1, Tasks: []ScriptedTask{
+				{TaskID: "t1", Latency: 10 * time.Millisecond, Success: true},
+				{TaskID: "t2", Latency: time.Second, Success: false, Priority: 2},
+			}},
+			{WorkerID: "w2", Instances: 3, Tasks: []ScriptedTask{
+				{TaskID: "t1", Latency: 0, Success: true},
+			}},
+		},
+	}
+	if diff := cmp.Diff(expected, sc); diff != "" {
+		t.Errorf("scenario mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := map[string]string{
+		"empty":             " ; ",
+		"missing colon":     "w1 t1 10ms ok",
+		"missing worker id": ": t1 10ms ok",
+		"invalid instances": "w1*0: t1 10ms ok",
+		"missing outcome":   "w1: t1 10ms",
+		"invalid latency":   "w1: t1 10 ok",
+		"invalid outcome":   "w1: t1 10ms done",
+		"invalid priority":  "w1: t1 10ms ok 2",
+		"too many fields":   "w1: t1 10ms ok p=1 p=2",
+	}
+	for title, s := range testCases {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s: expected error, got nil", title)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	ms := time.Millisecond
+
+	testCases := []struct {
+		title    string
+		opts     taskengine.Options
+		scenario string
+		results  string
+		works    string
+	}{
+		{
+			title:    "first success or last error",
+			opts:     taskengine.Options{Mode: taskengine.FirstSuccessOrLastError},
+			scenario: "w1: t1 10ms ok, t2 10ms err; w2: t1 20ms ok, t2 5ms err",
+			results:  "10ms w1/t1 ok; 20ms w1/t2 err",
+			works:    "w2/t2 0s..5ms err; w1/t1 0s..10ms ok; w2/t1 5ms..10ms canceled; w1/t2 10ms..20ms err",
+		},
+		{
+			title:    "until first success",
+			opts:     taskengine.Options{Mode: taskengine.UntilFirstSuccess},
+			scenario: "w1: t1 10ms err; w2: t1 20ms ok; w3: t1 30ms ok",
+			results:  "10ms w1/t1 err; 20ms w2/t1 ok",
+			works:    "w1/t1 0s..10ms err; w2/t1 0s..20ms ok; w3/t1 0s..20ms canceled",
+		},
+		{
+			title:    "all",
+			opts:     taskengine.Options{Mode: taskengine.All},
+			scenario: "w1: t1 10ms err; w2: t1 20ms ok; w3: t1 30ms ok",
+			results:  "10ms w1/t1 err; 20ms w2/t1 ok; 20ms w3/t1 canceled",
+			works:    "w1/t1 0s..10ms err; w2/t1 0s..20ms ok; w3/t1 0s..20ms canceled",
+		},
+		{
+			title:    "same time: first worker wins",
+			opts:     taskengine.Options{Mode: taskengine.All},
+			scenario: "w1: t1 10ms ok; w2: t1 10ms ok",
+			results:  "10ms w1/t1 ok; 10ms w2/t1 canceled",
+			works:    "w1/t1 0s..10ms ok; w2/t1 0s..10ms canceled",
+		},
+		{
+			title:    "instances",
+			opts:     taskengine.Options{Mode: taskengine.All},
+			scenario: "w1*2: t1 10ms ok, t2 10ms ok, t3 10ms ok",
+			results:  "10ms w1/t1 ok; 10ms w1/t2 ok; 20ms w1/t3 ok",
+			works:    "w1/t1 0s..10ms ok; w1/t2 0s..10ms ok; w1/t3 10ms..20ms ok",
+		},
+		{
+			title:    "hedged after failure",
+			opts:     taskengine.Options{Mode: taskengine.Hedged},
+			scenario: "w1: t1 10ms err; w2: t1 20ms ok",
+			results:  "30ms w2/t1 ok",
+			works:    "w1/t1 0s..10ms err; w2/t1 10ms..30ms ok",
+		},
+		{
+			title:    "hedged after delay",
+			opts:     taskengine.Options{Mode: taskengine.Hedged, HedgeDelay: 50 * ms},
+			scenario: "w1: t1 100ms ok; w2: t1 20ms ok",
+			results:  "70ms w2/t1 ok",
+			works:    "w2/t1 50ms..70ms ok; w1/t1 0s..70ms canceled",
+		},
+		{
+			title:    "hedged success before delay",
+			opts:     taskengine.Options{Mode: taskengine.Hedged, HedgeDelay: 50 * ms},
+			scenario: "w1: t1 30ms ok; w2: t1 20ms ok",
+			results:  "30ms w1/t1 ok",
+			works:    "w1/t1 0s..30ms ok",
+		},
+		{
+			title:    "priority window",
+			opts:     taskengine.Options{PriorityWindow: 50 * ms},
+			scenario: "w1: t1 40ms ok p=1; w2: t1 10ms ok",
+			results:  "40ms w1/t1 ok",
+			works:    "w2/t1 0s..10ms ok; w1/t1 0s..40ms ok",
+		},
+		{
+			title:    "priority window expired",
+			opts:     taskengine.Options{PriorityWindow: 20 * ms},
+			scenario: "w1: t1 40ms ok p=1; w2: t1 10ms ok",
+			results:  "30ms w2/t1 ok",
+			works:    "w2/t1 0s..10ms ok; w1/t1 0s..30ms canceled",
+		},
+		{
+			title:    "quorum",
+			opts:     taskengine.Options{Mode: taskengine.Quorum, Quorum: 2},
+			scenario: "w1: t1 10ms ok; w2: t1 20ms err; w3: t1 30ms ok; w4: t1 40ms ok",
+			results:  "30ms quorum(w1/t1 ok, w2/t1 err, w3/t1 ok)",
+			works:    "w1/t1 0s..10ms ok; w2/t1 0s..20ms err; w3/t1 0s..30ms ok; w4/t1 0s..30ms canceled",
+		},
+		{
+			title:    "quorum not reached",
+			opts:     taskengine.Options{Mode: taskengine.Quorum, Quorum: 3},
+			scenario: "w1: t1 10ms ok; w2: t1 20ms err",
+			results:  "20ms quorum(w1/t1 ok, w2/t1 err)",
+			works:    "w1/t1 0s..10ms ok; w2/t1 0s..20ms err",
+		},
+	}
+
+	for _, tc := range testCases {
+		tr, err := MustParse(tc.scenario).Run(&tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.title, err)
+		}
+		if got := tr.Results.String(); got != tc.results {
+			t.Errorf("%s: results: expected %q, got %q", tc.title, tc.results, got)
+		}
+		if got := tr.Works.String(); got != tc.works {
+			t.Errorf("%s: works: expected %q, got %q", tc.title, tc.works, got)
+		}
+	}
+}
+
+func TestRunCircuit(t *testing.T) {
+	cb, err := taskengine.NewCircuitBreaker(2, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	opts := &taskengine.Options{Mode: taskengine.All, Circuit: cb}
+	tr, err := MustParse("w1: t1 10ms err, t2 10ms err, t3 10ms ok, t4 10ms ok").Run(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// the remaining tasks are skipped in the engine order
+	expected := "10ms w1/t1 err; 20ms w1/t2 err; 20ms w1/t4 skipped; 20ms w1/t3 skipped"
+	if got := tr.Results.String(); got != expected {
+		t.Errorf("results: expected %q, got %q", expected, got)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	stats := &taskengine.Stats{}
+	opts := &taskengine.Options{Stats: stats}
+	_, err := MustParse("w1: t1 10ms err, t2 30ms ok; w2: t1 20ms ok").Run(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// w1 does t2 first, then t1 already canceled by the success of w2
+	if stats.Wall != 30*time.Millisecond {
+		t.Errorf("wall: expected 30ms, got %v", stats.Wall)
+	}
+	w1 := stats.Workers["w1"]
+	if w1.Busy != 30*time.Millisecond || w1.Canceled != 1 {
+		t.Errorf("w1: expected busy 30ms and 1 canceled, got %v and %d", w1.Busy, w1.Canceled)
+	}
+	w2 := stats.Workers["w2"]
+	if w2.Idle != 10*time.Millisecond {
+		t.Errorf("w2: expected idle 10ms, got %v", w2.Idle)
+	}
+	if winner := stats.Tasks["t1"].Winner; winner != "w2" {
+		t.Errorf("t1: expected winner w2, got %q", winner)
+	}
+}
+
+// statusObserver records the status and the reason of the finished works.
+type statusObserver struct {
+	mu       sync.Mutex
+	finished []string
+}
+
+func (o *statusObserver) TaskFinished(wid taskengine.WorkerID, inst int, tid taskengine.TaskID, res taskengine.Result) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var reason error
+	switch r := res.(type) {
+	case *Result:
+		reason = r.Reason
+	case *taskengine.SkippedResult:
+		reason = r.Err
+	}
+	o.finished = append(o.finished, fmt.Sprintf("%s/%s %v %v", wid, tid, taskengine.StatusOf(res), reason))
+}
+
+func (o *statusObserver) TaskAssigned(taskengine.WorkerID, taskengine.TaskID)     {}
+func (o *statusObserver) TaskStarted(taskengine.WorkerID, int, taskengine.TaskID) {}
+func (o *statusObserver) TaskCanceled(taskengine.TaskID)                          {}
+func (o *statusObserver) TaskCompleted(taskengine.TaskID, bool)                   {}
+func (o *statusObserver) WorkerIdle(taskengine.WorkerID, int)                     {}
+func (o *statusObserver) WorkerClosed(taskengine.WorkerID)                        {}
+
+func TestRunStatus(t *testing.T) {
+	cb, err := taskengine.NewCircuitBreaker(1, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testCases := []struct {
+		title    string
+		opts     taskengine.Options
+		scenario string
+		finished []string
+	}{
+		{
+			title:    "first success or last error",
+			opts:     taskengine.Options{Mode: taskengine.FirstSuccessOrLastError},
+			scenario: "w1: t1 10ms ok; w2: t1 20ms err; w3: t2 10ms err",
+			finished: []string{
+				"w1/t1 success <nil>",
+				"w2/t1 canceled canceled: task succeeded",
+				"w3/t2 error <nil>",
+			},
+		},
+		{
+			title:    "until first success",
+			opts:     taskengine.Options{Mode: taskengine.UntilFirstSuccess},
+			scenario: "w1: t1 10ms err; w2: t1 20ms ok; w3: t1 30ms ok",
+			finished: []string{
+				"w1/t1 error <nil>",
+				"w2/t1 success <nil>",
+				"w3/t1 canceled canceled: task succeeded",
+			},
+		},
+		{
+			title:    "all",
+			opts:     taskengine.Options{Mode: taskengine.All},
+			scenario: "w1: t1 10ms ok; w2: t1 20ms ok",
+			finished: []string{
+				"w1/t1 success <nil>",
+				"w2/t1 canceled canceled: task succeeded",
+			},
+		},
+		{
+			title:    "hedged",
+			opts:     taskengine.Options{Mode: taskengine.Hedged, HedgeDelay: 50 * time.Millisecond},
+			scenario: "w1: t1 100ms ok; w2: t1 20ms ok",
+			finished: []string{
+				"w2/t1 success <nil>",
+				"w1/t1 canceled canceled: task succeeded",
+			},
+		},
+		{
+			title:    "quorum",
+			opts:     taskengine.Options{Mode: taskengine.Quorum, Quorum: 1},
+			scenario: "w1: t1 10ms ok; w2: t1 20ms ok",
+			finished: []string{
+				"w1/t1 success <nil>",
+				"w2/t1 canceled canceled: quorum reached",
+			},
+		},
+		{
+			title:    "circuit",
+			opts:     taskengine.Options{Mode: taskengine.All, Circuit: cb},
+			scenario: "w1: t1 10ms err, t2 10ms ok",
+			finished: []string{
+				"w1/t1 error <nil>",
+				"w1/t2 skipped skipped: circuit open",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		obs := &statusObserver{}
+		tc.opts.Observer = obs
+		if _, err := MustParse(tc.scenario).Run(&tc.opts); err != nil {
+			t.Fatalf("%s: %v", tc.title, err)
+		}
+		if diff := cmp.Diff(tc.finished, obs.finished); diff != "" {
+			t.Errorf("%s: finished mismatch (-want +got):\n%s", tc.title, diff)
+		}
+	}
+}
+
+// randomScenario returns the DSL of a random scenario with the given number
+// of workers, instances of each worker and tasks.
+// Each worker does each task with probability spread%, and each work fails
+// with probability errPerc%. The latencies are normally distributed with
+// the given mean and standard deviation, rounded to the millisecond.
+func randomScenario(rnd *rand.Rand, workers, instances, tasks, spread, errPerc int, mean, stdDev time.Duration) string {
+	var ws []string
+	for wj := 1; wj <= workers; wj++ {
+		var ts []string
+		for tj := 1; tj <= tasks; tj++ {
+			if rnd.Intn(100) >= spread {
+				continue
+			}
+			latency := time.Duration(rnd.NormFloat64()*float64(stdDev) + float64(mean))
+			if latency < 0 {
+				latency = 0
+			}
+			outcome := "ok"
+			if rnd.Intn(100) < errPerc {
+				outcome = "err"
+			}
+			ts = append(ts, fmt.Sprintf("t%d %v %s", tj, latency.Round(time.Millisecond), outcome))
+		}
+		if len(ts) > 0 {
+			ws = append(ws, fmt.Sprintf("w%d*%d: %s", wj, instances, strings.Join(ts, ", ")))
+		}
+	}
+	return strings.Join(ws, "; ")
+}
+
+func TestRunRandom(t *testing.T) {
+	s := randomScenario(rand.New(rand.NewSource(1)), 5, 2, 100, 90, 50, 200*time.Millisecond, 50*time.Millisecond)
+	opts := &taskengine.Options{Mode: taskengine.All}
+
+	tr, err := MustParse(s).Run(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// in All mode, each work is returned
+	if len(tr.Results) != len(tr.Works) {
+		t.Errorf("expected %d results, got %d", len(tr.Works), len(tr.Results))
+	}
+
+	// the canceled works end at the time of the first success of the task,
+	// or immediately if started after it
+	succeeded := map[taskengine.TaskID]time.Duration{}
+	for _, w := range tr.Works {
+		if _, ok := succeeded[w.TaskID]; !ok && w.Err == nil {
+			succeeded[w.TaskID] = w.End
+		}
+	}
+	for _, w := range tr.Works {
+		if !errors.Is(w.Err, context.Canceled) {
+			continue
+		}
+		at, ok := succeeded[w.TaskID]
+		if !ok || (w.Start < at && w.End != at) || (w.Start >= at && w.End != w.Start) {
+			t.Errorf("%v: expected end at the first success of the task", w)
+		}
+	}
+
+	// the execution is deterministic
+	tr2, err := MustParse(s).Run(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if tr2.Works.String() != tr.Works.String() {
+		t.Errorf("works differ between two runs of the same scenario")
+	}
+	if tr2.Results.String() != tr.Results.String() {
+		t.Errorf("results differ between two runs of the same scenario")
+	}
+}
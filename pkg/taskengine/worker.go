@@ -56,6 +56,10 @@ type Worker struct {
 
 	// The work function
 	Work WorkFunc
+
+	// Rate limits the works of all the instances of the worker.
+	// If nil, the works are not limited.
+	Rate *RateLimiter
 }
 
 // Tasks is an array of tasks.
@@ -127,3 +131,17 @@ func Execute(ctx context.Context, workers []*Worker, tasks WorkerTasks, mode Mod
 
 	return eng.Execute(mode)
 }
+
+// ExecuteWithOptions function returns a chan that receives the Results of the workers
+// for the input Requests, executed with the given options.
+func ExecuteWithOptions(ctx context.Context, workers []*Worker, tasks WorkerTasks, opts *Options) (chan Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	eng, err := newEngine(ctx, workers, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	return eng.execute(opts)
+}